@@ -14,6 +14,7 @@ var DEFAULTS_INPUT_DIRS = []string{"/usr/local/share/mendel-go"}
 func Usage(exitCode int) {
 	usageStr1 := `Usage:
   mendel-go -f <filename> [-D <defaults-path>] [-O <data-path>] [-z] [-u <SPC-username>]
+  mendel-go -f <filename> -r <checkpoint> [-D <defaults-path>] [-O <data-path>] [-z] [-u <SPC-username>]
   mendel-go -d [-D <defaults-path>] [-O <data-path>] [-z] [-u <SPC-username>]
   mendel-go -c <filename> [-D <defaults-path>] [-O <data-path>]
   mendel-go -V
@@ -28,6 +29,7 @@ Examples:
   mendel-go -f /home/bob/mendel.in    # run with this input file
   mendel-go -d     # run with all default parameters from `+ DEFAULTS_INPUT_FILE +`
   mendel-go -c /home/bob/mendel.in    # create an input file primed with defaults, then you can edit it
+  mendel-go -f /home/bob/mendel.in -r output/mycase/mendel.chk    # continue an interrupted run from its last checkpoint
 `
 
 	//if exitCode > 0 {
@@ -43,7 +45,7 @@ Examples:
 }
 
 type CommandArgs struct {
-	InputFile, InputFileToCreate, DefaultFile, DataPath, SPCusername string
+	InputFile, InputFileToCreate, DefaultFile, DataPath, SPCusername, RestartFile string
 	CreateZip, Version bool
 }
 
@@ -61,6 +63,7 @@ func ReadCmdArgs() {
 	flag.StringVar(&CmdArgs.DefaultFile, "D", "", "Path to the defaults file. If not set, looks for "+DEFAULTS_INPUT_FILE+" in the current directory, the directory of the executable, and "+strings.Join(DEFAULTS_INPUT_DIRS,", "))
 	flag.StringVar(&CmdArgs.DataPath, "O", "", "Path to put the output data files in. If not set, the data_file_path in the input config file or defaults file is used.")
 	flag.StringVar(&CmdArgs.SPCusername, "u", "", "Create a zip of the output for this SPC username, suitable for importing into SPC for data visualization.")
+	flag.StringVar(&CmdArgs.RestartFile, "r", "", "Continue a run from this checkpoint file. Must be used with the same input file (-f or -d) the checkpoint was created with.")
 	flag.StringVar(&CmdArgs.InputFileToCreate, "c", "", "Create a mendel input file (using default values) and then exit")
 	flag.BoolVar(&useDefaults, "d", false, "Run mendel with all default parameters")
 	flag.BoolVar(&CmdArgs.CreateZip, "z", false, "Create a zip of the output, suitable for importing into the Mendel web UI for data visualization")
@@ -71,7 +74,7 @@ func ReadCmdArgs() {
 	// spew.Dump(flag.Lookup("f").Value.String())
 
 	if CmdArgs.InputFileToCreate != "" {
-		if CmdArgs.InputFile != "" || useDefaults { log.Println("Error: if you specify -c you can not specify either -f or -d"); Usage(1) }
+		if CmdArgs.InputFile != "" || useDefaults || CmdArgs.RestartFile != "" { log.Println("Error: if you specify -c you can not specify -f, -d, or -r"); Usage(1) }
 
 	} else if useDefaults {
 		if CmdArgs.InputFile != "" || CmdArgs.InputFileToCreate != "" { log.Println("Error: if you specify -d you can not specify either -f or -c"); Usage(1) }
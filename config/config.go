@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 	"runtime"
+	"strconv"
 	"github.com/genetic-algorithms/mendel-go/utils"
 	"fmt"
 	"bytes"
@@ -30,6 +31,7 @@ type Config struct {
 	Mutations struct {
 		Mutn_rate float64  `toml:"mutn_rate"`
 		Mutn_rate_model string  `toml:"mutn_rate_model"`	// toml does not know how to handle user-defined types like MutationRateModelType
+		Mutn_rate_map_file string  `toml:"mutn_rate_map_file"`
 		Frac_fav_mutn float64  `toml:"frac_fav_mutn"`
 		Fraction_neutral float64  `toml:"fraction_neutral"`
 		Genome_size float64  `toml:"genome_size"`
@@ -39,6 +41,12 @@ type Config struct {
 		High_impact_mutn_fraction float64  `toml:"high_impact_mutn_fraction"`
 		High_impact_mutn_threshold float64  `toml:"high_impact_mutn_threshold"`
 		Max_fav_fitness_gain float64  `toml:"max_fav_fitness_gain"`
+		Dfe_shape_del float64  `toml:"dfe_shape_del"`
+		Dfe_scale_del float64  `toml:"dfe_scale_del"`
+		Dfe_shape_fav float64  `toml:"dfe_shape_fav"`
+		Dfe_scale_fav float64  `toml:"dfe_scale_fav"`
+		Dfe_neutral_fraction float64  `toml:"dfe_neutral_fraction"`
+		Dfe_empirical_file string  `toml:"dfe_empirical_file"`
 		Fraction_recessive float64  `toml:"fraction_recessive"`
 		Recessive_hetero_expression float64  `toml:"recessive_hetero_expression"`
 		Dominant_hetero_expression float64  `toml:"dominant_hetero_expression"`
@@ -46,12 +54,16 @@ type Config struct {
 		Synergistic_epistasis bool  `toml:"synergistic_epistasis"`
 		Se_nonlinked_scaling float64  `toml:"se_nonlinked_scaling"`
 		Se_linked_scaling float64  `toml:"se_linked_scaling"`
-		Upload_mutations bool  `toml:"upload_mutations"`
 		Allow_back_mutn bool  `toml:"allow_back_mutn"`
-		Polygenic_beneficials bool  `polygenic_beneficials`
+		Polygenic_beneficials bool  `toml:"polygenic_beneficials"`
 		Polygenic_init string  `toml:"polygenic_init"`
 		Polygenic_target string  `toml:"polygenic_target"`
 		Polygenic_effect float64  `toml:"polygenic_effect"`
+		Mito_mutn_rate float64  `toml:"mito_mutn_rate"`
+		Mito_fitness_effect_model string  `toml:"mito_fitness_effect_model"`
+		Mito_dfe_shape float64  `toml:"mito_dfe_shape"`
+		Mito_dfe_scale float64  `toml:"mito_dfe_scale"`
+		Mito_fraction_neutral float64  `toml:"mito_fraction_neutral"`
 	}  `toml:"mutations"`
 	Selection struct {
 		Fraction_random_death float64  `toml:"fraction_random_death"`
@@ -66,14 +78,20 @@ type Config struct {
 		Num_offspring_model string  `toml:"num_offspring_model"`
 		Recombination_model uint32  `toml:"recombination_model"`
 		Fraction_self_fertilization float64  `toml:"fraction_self_fertilization"`
+		Separate_sexes bool  `toml:"separate_sexes"`
+		Sex_chromosome_system string  `toml:"sex_chromosome_system"`
 		Crossover_model string  `toml:"crossover_model"`
 		Mean_num_crossovers uint32  `toml:"mean_num_crossovers"`
+		Crossover_map_file string  `toml:"crossover_map_file"`
 		Haploid_chromosome_number uint32  `toml:"haploid_chromosome_number"`
 		Num_linkage_subunits uint32  `toml:"num_linkage_subunits"`
+		Chromosome_lbs string  `toml:"chromosome_lbs"`
+		Mito_linkage_subunits uint32  `toml:"mito_linkage_subunits"`
 		Num_contrasting_alleles uint32  `toml:"num_contrasting_alleles"`
 		Initial_allele_fitness_model string  `toml:"initial_allele_fitness_model"`
 		Initial_alleles_pop_frac float64  `toml:"initial_alleles_pop_frac"`
 		Initial_alleles_frequencies string  `toml:"initial_alleles_frequencies"`
+		Upload_mutations_file string  `toml:"upload_mutations_file"`
 		Max_total_fitness_increase float64  `toml:"max_total_fitness_increase"`
 		Pop_growth_model string  `toml:"pop_growth_model"`
 		Pop_growth_rate float64  `toml:"pop_growth_rate"`
@@ -93,9 +111,13 @@ type Config struct {
 		Migration_model int  `toml:"migration_model"`
 		Tribal_competition bool  `toml:"tribal_competition"`
 		Tribal_fission bool  `toml:"tribal_fission"`
+		Fission_pop_size uint32  `toml:"fission_pop_size"`
+		Fission_model string  `toml:"fission_model"`
+		Max_tribes uint32  `toml:"max_tribes"`
 		Tc_scaling_factor float64  `toml:"tc_scaling_factor"`
 		Group_heritability float64  `toml:"group_heritability"`
 		Social_bonus_factor float64  `toml:"social_bonus_factor"`
+		Min_tribe_size uint32  `toml:"min_tribe_size"`
 	}  `toml:"tribes"`
 	Computation struct {
 		Tracking_threshold float32  `toml:"tracking_threshold"`
@@ -106,8 +128,16 @@ type Config struct {
 		Files_to_output string  `toml:"files_to_output"`
 		Plot_allele_gens uint32  `toml:"plot_allele_gens"`
 		Omit_first_allele_bin bool  `toml:"omit_first_allele_bin"`
-		//Restart_case bool  `toml:"restart_case"`
-		//Restart_dump_number uint32  `toml:"restart_dump_number"`
+		Checkpoint_gens uint32  `toml:"checkpoint_gens"`
+		Track_pedigree bool  `toml:"track_pedigree"`
+		Prune_pedigree bool  `toml:"prune_pedigree"`
+		Track_tree_sequence bool  `toml:"track_tree_sequence"`
+		Track_fixation bool  `toml:"track_fixation"`
+		Num_trajectories uint32  `toml:"num_trajectories"`
+		Trajectory_gen uint32  `toml:"trajectory_gen"`
+		Trajectory_model string  `toml:"trajectory_model"`
+		Ld_sample_size uint32  `toml:"ld_sample_size"`
+		Ld_min_freq float64  `toml:"ld_min_freq"`
 		// Considered advanced options:
 		Num_threads uint32  `toml:"num_threads"`
 		Random_number_seed int64  `toml:"random_number_seed"`
@@ -134,6 +164,10 @@ type ComputedValues struct {
 	Gamma_fav float64
 	Del_scale float64		// not sure if i really need these
 	Fav_scale float64
+	Sites_per_lb float64		// the number of nucleotide sites in each LB of a haploid genome, used for the probability of a back mutation
+	Chromosome_lbs []uint32		// the number of LBs in each chromosome, from chromosome_lbs or num_linkage_subunits split evenly
+	Chromosome_offsets []uint32		// the index in the whole genome of the 1st LB of each chromosome
+	Fraction_neutral float64		// fraction_neutral plus, for fitness_effect_model=mixture, the dfe_neutral_fraction of the rest
 }
 
 var Computed *ComputedValues
@@ -162,6 +196,7 @@ func ReadFromFile(filename string) error {
 	FileMgrFactory(Cfg.Computation.Data_file_path, Cfg.Computation.Files_to_output)
 
 	if err := Cfg.validateAndAdjust(); err != nil { log.Fatalln(err) }
+	if err := readTribeOverrides(filename); err != nil { log.Fatalln(err) }		// this copies Cfg, so must come after all of the adjustments to it
 	Computed = ComputedValuesFactory()
 	return nil
 }
@@ -170,20 +205,47 @@ func ReadFromFile(filename string) error {
 func (c *Config) validateAndAdjust() error {
 	// Check and adjust certain config values
 	if c.Basic.Pop_size % 2 != 0 { return errors.New("basic.pop_size must be an even number") }
-	if (c.Population.Num_linkage_subunits % c.Population.Haploid_chromosome_number) != 0 { return errors.New("num_linkage_subunits must be an exact multiple of haploid_chromosome_number") }
+	if c.Population.Chromosome_lbs != "" {
+		chromosomeLbs, err := ParseChromosomeLbs(c.Population.Chromosome_lbs)
+		if err != nil { return err }
+		if uint32(len(chromosomeLbs)) != c.Population.Haploid_chromosome_number { return fmt.Errorf("chromosome_lbs has %d LB counts, but haploid_chromosome_number is %d", len(chromosomeLbs), c.Population.Haploid_chromosome_number) }
+		var numLbs uint32
+		for _, n := range chromosomeLbs { numLbs += n }
+		if numLbs != c.Population.Num_linkage_subunits {
+			log.Printf("Setting num_linkage_subunits=%d, the total of chromosome_lbs\n", numLbs)
+			c.Population.Num_linkage_subunits = numLbs
+		}
+	} else if (c.Population.Num_linkage_subunits % c.Population.Haploid_chromosome_number) != 0 { return errors.New("num_linkage_subunits must be an exact multiple of haploid_chromosome_number (or set chromosome_lbs)") }
+
+	if c.Population.Crossover_map_file != "" && (strings.ToLower(c.Population.Crossover_model) != "partial" || c.Population.Recombination_model != 3) { log.Printf("Warning: crossover_map_file is only used when crossover_model = partial and recombination_model = 3, so it will be ignored") }
+
+	if c.Population.Separate_sexes {
+		if c.Population.Recombination_model == 1 || c.Population.Fraction_self_fertilization > 0.0 { return errors.New("separate_sexes can not be used with recombination_model = 1 (clonal) or fraction_self_fertilization > 0.0") }
+		if system := strings.ToLower(c.Population.Sex_chromosome_system); system != "xy" && system != "zw" { return errors.New("sex_chromosome_system must be xy or zw") }
+		if c.Mutations.Dominant_hetero_expression <= 0.0 || c.Mutations.Recessive_hetero_expression <= 0.0 { return errors.New("separate_sexes requires dominant_hetero_expression and recessive_hetero_expression to be > 0.0, to calculate the hemizygous effects of the sex chromosome mutations") }
+		if c.Mutations.Multiplicative_weighting > 0.0 || c.Mutations.Synergistic_epistasis { return errors.New("separate_sexes can not be used with multiplicative_weighting > 0.0 or synergistic_epistasis = true, because the hemizygous effects of the sex chromosome mutations are only applied to the additive fitness") }
+	}
+
+	if c.Population.Mito_linkage_subunits > 0 {
+		if c.Mutations.Mito_mutn_rate < 0.0 { return errors.New("mito_mutn_rate must be >= 0.0") }
+		if c.Mutations.Mito_fraction_neutral < 0.0 || c.Mutations.Mito_fraction_neutral > 1.0 { return errors.New("mito_fraction_neutral must be between 0.0 and 1.0") }
+	}
 
 	c.Selection.Heritability = math.Max(1.e-20, c.Selection.Heritability)   // Limit the minimum value of heritability to be 10**-20
 
 	if c.Mutations.Max_fav_fitness_gain <= 0.0	{ return errors.New("max_fav_fitness_gain must be > 0.0") }
 
 	if c.Mutations.Allow_back_mutn && c.Computation.Tracking_threshold != 0.0 { return errors.New("can not set both allow_back_mutn and a non-zero tracking_threshold") }
-	if c.Mutations.Multiplicative_weighting != 0.0 && c.Computation.Tracking_threshold != 0.0 { return errors.New("setting tracking_threshold with multiplicative_weighting is not yet supported") }
+	if c.Mutations.Multiplicative_weighting < 0.0 || c.Mutations.Multiplicative_weighting > 1.0 { return errors.New("multiplicative_weighting must be between 0.0 and 1.0") }
 
-	if c.Computation.Tracking_threshold >= 1.0 && (FMgr.IsDir(ALLELE_BINS_DIRECTORY) || FMgr.IsDir(NORMALIZED_ALLELE_BINS_DIRECTORY) || FMgr.IsDir(DISTRIBUTION_DEL_DIRECTORY) || FMgr.IsDir(DISTRIBUTION_FAV_DIRECTORY)) {
-		return errors.New(ALLELE_BINS_DIRECTORY+", "+NORMALIZED_ALLELE_BINS_DIRECTORY+", "+DISTRIBUTION_DEL_DIRECTORY+", or "+DISTRIBUTION_FAV_DIRECTORY+" file output was requested, but no alleles can be plotted when tracking_threshold >= 1.0")
+	if c.Computation.Tracking_threshold >= 1.0 && (FMgr.IsDir(ALLELE_BINS_DIRECTORY) || FMgr.IsDir(NORMALIZED_ALLELE_BINS_DIRECTORY) || FMgr.IsDir(DISTRIBUTION_DEL_DIRECTORY) || FMgr.IsDir(DISTRIBUTION_FAV_DIRECTORY) || FMgr.IsDir(ALLELE_SFS_DIRECTORY)) {
+		return errors.New(ALLELE_BINS_DIRECTORY+", "+NORMALIZED_ALLELE_BINS_DIRECTORY+", "+DISTRIBUTION_DEL_DIRECTORY+", "+DISTRIBUTION_FAV_DIRECTORY+", or "+ALLELE_SFS_DIRECTORY+" file output was requested, but no alleles can be plotted when tracking_threshold >= 1.0")
 	}
-	if !FMgr.IsDir(ALLELE_BINS_DIRECTORY) && !FMgr.IsDir(NORMALIZED_ALLELE_BINS_DIRECTORY) && !FMgr.IsDir(DISTRIBUTION_DEL_DIRECTORY) && !FMgr.IsDir(DISTRIBUTION_FAV_DIRECTORY) {
-		log.Printf("Since %v, %v, %v, and %v were not requested to be written, setting tracking_threshold=9.0 to save space/time\n", ALLELE_BINS_DIRECTORY, NORMALIZED_ALLELE_BINS_DIRECTORY, DISTRIBUTION_DEL_DIRECTORY, DISTRIBUTION_FAV_DIRECTORY)
+	// The SFS is of genomes, but with count_duplicate_alleles=false each allele count is the number of individuals it occurs in
+	if FMgr.IsDir(ALLELE_SFS_DIRECTORY) && !c.Computation.Count_duplicate_alleles { return errors.New(ALLELE_SFS_DIRECTORY+" file output was requested, but it requires count_duplicate_alleles = true") }
+	// Back mutations need every mutation to be tracked, so do not turn off tracking in that case
+	if !c.Mutations.Allow_back_mutn && !FMgr.NeedsTrackedMutations() {
+		log.Printf("Since none of %v were requested to be written, setting tracking_threshold=9.0 to save space/time\n", strings.Join(TRACKED_MUTATION_OUTPUTS, ", "))
 		c.Computation.Tracking_threshold = 9.0
 	}
 	//if c.Computation.Track_neutrals && c.Computation.Tracking_threshold != 0.0 { c.Computation.Track_neutrals = false }
@@ -192,6 +254,14 @@ func (c *Config) validateAndAdjust() error {
 
 	if c.Tribes.Num_tribes <= 0 { return errors.New("num_tribes can not be <= 0") }
 
+	if c.Computation.Prune_pedigree && !c.Computation.Track_pedigree { return errors.New("prune_pedigree can only be set when track_pedigree = true") }
+	if c.Computation.Track_pedigree && !FMgr.IsFile(PEDIGREE_FILENAME) { log.Printf("Warning: track_pedigree = true, but %v is not in files_to_output, so the pedigree will not be written", PEDIGREE_FILENAME) }
+	if c.Computation.Track_fixation && !FMgr.IsFile(FIXATION_FILENAME) { log.Printf("Warning: track_fixation = true, but %v is not in files_to_output, so fixation will not be tracked", FIXATION_FILENAME) }
+	if c.Computation.Ld_sample_size > 0 && (c.Computation.Ld_min_freq <= 0.0 || c.Computation.Ld_min_freq > 0.5) { return errors.New("ld_min_freq must be > 0.0 and <= 0.5") }
+	if c.Computation.Ld_sample_size > 0 && !FMgr.IsDir(LD_DIRECTORY) { log.Printf("Warning: ld_sample_size > 0, but %v is not in files_to_output, so linkage disequilibrium will not be calculated", LD_DIRECTORY) }
+	if c.Computation.Num_trajectories > 0 && !FMgr.IsFile(TRAJECTORIES_FILENAME) { log.Printf("Warning: num_trajectories > 0, but %v is not in files_to_output, so the trajectories will not be written", TRAJECTORIES_FILENAME) }
+	if c.Computation.Track_tree_sequence && !FMgr.IsTreeSequenceOutput() { log.Printf("Warning: track_tree_sequence = true, but %v is not in files_to_output, so the tree sequence will not be written", TREE_SEQUENCE_DIRECTORY) }
+
 	return nil
 }
 
@@ -208,6 +278,19 @@ func ComputedValuesFactory() (c *ComputedValues) {
 
 	// Taken from mendel-f90/init.f90
 	c.Lb_modulo = (pow(2,30)-2) / float64(Cfg.Population.Num_linkage_subunits)
+	c.Sites_per_lb = Cfg.Mutations.Genome_size / float64(Cfg.Population.Num_linkage_subunits)
+	c.Fraction_neutral = Cfg.Mutations.Fraction_neutral
+	if strings.ToLower(Cfg.Mutations.Fitness_effect_model) == "mixture" { c.Fraction_neutral += (1.0 - Cfg.Mutations.Fraction_neutral) * Cfg.Mutations.Dfe_neutral_fraction }
+
+	// validateAndAdjust() already checked chromosome_lbs, or that num_linkage_subunits is a clean multiple of haploid_chromosome_number
+	if Cfg.Population.Chromosome_lbs != "" {
+		c.Chromosome_lbs, _ = ParseChromosomeLbs(Cfg.Population.Chromosome_lbs)
+	} else {
+		c.Chromosome_lbs = make([]uint32, Cfg.Population.Haploid_chromosome_number)
+		for i := range c.Chromosome_lbs { c.Chromosome_lbs[i] = Cfg.Population.Num_linkage_subunits / Cfg.Population.Haploid_chromosome_number }
+	}
+	c.Chromosome_offsets = make([]uint32, len(c.Chromosome_lbs))
+	for i := 1; i < len(c.Chromosome_lbs); i++ { c.Chromosome_offsets[i] = c.Chromosome_offsets[i-1] + c.Chromosome_lbs[i-1] }
 
 	c.Alpha_del = logn(Cfg.Mutations.Genome_size)		// this is the lower bound of how small (close to 0) a del mutn can be when using weibull
 	if Cfg.Mutations.Max_fav_fitness_gain > 0.0 {		// Alpha_fav is also the bound of how small a fav mutn fitness can be
@@ -296,3 +379,14 @@ func Verbose(level uint32, msg string, args ...interface{}) {
 func IsVerbose(level uint32) bool {
 	return Cfg.Computation.Verbosity >= level
 }
+
+
+// ParseChromosomeLbs parses chromosome_lbs, which is the number of LBs in each chromosome, like: 60, 55, 42
+func ParseChromosomeLbs(chromosomeLbs string) (lbs []uint32, err error) {
+	for _, numStr := range strings.Split(chromosomeLbs, ",") {
+		num, err := strconv.ParseUint(strings.TrimSpace(numStr), 10, 32)
+		if err != nil || num == 0 { return nil, fmt.Errorf("chromosome_lbs must be a list of LB counts > 0, like: 60, 55, 42. Bad LB count: %v", strings.TrimSpace(numStr)) }
+		lbs = append(lbs, uint32(num))
+	}
+	return
+}
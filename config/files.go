@@ -1,6 +1,7 @@
 package config
 
 import (
+	"io"
 	"os"
 	"regexp"
 	"log"
@@ -18,8 +19,30 @@ const (
 	NORMALIZED_ALLELE_BINS_DIRECTORY = "normalized-allele-bins/"
 	DISTRIBUTION_DEL_DIRECTORY = "allele-distribution-del/"
 	DISTRIBUTION_FAV_DIRECTORY = "allele-distribution-fav/"
+	ALLELE_SFS_DIRECTORY = "allele-sfs/"		// the site frequency spectrum and popgen summary stats each gen alleles are counted
+	PEDIGREE_FILENAME = "mendel.ped"		// the parents of every individual that survived selection, only available when track_pedigree=true
+	FIXATION_FILENAME = "mendel.fix"		// when each new mutation was lost or fixed, only available when track_fixation=true
+	POLYGENIC_FILENAME = "mendel.plg"		// the generations the polygenic target first appeared in and became fixed in each tribe, only available when polygenic_beneficials=true
+	MITO_FILENAME = "mendel.mit"		// the mean mutations and fitness effect of the mitochondrial genomes, only available when mito_linkage_subunits>0
+	TRAJECTORIES_FILENAME = "allele-trajectories.csv"		// the frequency of a sample of the alleles each gen, only available when num_trajectories>0
+	LD_DIRECTORY = "linkage-disequilibrium/"		// the linkage disequilibrium and haplotype stats of a sample of each tribe, only available when ld_sample_size>0
+	TREE_SEQUENCE_DIRECTORY = "tree-sequence/"		// the genealogy as tskit text tables, only available when track_tree_sequence=true
+	TREE_SEQUENCE_NODES = "nodes.txt"
+	TREE_SEQUENCE_EDGES = "edges.txt"
+	TREE_SEQUENCE_SITES = "sites.txt"
+	TREE_SEQUENCE_MUTATIONS = "mutations.txt"
 )
 
+// The tables in TREE_SEQUENCE_DIRECTORY. They are appended to every gen, so unlike the files of the other dirs they are opened at the beginning
+// and are in FileMgr.Files (keyed by dir/file), which also means they are cut back properly on restart.
+var TREE_SEQUENCE_TABLES = []string{TREE_SEQUENCE_NODES, TREE_SEQUENCE_EDGES, TREE_SEQUENCE_SITES, TREE_SEQUENCE_MUTATIONS}
+
+// The files/dirs whose output comes from the individual tracked mutations, so requesting any of them keeps tracking_threshold from being raised
+var TRACKED_MUTATION_OUTPUTS = []string{ALLELE_BINS_DIRECTORY, NORMALIZED_ALLELE_BINS_DIRECTORY, DISTRIBUTION_DEL_DIRECTORY, DISTRIBUTION_FAV_DIRECTORY, ALLELE_SFS_DIRECTORY, LD_DIRECTORY, TREE_SEQUENCE_DIRECTORY, FIXATION_FILENAME, TRAJECTORIES_FILENAME}
+
+// The files/dirs that cover the whole species, so they are only in the top dir, not in each tribe dir
+var SPECIES_ONLY_FILES = map[string]bool{PEDIGREE_FILENAME: true, TREE_SEQUENCE_DIRECTORY: true, FIXATION_FILENAME: true, POLYGENIC_FILENAME: true, TRAJECTORIES_FILENAME: true}
+
 // Not using buffered io because we need write to be flushed every generation to support restart
 //type FileElem struct {
 //	File *os.File
@@ -31,6 +54,7 @@ type FileMgr struct {
 	DataFilePath string                         // the directory in which output files should go
 	Files        map[string]*os.File            // key is filename, value is file descriptor (nil if not opened yet)
 	Dirs         map[string]map[string]*os.File // directories that hold a group of output files. Key is dir name, value is map in which key is filename, value is file descriptor (nil if not opened yet)
+	FileNames    []string                       // the files and dirs requested in files_to_output, so they can be opened for tribes created during the run
 }
 
 // FMgr is the singleton instance of FileMgr, created by FileMgrFactory.
@@ -43,12 +67,19 @@ func FileMgrFactory(dataFilePath, filesToOutput string) *FileMgr {
 	if filesToOutput == "" { return FMgr }
 
 	// Get the proper list of file names
-	var VALID_FILE_NAMES = map[string]int{HISTORY_FILENAME: 1, FITNESS_FILENAME: 1, ALLELE_BINS_DIRECTORY: 1, NORMALIZED_ALLELE_BINS_DIRECTORY: 1, DISTRIBUTION_DEL_DIRECTORY: 1, DISTRIBUTION_FAV_DIRECTORY: 1,}
+	var VALID_FILE_NAMES = map[string]int{HISTORY_FILENAME: 1, FITNESS_FILENAME: 1, ALLELE_BINS_DIRECTORY: 1, NORMALIZED_ALLELE_BINS_DIRECTORY: 1, DISTRIBUTION_DEL_DIRECTORY: 1, DISTRIBUTION_FAV_DIRECTORY: 1, ALLELE_SFS_DIRECTORY: 1,}
 	if CmdArgs.CreateZip || CmdArgs.SPCusername != "" {
 		// Add the files that are relevant only when -u is specified (and we aren't running in spc)
 		VALID_FILE_NAMES[TOML_FILENAME] = 1
 		VALID_FILE_NAMES[OUTPUT_FILENAME] = 1
 	}
+	if Cfg.Computation.Track_pedigree { VALID_FILE_NAMES[PEDIGREE_FILENAME] = 1 }
+	if Cfg.Computation.Track_tree_sequence { VALID_FILE_NAMES[TREE_SEQUENCE_DIRECTORY] = 1 }
+	if Cfg.Computation.Track_fixation { VALID_FILE_NAMES[FIXATION_FILENAME] = 1 }
+	if Cfg.Mutations.Polygenic_beneficials { VALID_FILE_NAMES[POLYGENIC_FILENAME] = 1 }
+	if Cfg.Computation.Num_trajectories > 0 { VALID_FILE_NAMES[TRAJECTORIES_FILENAME] = 1 }
+	if Cfg.Population.Mito_linkage_subunits > 0 { VALID_FILE_NAMES[MITO_FILENAME] = 1 }
+	if Cfg.Computation.Ld_sample_size > 0 { VALID_FILE_NAMES[LD_DIRECTORY] = 1 }
 	var fileNames []string
 	if filesToOutput == "*" {
 		// They want all files/dirs output
@@ -70,8 +101,9 @@ func FileMgrFactory(dataFilePath, filesToOutput string) *FileMgr {
 
 	// Open all of the files and put in the map
 	Verbose(5, "Opening files for writing: %v", fileNames)
+	FMgr.FileNames = fileNames
 	FMgr.openFiles(dataFilePath, "", fileNames)		// this is either for the single pop, or a summary of all the tribes
-	if Cfg.Tribes.Num_tribes > 1 {
+	if HasTribeFiles() {
 		for i:=1; i<=int(Cfg.Tribes.Num_tribes); i++ {
 			FMgr.openFiles(dataFilePath, TribeDir(uint32(i)), fileNames)
 		}
@@ -80,10 +112,54 @@ func FileMgrFactory(dataFilePath, filesToOutput string) *FileMgr {
 	return FMgr		// return the object created so we can chain other methods after this
 }
 
+
+// AddTribe opens the output files for a tribe that was created during the run (by tribal fission). If this is a restarted run, the files
+// are cut back to their size at the checkpoint (or to empty if the tribe did not exist yet), like RestoreFileSizes() does.
+func (fMgr *FileMgr) AddTribe(tribeNum uint32) {
+	if len(fMgr.FileNames) == 0 { return }
+	fMgr.openFiles(fMgr.DataFilePath, TribeDir(tribeNum), fMgr.FileNames)
+	if Restart == nil { return }
+	prefix := TribePrefix(tribeNum)
+	for name, file := range fMgr.Files {
+		if file == nil || !strings.HasPrefix(name, prefix) { continue }
+		size := Restart.FileSizes[name]
+		if err := file.Truncate(size); err != nil { log.Fatalf("Error truncating output file %v to its checkpoint size: %v", name, err) }
+		if _, err := file.Seek(size, io.SeekStart); err != nil { log.Fatalf("Error seeking in output file %v: %v", name, err) }
+	}
+	for dirName := range fMgr.Dirs {
+		if strings.HasPrefix(dirName, prefix) { fMgr.restoreDirFileSizes(dirName, Restart.FileSizes) }
+	}
+}
+
+// RemoveTribe closes the output files of a tribe that has stopped for good (it was split by tribal fission), and stops managing them.
+// The files themselves are kept.
+func (fMgr *FileMgr) RemoveTribe(tribeNum uint32) {
+	prefix := TribePrefix(tribeNum)
+	if prefix == "" { return }
+	for name, file := range fMgr.Files {
+		if !strings.HasPrefix(name, prefix) { continue }
+		if file != nil {
+			if err := file.Close(); err != nil { log.Printf("Error closing %v: %v", name, err) }
+		}
+		delete(fMgr.Files, name)
+	}
+	for dirName, dir := range fMgr.Dirs {
+		if !strings.HasPrefix(dirName, prefix) { continue }
+		for fileName, file := range dir {
+			if file == nil { continue }
+			if err := file.Close(); err != nil { log.Printf("Error closing %v: %v", fileName, err) }
+		}
+		delete(fMgr.Dirs, dirName)
+	}
+}
+
+// HasTribeFiles returns true if each tribe has its own output files in a tribe-N subdir, with a summary of the whole species in the top dir
+func HasTribeFiles() bool { return Cfg.Tribes.Num_tribes > 1 || Cfg.Tribes.Tribal_fission }
+
 func TribeDir(tribeNum uint32) string { return "tribe-"+strconv.Itoa(int(tribeNum)) }
 
 func TribePrefix(tribeNum uint32) string {
-	if Cfg.Tribes.Num_tribes <= 1 || tribeNum == 0 { return "" }
+	if !HasTribeFiles() || tribeNum == 0 { return "" }
 	return TribeDir(tribeNum) + "/"
 }
 
@@ -95,7 +171,16 @@ func (fMgr *FileMgr) openFiles(dataFilePath, subdir string, fileNames []string)
 		if err := os.MkdirAll(dataFilePath, 0755); err != nil { log.Fatalf("Error creating data_file_path %v: %v", dataFilePath, err) }
 	}
 	for _, f := range fileNames {
-		if strings.HasSuffix(f, "/") {
+		if subdir != "" && SPECIES_ONLY_FILES[f] { continue }
+		if f == TREE_SEQUENCE_DIRECTORY {
+			dirPath := dataFilePath + "/" + f
+			if err := os.MkdirAll(dirPath, 0755); err != nil { log.Fatalf("Error creating output directory %v: %v", dirPath, err) }
+			for _, table := range TREE_SEQUENCE_TABLES {
+				file, err := createOrReopen(dirPath + table)
+				if err != nil { log.Fatal(err) }
+				FMgr.Files[f+table] = file
+			}
+		} else if strings.HasSuffix(f, "/") {
 			// f is really a directory name, make sure it exists and then add it to our Dirs map. The actual files under that will get created later when GetDirFile() is called.
 			fDir := f
 			dirPath := dataFilePath + "/" + fDir
@@ -104,7 +189,7 @@ func (fMgr *FileMgr) openFiles(dataFilePath, subdir string, fileNames []string)
 		} else {
 			// f is a single file, open it
 			filePath := dataFilePath + "/" + f
-			file, err := os.Create(filePath)
+			file, err := createOrReopen(filePath)
 			if err != nil { log.Fatal(err) } 	// for now, if we can't open a file, just bail
 			//FMgr.Files[f] = FileElem{file, bufio.NewWriter(file)}
 			FMgr.Files[prefixDir(subdir,f)] = file
@@ -134,6 +219,21 @@ func (fMgr *FileMgr) IsFile(fileName string) bool {
 }
 
 
+// IsTreeSequenceOutput returns true if the tree sequence tables were specified in the files_to_output config parameter and are open.
+func (fMgr *FileMgr) IsTreeSequenceOutput() bool { return fMgr.IsFile(TREE_SEQUENCE_DIRECTORY+TREE_SEQUENCE_NODES) }
+
+
+// NeedsTrackedMutations returns true if any of the TRACKED_MUTATION_OUTPUTS was specified in the files_to_output config parameter.
+func (fMgr *FileMgr) NeedsTrackedMutations() bool {
+	for _, name := range TRACKED_MUTATION_OUTPUTS {
+		if name == TREE_SEQUENCE_DIRECTORY {
+			if fMgr.IsTreeSequenceOutput() { return true }
+		} else if fMgr.IsDir(name) || fMgr.IsFile(name) { return true }
+	}
+	return false
+}
+
+
 // IsDir returns true if the specified dir name was specified in the files_to_output config parameter.
 func (fMgr *FileMgr) IsDir(dirName string) bool {
 	if dir, ok := fMgr.Dirs[dirName]; ok && dir != nil { return true }
@@ -157,7 +257,7 @@ func (fMgr *FileMgr) GetDirFile(dirName, fileName string, tribeNum uint32) *os.F
 		} else {
 			// Not there yet, create the entry
 			filePath := FMgr.DataFilePath + "/" + TribePrefix(tribeNum)+dirName + fileName // dirName already has / at the end of it
-			file, err := os.Create(filePath)
+			file, err := createOrReopen(filePath)
 			if err != nil { log.Fatal(err) } 	// for now, if we can't open a file, just bail
 			dir[fileName] = file		// add it to our list so we can close it at the end
 			return file
@@ -1,23 +1,99 @@
 package config
 
-// Note: this file is not currently used
+import (
+	"github.com/genetic-algorithms/mendel-go/random"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
 
-import "log"
+// CHECKPOINT_FILENAME is the file (in data_file_path) that a checkpoint is written to every checkpoint_gens generations
+const CHECKPOINT_FILENAME = "mendel.chk"
 
+// RestartValues are the run-wide values (as opposed to the species/population contents) that are saved in a checkpoint
+// and needed to continue a run exactly where it left off.
 type RestartValues struct {
-	Gen_0 uint32
+	Gen_0 uint32                // the generation the checkpoint was written at, so the restarted run begins with the gen after this
+	MainRandState random.RandState // the state of the main random number generator. The others are re-seeded each gen, so they do not need to be saved.
+	NextUniqueInt uint64        // the next id utils.GlobalUniqueInt will hand out
+	LastIndivId uint64          // the last id given to an individual in the pedigree, when track_pedigree=true
+	NextNodeId, NextSiteId int64 // the next rows of the tree sequence node and site tables, when track_tree_sequence=true
+	FileSizes map[string]int64  // the size of each output file at the time of the checkpoint, keyed the same as FileMgr.Files, or by the FileMgr.Dirs key plus the file name for the files in the output dirs
 }
 
+// Restart holds the values read from the checkpoint file, or is nil if this is not a restarted run.
 var Restart *RestartValues
 
-// ReadRestartFile loads values from a restart file. If filename == "", it creates values appropriate for the non-restart case.
-// This is also the factory method for the RestartValues class and will store the created instance in this packages Restart var.
-func ReadRestartFile(filename string) error {
-	if filename == "" {
-		Restart = &RestartValues{Gen_0: 0}
-	} else {
-		// Load the restart file
-		log.Fatalln("Error: loading a restart file is not yet supported.")
+
+// GetFileSizes returns the current size of each of the output files, to be saved in a checkpoint.
+func (fMgr *FileMgr) GetFileSizes() map[string]int64 {
+	sizes := make(map[string]int64)
+	for name, file := range fMgr.Files {
+		if file == nil { continue }
+		// We always write to the end of the files, so the current offset is the size
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil { log.Fatalf("Error getting the size of output file %v: %v", name, err) }
+		sizes[name] = offset
+	}
+	// The files in the output dirs are each written once and closed, so get their sizes from the file system. Only include the files this run
+	// wrote (or a run it was restarted from), not others that happen to be in the dirs (e.g. from an earlier run with the same data_file_path).
+	for dirName, dir := range fMgr.Dirs {
+		for fileName := range dir {
+			filePath := fMgr.DataFilePath + "/" + dirName + fileName
+			info, err := os.Stat(filePath)
+			if os.IsNotExist(err) { continue }
+			if err != nil { log.Fatalf("Error getting the size of output file %v: %v", filePath, err) }
+			sizes[dirName+fileName] = info.Size()
+		}
+	}
+	if Restart != nil {
+		for name, size := range Restart.FileSizes {
+			dirName := name[:strings.LastIndex(name, "/")+1]
+			if _, ok := fMgr.Dirs[dirName]; !ok { continue }
+			if _, ok := sizes[name]; !ok { sizes[name] = size }
+		}
+	}
+	return sizes
+}
+
+
+// RestoreFileSizes truncates each output file back to the size it was when the checkpoint was written, so the output of the restarted
+// run continues exactly where the checkpoint left off. Files that did not exist at the checkpoint are truncated to empty, and the files in the
+// output dirs that did not exist are removed.
+func (fMgr *FileMgr) RestoreFileSizes(sizes map[string]int64) {
+	for name, file := range fMgr.Files {
+		if file == nil { continue }
+		size := sizes[name]
+		if err := file.Truncate(size); err != nil { log.Fatalf("Error truncating output file %v to its checkpoint size: %v", name, err) }
+		if _, err := file.Seek(size, io.SeekStart); err != nil { log.Fatalf("Error seeking in output file %v: %v", name, err) }
+	}
+	for dirName := range fMgr.Dirs { fMgr.restoreDirFileSizes(dirName, sizes) }
+}
+
+
+// restoreDirFileSizes truncates the files in this output dir back to their size at the checkpoint, and removes the files that were written after it.
+func (fMgr *FileMgr) restoreDirFileSizes(dirName string, sizes map[string]int64) {
+	dirPath := fMgr.DataFilePath + "/" + dirName
+	entries, err := os.ReadDir(dirPath)
+	if err != nil { log.Fatalf("Error reading output directory %v: %v", dirPath, err) }
+	for _, entry := range entries {
+		if entry.IsDir() { continue }
+		filePath := dirPath + entry.Name()
+		if size, ok := sizes[dirName+entry.Name()]; ok {
+			if err := os.Truncate(filePath, size); err != nil { log.Fatalf("Error truncating output file %v to its checkpoint size: %v", filePath, err) }
+		} else {
+			if err := os.Remove(filePath); err != nil { log.Fatalf("Error removing output file %v that was written after the checkpoint: %v", filePath, err) }
+		}
+	}
+}
+
+
+// createOrReopen opens an output file for writing. If we are restarting from a checkpoint the existing contents are kept,
+// because RestoreFileSizes() will cut them back to where the checkpoint was written.
+func createOrReopen(filePath string) (*os.File, error) {
+	if CmdArgs.RestartFile != "" {
+		return os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0644)
 	}
-	return nil
-}
\ No newline at end of file
+	return os.Create(filePath)
+}
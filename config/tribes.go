@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TribeOverrides are the config values that can be set differently for a tribe, in a [tribes.N] table of the input file (where N is the
+// tribe number). The fields are pointers so we can tell which ones were specified.
+type TribeOverrides struct {
+	Pop_size *uint32  `toml:"pop_size"`
+	Mutn_rate *float64  `toml:"mutn_rate"`
+	Selection_model *string  `toml:"selection_model"`
+	Heritability *float64  `toml:"heritability"`
+	Non_scaling_noise *float64  `toml:"non_scaling_noise"`
+	Pop_growth_model *string  `toml:"pop_growth_model"`
+	Pop_growth_rate *float64  `toml:"pop_growth_rate"`
+	Pop_growth_rate2 *float64  `toml:"pop_growth_rate2"`
+	Max_pop_size *uint32  `toml:"max_pop_size"`
+	Carrying_capacity *uint32  `toml:"carrying_capacity"`
+	Multiple_Bottlenecks *string  `toml:"multiple_bottlenecks"`
+	Bottleneck_generation *uint32  `toml:"bottleneck_generation"`
+	Bottleneck_pop_size *uint32  `toml:"bottleneck_pop_size"`
+	Num_bottleneck_generations *uint32  `toml:"num_bottleneck_generations"`
+}
+
+// TribeCfgs holds the resolved config of each tribe that has a [tribes.N] table in the input file, keyed by tribe number.
+// The other tribes use Cfg. It gets set in ReadFromFile().
+var TribeCfgs map[uint32]*Config
+
+
+// TribeConfig returns the config values that the specified tribe should use
+func TribeConfig(tribeNum uint32) *Config {
+	if c, ok := TribeCfgs[tribeNum]; ok { return c }
+	return Cfg
+}
+
+
+// readTribeOverrides reads the [tribes.N] tables from the input file and creates a config for each of those tribes that is a copy of
+// Cfg with the tribe's values applied on top of it.
+func readTribeOverrides(filename string) error {
+	TribeCfgs = make(map[uint32]*Config)
+	var tribesTables struct {
+		Tribes map[string]toml.Primitive  `toml:"tribes"`
+	}
+	md, err := toml.DecodeFile(filename, &tribesTables)
+	if err != nil { return err }
+	for key, prim := range tribesTables.Tribes {
+		tribeNum, err := strconv.ParseUint(key, 10, 32)
+		if err != nil { continue }		// this is one of the regular [tribes] values
+		if Cfg.Tribes.Homogenous_tribes { return errors.New("[tribes."+key+"] can only be specified when homogenous_tribes = false") }
+		if tribeNum < 1 || tribeNum > uint64(Cfg.Tribes.Num_tribes) { return fmt.Errorf("[tribes.%s] is not a valid tribe number, it must be 1 - %d", key, Cfg.Tribes.Num_tribes) }
+		var overrides TribeOverrides
+		if err := md.PrimitiveDecode(prim, &overrides); err != nil { return fmt.Errorf("error reading [tribes.%s]: %v", key, err) }
+		for _, k := range md.Undecoded() {
+			if len(k) > 2 && k[0] == "tribes" && k[1] == key { return fmt.Errorf("%v can not be specified per tribe", strings.Join(k, ".")) }
+		}
+
+		tribeCfg := *Cfg		// the sections are structs, so this copies all of the values
+		if overrides.Pop_size != nil { tribeCfg.Basic.Pop_size = *overrides.Pop_size }
+		if overrides.Mutn_rate != nil { tribeCfg.Mutations.Mutn_rate = *overrides.Mutn_rate }
+		if overrides.Selection_model != nil { tribeCfg.Selection.Selection_model = *overrides.Selection_model }
+		if overrides.Heritability != nil { tribeCfg.Selection.Heritability = math.Max(1.e-20, *overrides.Heritability) }
+		if overrides.Non_scaling_noise != nil { tribeCfg.Selection.Non_scaling_noise = *overrides.Non_scaling_noise }
+		if overrides.Pop_growth_model != nil { tribeCfg.Population.Pop_growth_model = *overrides.Pop_growth_model }
+		if overrides.Pop_growth_rate != nil { tribeCfg.Population.Pop_growth_rate = *overrides.Pop_growth_rate }
+		if overrides.Pop_growth_rate2 != nil { tribeCfg.Population.Pop_growth_rate2 = *overrides.Pop_growth_rate2 }
+		if overrides.Max_pop_size != nil { tribeCfg.Population.Max_pop_size = *overrides.Max_pop_size }
+		if overrides.Carrying_capacity != nil { tribeCfg.Population.Carrying_capacity = *overrides.Carrying_capacity }
+		if overrides.Multiple_Bottlenecks != nil { tribeCfg.Population.Multiple_Bottlenecks = *overrides.Multiple_Bottlenecks }
+		if overrides.Bottleneck_generation != nil { tribeCfg.Population.Bottleneck_generation = *overrides.Bottleneck_generation }
+		if overrides.Bottleneck_pop_size != nil { tribeCfg.Population.Bottleneck_pop_size = *overrides.Bottleneck_pop_size }
+		if overrides.Num_bottleneck_generations != nil { tribeCfg.Population.Num_bottleneck_generations = *overrides.Num_bottleneck_generations }
+		if tribeCfg.Basic.Pop_size % 2 != 0 { return errors.New("pop_size in [tribes."+key+"] must be an even number") }
+		TribeCfgs[uint32(tribeNum)] = &tribeCfg
+		Verbose(1, "Tribe %d parameters: pop_size=%d, mutn_rate=%v, selection_model=%s, heritability=%v, non_scaling_noise=%v, pop_growth_model=%s, pop_growth_rate=%v", tribeNum, tribeCfg.Basic.Pop_size, tribeCfg.Mutations.Mutn_rate, tribeCfg.Selection.Selection_model, tribeCfg.Selection.Heritability, tribeCfg.Selection.Non_scaling_noise, tribeCfg.Population.Pop_growth_model, tribeCfg.Population.Pop_growth_rate)
+	}
+	if len(TribeCfgs) == 0 && !Cfg.Tribes.Homogenous_tribes { log.Println("Warning: homogenous_tribes = false, but there are no [tribes.N] tables in the input file, so all of the tribes use the same values") }
+	return nil
+}
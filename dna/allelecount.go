@@ -0,0 +1,155 @@
+package dna
+
+import (
+	"log"
+	"sort"
+)
+
+// ALLELE_COUNT_BATCH_SIZE is the number of allele occurrences AlleleCount.Add() gathers before combining them into the counts
+const ALLELE_COUNT_BATCH_SIZE = 1 << 20
+
+// NUM_MUTATION_TYPES is the number of values of MutationType
+const NUM_MUTATION_TYPES = int(FAV_ALLELE) + 1
+
+// Allele is the number of occurrences of 1 mutation or initial allele
+type Allele struct {
+	Id            uint64
+	Count         uint32
+	FitnessEffect float32
+}
+
+// The number of occurrences of each allele (both mutations and initial alleles) in 1 generation. Each slice is sorted by the unique id of the mutation.
+// The occurrences are gathered with Add() and then sorted and combined into the slices in batches, so the memory used is proportional to the number of
+// distinct alleles (16 bytes each), not the number of occurrences. The counts of different parts of the population can be combined with Merge().
+// Note: this is defined here instead of population.go to avoid circular dependencies
+type AlleleCount struct {
+	DeleteriousDom         []Allele
+	DeleteriousRec         []Allele
+	Neutral         []Allele
+	FavorableDom         []Allele
+	FavorableRec         []Allele
+	DelInitialAlleles         []Allele
+	FavInitialAlleles         []Allele
+	SexChromosome map[uint64]bool		// the alleles on the sex chromosome, with true for the Y (or W), only filled in for the allele-sfs/ output when separate_sexes==true
+	pending [NUM_MUTATION_TYPES][]Allele		// the occurrences added since the last Compact(), in the order they were added
+	numPending int
+}
+
+func AlleleCountFactory() *AlleleCount {
+	return &AlleleCount{}
+}
+
+
+// counts returns the slice of counts for mutation type mType
+func (ac *AlleleCount) counts(mType MutationType) *[]Allele {
+	switch mType {
+	case DELETERIOUS_DOMINANT:
+		return &ac.DeleteriousDom
+	case DELETERIOUS_RECESSIVE:
+		return &ac.DeleteriousRec
+	case NEUTRAL:
+		return &ac.Neutral
+	case FAVORABLE_DOMINANT:
+		return &ac.FavorableDom
+	case FAVORABLE_RECESSIVE:
+		return &ac.FavorableRec
+	case DEL_ALLELE:
+		return &ac.DelInitialAlleles
+	case FAV_ALLELE:
+		return &ac.FavInitialAlleles
+	default:
+		log.Fatalf("Error: unknown Mutation type %v found when counting alleles.", mType)
+	}
+	return nil
+}
+
+
+// Add adds 1 occurrence of mutation m. Compact() must be called before reading the counts.
+func (ac *AlleleCount) Add(m Mutation) {
+	if int(m.Type) >= NUM_MUTATION_TYPES { log.Fatalf("Error: unknown Mutation type %v found when counting alleles.", m.Type) }
+	ac.pending[m.Type] = append(ac.pending[m.Type], Allele{Id: m.Id, Count: 1, FitnessEffect: m.FitnessEffect})
+	ac.numPending++
+	if ac.numPending >= ALLELE_COUNT_BATCH_SIZE { ac.Compact() }
+}
+
+
+// AddDistinct adds 1 occurrence of each of the alleles in other, no matter how many times it occurs there. This is used to count the number
+// of individuals an allele occurs in, instead of the number of copies of it.
+func (ac *AlleleCount) AddDistinct(other *AlleleCount) {
+	other.Compact()
+	for t := 0; t < NUM_MUTATION_TYPES; t++ {
+		for _, al := range *other.counts(MutationType(t)) {
+			ac.Add(Mutation{Id: al.Id, Type: MutationType(t), FitnessEffect: al.FitnessEffect})
+		}
+	}
+}
+
+
+// Compact sorts the occurrences added since the last call and combines them into the counts
+func (ac *AlleleCount) Compact() {
+	if ac.numPending == 0 { return }
+	for t := range ac.pending {
+		pending := ac.pending[t]
+		if len(pending) == 0 { continue }
+		sort.Slice(pending, func(i, j int) bool { return pending[i].Id < pending[j].Id })
+		// Combine the occurrences of the same id in place
+		last := 0
+		for i := 1; i < len(pending); i++ {
+			if pending[i].Id == pending[last].Id {
+				pending[last].Count += pending[i].Count
+			} else {
+				last++
+				pending[last] = pending[i]
+			}
+		}
+		counts := ac.counts(MutationType(t))
+		*counts = mergeAlleles(*counts, pending[:last+1])
+		ac.pending[t] = pending[:0]		// reuse the space for the next batch
+	}
+	ac.numPending = 0
+}
+
+
+// Merge adds all of the counts in other to these counts
+func (ac *AlleleCount) Merge(other *AlleleCount) {
+	ac.Compact()
+	other.Compact()
+	for t := 0; t < NUM_MUTATION_TYPES; t++ {
+		counts := ac.counts(MutationType(t))
+		*counts = mergeAlleles(*counts, *other.counts(MutationType(t)))
+	}
+	for id, onHetero := range other.SexChromosome { ac.MarkSexChromosome(id, onHetero) }
+}
+
+
+// MarkSexChromosome notes that the allele with this id is on the sex chromosome, and whether it is on the Y (or W), so the number of copies of
+// the chromosome it is on can be used for its frequency
+func (ac *AlleleCount) MarkSexChromosome(id uint64, onHetero bool) {
+	if ac.SexChromosome == nil { ac.SexChromosome = make(map[uint64]bool) }
+	ac.SexChromosome[id] = onHetero
+}
+
+
+// mergeAlleles returns the union of the 2 sorted slices, adding the counts of the alleles that are in both
+func mergeAlleles(a, b []Allele) []Allele {
+	if len(b) == 0 { return a }
+	if len(a) == 0 { return append([]Allele(nil), b...) }
+	merged := make([]Allele, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i].Id < b[j].Id:
+			merged = append(merged, a[i])
+			i++
+		case a[i].Id > b[j].Id:
+			merged = append(merged, b[j])
+			j++
+		default:
+			merged = append(merged, Allele{Id: a[i].Id, Count: a[i].Count + b[j].Count, FitnessEffect: a[i].FitnessEffect})
+			i++
+			j++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	return append(merged, b[j:]...)
+}
@@ -0,0 +1,119 @@
+package dna
+
+import "log"
+
+// The LB and chromosome fields are unexported, so these types and functions are used by the pop package to save the genomes of
+// the individuals in a checkpoint file and restore them again when a run is restarted.
+
+// LinkageBlockCheckpoint holds the contents of 1 LB in a form that can be written to a checkpoint file. The mutn slice is represented
+// by an index into a table of mutn arrays, so LBs that share a mutn array in the run will still share it after a restart.
+type LinkageBlockCheckpoint struct {
+	MutnIndex int32		// index into the MutnArrayTable, or -1 if this LB has no tracked mutations
+	FitnessEffect float32
+	MultFitnessEffect float32
+	DelFitnessEffect float32
+	NumDeleterious, NumFavorable, NumNeutrals, NumDelAllele, NumFavAllele uint16
+}
+
+// ChromosomeCheckpoint holds the contents of 1 chromosome in a form that can be written to a checkpoint file.
+type ChromosomeCheckpoint struct {
+	FitnessEffect float32
+	MultFitnessEffect float32
+	DelFitnessEffect, DelFitnessSqr float32
+	LinkageBlocks []LinkageBlockCheckpoint
+}
+
+// mutnArrayKey identifies a mutn slice by its backing array and length. Because of copy-on-write many LBs can share the same array.
+type mutnArrayKey struct {
+	first *Mutation
+	length int
+}
+
+// MutnArrayTable assigns an index to each distinct mutn array while writing a checkpoint, so each array is only written once.
+// While reading a checkpoint it holds the arrays that have been read so far.
+type MutnArrayTable struct {
+	indices map[mutnArrayKey]int32
+	arrays [][]Mutation
+	numWritten int		// the arrays before this index have already been returned by TakeNew()
+}
+
+func MutnArrayTableFactory() *MutnArrayTable {
+	return &MutnArrayTable{indices: make(map[mutnArrayKey]int32)}
+}
+
+// TakeNew returns the arrays that have been added to the table since the last time this was called. The caller should write
+// these to the checkpoint before the LBs that refer to them.
+func (t *MutnArrayTable) TakeNew() (newArrays [][]Mutation) {
+	newArrays = t.arrays[t.numWritten:]
+	t.numWritten = len(t.arrays)
+	return
+}
+
+// AddRead adds arrays that were read from a checkpoint file, in the same order they were returned from TakeNew() when it was written.
+func (t *MutnArrayTable) AddRead(arrays [][]Mutation) {
+	t.arrays = append(t.arrays, arrays...)
+}
+
+// index returns the index of this mutn slice in the table, adding it if necessary
+func (t *MutnArrayTable) index(mutn []Mutation) int32 {
+	if len(mutn) == 0 { return -1 }
+	key := mutnArrayKey{first: &mutn[0], length: len(mutn)}
+	if i, ok := t.indices[key]; ok { return i }
+	i := int32(len(t.arrays))
+	t.indices[key] = i
+	t.arrays = append(t.arrays, mutn)
+	return i
+}
+
+
+// Checkpoint returns the contents of this chromosome to be written to a checkpoint file
+func (c *Chromosome) Checkpoint(table *MutnArrayTable) (cp ChromosomeCheckpoint) {
+	cp.FitnessEffect = c.FitnessEffect
+	cp.MultFitnessEffect = c.MultFitnessEffect
+	cp.DelFitnessEffect = c.DelFitnessEffect
+	cp.DelFitnessSqr = c.DelFitnessSqr
+	cp.LinkageBlocks = make([]LinkageBlockCheckpoint, len(c.LinkageBlocks))
+	for i := range c.LinkageBlocks {
+		lb := &c.LinkageBlocks[i]
+		cp.LinkageBlocks[i] = LinkageBlockCheckpoint{
+			MutnIndex: table.index(lb.mutn),
+			FitnessEffect: lb.fitnessEffect,
+			MultFitnessEffect: lb.multFitnessEffect,
+			DelFitnessEffect: lb.delFitnessEffect,
+			NumDeleterious: lb.numDeleterious,
+			NumFavorable: lb.numFavorable,
+			NumNeutrals: lb.numNeutrals,
+			NumDelAllele: lb.numDelAllele,
+			NumFavAllele: lb.numFavAllele,
+		}
+	}
+	return
+}
+
+
+// Restore sets the contents of this chromosome from what was read from a checkpoint file
+func (c *Chromosome) Restore(cp *ChromosomeCheckpoint, table *MutnArrayTable) {
+	if len(cp.LinkageBlocks) != len(c.LinkageBlocks) { log.Fatalf("Error: checkpoint chromosome has %d linkage blocks, but this run has %d", len(cp.LinkageBlocks), len(c.LinkageBlocks)) }
+	c.FitnessEffect = cp.FitnessEffect
+	c.MultFitnessEffect = cp.MultFitnessEffect
+	c.DelFitnessEffect = cp.DelFitnessEffect
+	c.DelFitnessSqr = cp.DelFitnessSqr
+	for i := range cp.LinkageBlocks {
+		lbCp := &cp.LinkageBlocks[i]
+		lb := &c.LinkageBlocks[i]
+		if lbCp.MutnIndex >= 0 {
+			if int(lbCp.MutnIndex) >= len(table.arrays) { log.Fatalf("Error: checkpoint file refers to mutation array %d, but only %d have been read", lbCp.MutnIndex, len(table.arrays)) }
+			mutn := table.arrays[lbCp.MutnIndex]
+			lb.mutn = mutn[:len(mutn):len(mutn)]
+			lb.IsPtrToParent = true		// the array may be shared with other LBs, so copy it before adding to it
+		}
+		lb.fitnessEffect = lbCp.FitnessEffect
+		lb.multFitnessEffect = lbCp.MultFitnessEffect
+		lb.delFitnessEffect = lbCp.DelFitnessEffect
+		lb.numDeleterious = lbCp.NumDeleterious
+		lb.numFavorable = lbCp.NumFavorable
+		lb.numNeutrals = lbCp.NumNeutrals
+		lb.numDelAllele = lbCp.NumDelAllele
+		lb.numFavAllele = lbCp.NumFavAllele
+	}
+}
@@ -2,6 +2,7 @@ package dna
 
 import (
 	"math/rand"
+	"sort"
 	"github.com/genetic-algorithms/mendel-go/config"
 	"github.com/genetic-algorithms/mendel-go/utils"
 )
@@ -11,6 +12,16 @@ import (
 type Chromosome struct {
 	LinkageBlocks []LinkageBlock
 	FitnessEffect float32	// keep a running total of the fitness contribution of this LB to the chromosome
+	MultFitnessEffect float32	// keep a running multiplicative combination of the fitness contribution of the LBs, in the same form as LinkageBlock.multFitnessEffect
+	DelFitnessEffect float32	// keep a running total of the deleterious mutation fitness effects of the LBs, for synergistic epistasis
+	DelFitnessSqr float32	// keep a running total of the square of each LB's deleterious mutation fitness effect, for the linked part of synergistic epistasis
+	LbSources []LbSource	// which parent chromosome each section of LBs was copied from, only recorded when Mdl.RecordLbSources is true
+}
+
+// LbSource is the beginning of a section of LBs that were all copied from the same parent chromosome. The section extends to the next LbSource (or the end).
+type LbSource struct {
+	BegIndex int
+	From *Chromosome
 }
 
 
@@ -26,6 +37,9 @@ func (c *Chromosome) ChromosomeFactory(lBsPerChromosome uint32) {
 // In the other Chromosome methods we can tell if the recycled chromosome exists because the ptr to it will be non-nil.
 func (c *Chromosome) Reinitialize() {
 	c.FitnessEffect = 0.0
+	c.MultFitnessEffect = 0.0
+	c.DelFitnessEffect = 0.0
+	c.DelFitnessSqr = 0.0
 }
 
 
@@ -49,13 +63,35 @@ func (c *Chromosome) Copy(offspr *Chromosome) (deleterious, neutral, favorable,
 func (c *Chromosome) TransferLB(newChr *Chromosome, lbIndex int) (uint32, uint32, uint32, uint32, uint32) {
 	newChr.LinkageBlocks[lbIndex] = c.LinkageBlocks[lbIndex]    // this copies all of the LB struct fields, including the slice reference (but not the mutn array that backs the slice)
 	newChr.LinkageBlocks[lbIndex].IsPtrToParent = true            // indicate we are still using the parents mutn array, so we will copy it later if we have to add a mutation
+	if Mdl.RecordLbSources { newChr.recordLbSource(c, lbIndex) }
 
 	// Housekeeping for the new chromo
 	newChr.FitnessEffect += newChr.LinkageBlocks[lbIndex].SumFitness()
+	newChr.MultFitnessEffect = MultCombine(newChr.MultFitnessEffect, newChr.LinkageBlocks[lbIndex].MultFitness())
+	delFitness := newChr.LinkageBlocks[lbIndex].DelFitness()
+	newChr.DelFitnessEffect += delFitness
+	newChr.DelFitnessSqr += delFitness * delFitness
 	return newChr.LinkageBlocks[lbIndex].GetMutationStats()
 }
 
 
+// recordLbSource notes that the LB at lbIndex was copied from chromosome from. All of the crossover models transfer the LBs in order, so a new section
+// only starts when the parent chromosome changes.
+func (c *Chromosome) recordLbSource(from *Chromosome, lbIndex int) {
+	if lbIndex == 0 { c.LbSources = c.LbSources[:0] }		// this may be a recycled chromosome
+	if len(c.LbSources) > 0 && c.LbSources[len(c.LbSources)-1].From == from { return }
+	c.LbSources = append(c.LbSources, LbSource{BegIndex: lbIndex, From: from})
+}
+
+
+// LocateLb returns the chromosome index and the index within that chromosome of the LB at index lb of the whole genome
+func LocateLb(lb int) (chr, lbInChr int) {
+	offsets := config.Computed.Chromosome_offsets
+	chr = sort.Search(len(offsets), func(i int) bool { return int(offsets[i]) > lb }) - 1		// the last chromosome that starts at or before lb
+	return chr, lb - int(offsets[chr])
+}
+
+
 // GetNumLinkages returns the number of linkage blocks from each parent (we assume they always have the same number of LBs from each parent)
 func (c *Chromosome) GetNumLinkages() uint32 { return uint32(len(c.LinkageBlocks)) }
 
@@ -71,10 +107,10 @@ func (dad *Chromosome) Meiosis(mom *Chromosome, offspr *Chromosome, lBsPerChromo
 
 
 // The different implementations of LB crossover to another chromosome during meiosis
-type CrossoverType func(dad *Chromosome, mom *Chromosome, offspr *Chromosome, lBsPerChromosome uint32, uniformRandom *rand.Rand) (uint32, uint32, uint32, uint32, uint32)
+type CrossoverType func(dad *Chromosome, mom *Chromosome, offspr *Chromosome, chrIndex uint32, lBsPerChromosome uint32, uniformRandom *rand.Rand) (uint32, uint32, uint32, uint32, uint32)
 
 // Create the gamete from all of dad's chromosomes or all of mom's chromosomes. Returns the number of each kind of mutation in the new chromosome.
-func NoCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, _ uint32, uniformRandom *rand.Rand) (uint32, uint32, uint32, uint32, uint32) {
+func NoCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, _ uint32, _ uint32, uniformRandom *rand.Rand) (uint32, uint32, uint32, uint32, uint32) {
 	// Create the chromosome (if necessary) and copy all of the LBs from the one or the other
 	if uniformRandom.Intn(2) == 0 {
 		return dad.Copy(offspr)
@@ -85,7 +121,7 @@ func NoCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, _ uint32,
 
 
 // Create the gamete from dad and mom's chromosomes by randomly choosing each LB from either. Returns the number of each kind of mutation in the new chromosome.
-func FullCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, _ uint32, uniformRandom *rand.Rand) (deleterious, neutral, favorable, delAllele, favAllele uint32) {
+func FullCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, _ uint32, _ uint32, uniformRandom *rand.Rand) (deleterious, neutral, favorable, delAllele, favAllele uint32) {
 	// Each LB can come from either dad or mom
 	for lbIndex :=0; lbIndex <int(dad.GetNumLinkages()); lbIndex++ {
 		var delet, neut, fav, delAll, favAll uint32
@@ -105,7 +141,7 @@ func FullCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, _ uint3
 
 
 // Create the gamete from dad and mom's chromosomes by randomly choosing sections of LBs from either. Returns the number of each kind of mutation in the new chromosome.
-func PartialCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, lBsPerChromosome uint32, uniformRandom *rand.Rand) (deleterious, neutral, favorable, delAllele, favAllele uint32) {
+func PartialCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, chrIndex uint32, lBsPerChromosome uint32, uniformRandom *rand.Rand) (deleterious, neutral, favorable, delAllele, favAllele uint32) {
 	// Algorithm: choose random sizes for <numCrossovers> LB sections for primary and <numCrossovers> LB sections for secondary
 
 	// Choose if dad or mom is the primary chromosome
@@ -118,6 +154,11 @@ func PartialCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, lBsP
 		secondary = dad
 	}
 
+	if Mdl.CrossoverPoints != nil {
+		deleterious, neutral, favorable, delAllele, favAllele = mappedCrossover(primary, secondary, offspr, chrIndex, lBsPerChromosome, uniformRandom)
+		return
+	}
+
 	// Mean_num_crossovers is the average number of crossovers for the chromosome PAIR during Meiosis 1 Metaphase. So for each chromosome (chromotid)
 	// the mean = (Mean_num_crossovers / 2). When determining the actual num crossovers for this instance, we get a random number in the
 	// range: 0 - (2 * mean + 1) which is (2 * Mean_num_crossovers / 2 + 1) which is (Mean_num_crossovers + 1)
@@ -175,13 +216,75 @@ func PartialCrossover(dad *Chromosome, mom *Chromosome, offspr *Chromosome, lBsP
 }
 
 
-// AppendMutation creates and adds a mutations to the LB specified. Returns the type of mutation added.
-func (c *Chromosome) AppendMutation(lbInChr int, mutId uint64, uniformRandom *rand.Rand) MutationType {
+// mappedCrossover is PartialCrossover when crossover_map_file is set: the crossover points are chosen from the chromosome's weights, and the
+// chromosome switches between the primary and secondary parent at each of them.
+func mappedCrossover(primary *Chromosome, secondary *Chromosome, offspr *Chromosome, chrIndex uint32, lBsPerChromosome uint32, uniformRandom *rand.Rand) (deleterious, neutral, favorable, delAllele, favAllele uint32) {
+	// Like PartialCrossover, the num crossovers in this 1 chromosome is in the range 0 - (Mean_num_crossovers + 1), but the mean is scaled by the chromosome's total weight
+	maxCrossovers := utils.RoundInt(float64(config.Cfg.Population.Mean_num_crossovers) * Mdl.CrossoverScales[chrIndex])
+	numCrossovers := uniformRandom.Intn(maxCrossovers + 1)
+	points := Mdl.CrossoverPoints[chrIndex]
+	if numCrossovers <= 0 || points == nil {
+		deleterious, neutral, favorable, delAllele, favAllele = primary.Copy(offspr)
+		return
+	}
+	crossovers := make([]int, numCrossovers)
+	for i := range crossovers { crossovers[i] = points.Sample(uniformRandom) + 1 }		// the index of the 1st LB after the crossover
+	sort.Ints(crossovers)
+
+	parent := primary
+	next := 0		// the next crossover
+	for lbIndex := 0; lbIndex < int(lBsPerChromosome); lbIndex++ {
+		// 2 crossovers at the same point cancel each other out
+		for ; next < len(crossovers) && crossovers[next] == lbIndex; next++ {
+			if parent == primary {
+				parent = secondary
+			} else {
+				parent = primary
+			}
+		}
+		delet, neut, fav, delAll, favAll := parent.TransferLB(offspr, lbIndex)
+		deleterious += delet
+		neutral += neut
+		favorable += fav
+		delAllele += delAll
+		favAllele += favAll
+	}
+	return
+}
+
+
+// AppendMutation creates and adds a mutations to the LB specified. Returns the type of mutation added, or if it was a back mutation
+// (see LinkageBlock.AppendMutation()) the type of mutation that was reverted. If trackAll is true the mutation is tracked regardless of tracking_threshold.
+func (c *Chromosome) AppendMutation(lbInChr int, mutId uint64, trackAll bool, uniformRandom *rand.Rand) (MutationType, bool) {
 	// Note: to try to save time, we could accumulate the chromosome fitness as we go, but doing so would bypass the LB method
 	//		of calculating its own fitness, so we won't do that.
-	mType, fitnessEffect := c.LinkageBlocks[lbInChr].AppendMutation(mutId, uniformRandom)
+	lb := &c.LinkageBlocks[lbInChr]
+	oldDelFitness := lb.DelFitness()
+	mType, fitnessEffect, reverted := lb.AppendMutation(mutId, trackAll, uniformRandom)
 	c.FitnessEffect += fitnessEffect
-	return mType
+	if reverted {
+		c.MultFitnessEffect = MultRemove(c.MultFitnessEffect, -fitnessEffect)
+	} else {
+		c.MultFitnessEffect = MultCombine(c.MultFitnessEffect, fitnessEffect)
+	}
+	newDelFitness := lb.DelFitness()
+	c.DelFitnessEffect += newDelFitness - oldDelFitness
+	c.DelFitnessSqr += newDelFitness * newDelFitness - oldDelFitness * oldDelFitness
+	return mType, reverted
+}
+
+
+// AddMutation adds an already created mutation to the LB specified, and updates the chromosome's running totals
+func (c *Chromosome) AddMutation(lbInChr int, mutn Mutation) {
+	lb := &c.LinkageBlocks[lbInChr]
+	oldDelFitness := lb.DelFitness()
+	lb.AddMutation(mutn)
+	if mutn.Type == NEUTRAL { return }
+	c.FitnessEffect += mutn.FitnessEffect
+	c.MultFitnessEffect = MultCombine(c.MultFitnessEffect, mutn.FitnessEffect)
+	newDelFitness := lb.DelFitness()
+	c.DelFitnessEffect += newDelFitness - oldDelFitness
+	c.DelFitnessSqr += newDelFitness * newDelFitness - oldDelFitness * oldDelFitness
 }
 
 
@@ -190,6 +293,8 @@ func ChrAppendInitialContrastingAlleles(chr1, chr2 *Chromosome, lbIndex int, uni
 	fitnessEffect1, fitnessEffect2 := AppendInitialContrastingAlleles(&chr1.LinkageBlocks[lbIndex], &chr2.LinkageBlocks[lbIndex], uniqueInt, uniformRandom)
 	chr1.FitnessEffect += fitnessEffect1
 	chr2.FitnessEffect += fitnessEffect2
+	chr1.MultFitnessEffect = MultCombine(chr1.MultFitnessEffect, fitnessEffect1)
+	chr2.MultFitnessEffect = MultCombine(chr2.MultFitnessEffect, fitnessEffect2)
 }
 
 // ChrAppendInitialAllelePair adds an initial contrasting allele pair to 2 LBs on 2 chromosomes (favorable to 1, deleterious to the other).
@@ -197,6 +302,8 @@ func ChrAppendInitialAllelePair(chr1, chr2 *Chromosome, lbIndex int, favMutn, de
 	AppendInitialAllelePair(&chr1.LinkageBlocks[lbIndex], &chr2.LinkageBlocks[lbIndex], favMutn, delMutn)
 	chr1.FitnessEffect += favMutn.FitnessEffect
 	chr2.FitnessEffect += delMutn.FitnessEffect
+	chr1.MultFitnessEffect = MultCombine(chr1.MultFitnessEffect, favMutn.FitnessEffect)
+	chr2.MultFitnessEffect = MultCombine(chr2.MultFitnessEffect, delMutn.FitnessEffect)
 }
 
 // SumFitness combines the fitness effect of all of its LBs in the additive method
@@ -206,7 +313,24 @@ func (c *Chromosome) SumFitness() float64 {
 }
 
 
+// HemiSumFitness combines the hemizygous fitness effect of all of its LBs in the additive method. This is used instead of SumFitness() for the
+// sex chromosomes of the heterogametic sex (e.g. XY males), which each have only 1 copy of their LBs. It is computed from the mutations,
+// so it relies on every mutation on the sex chromosome being tracked (see the trackAll arg of AppendMutation()).
+func (c *Chromosome) HemiSumFitness() (fitness float64) {
+	for i := range c.LinkageBlocks {
+		for _, m := range c.LinkageBlocks[i].GetMutations() {
+			fitness += float64(HemizygousEffect(m.Type, m.FitnessEffect))
+		}
+	}
+	return
+}
+
+
+// MultFitness combines the fitness effect of all of its LBs in the multiplicative method. The result is (product of (1+effect)) - 1.
+func (c *Chromosome) MultFitness() float64 { return float64(c.MultFitnessEffect) }
+
+
 // CountAlleles adds all of this chromosome's alleles (both mutations and initial alleles) to the given struct
-func (c *Chromosome) CountAlleles(allelesForThisIndiv *AlleleCount) {
-	for _, lb := range c.LinkageBlocks { lb.CountAlleles(allelesForThisIndiv) }
+func (c *Chromosome) CountAlleles(alleles *AlleleCount) {
+	for _, lb := range c.LinkageBlocks { lb.CountAlleles(alleles) }
 }
@@ -0,0 +1,143 @@
+package dna
+
+import (
+	"bufio"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// These are the distributions of fitness effects (DFE) that are parameterized by dfe_shape_* and dfe_scale_*, as in scipy.stats:
+//   gamma: shape is the gamma shape (k) and scale is the gamma scale (theta), so the mean effect is shape*scale
+//   lognormal: shape is the standard deviation (sigma) of the log of the effect and scale is the median effect (exp(mu))
+//   exponential: scale is the mean effect, and shape is not used
+//   mixture: a fraction dfe_neutral_fraction of the non-neutral mutations are made neutral by CalcMutationType() (see
+//            ComputedValues.Fraction_neutral), and the rest are drawn from the gamma distribution
+// The draws are the size of the effect, so deleterious ones are negated. Deleterious effects are capped at 1.0 (lethal), and favorable effects
+// are capped at max_fav_fitness_gain.
+
+// CalcGammaDelMutationFitness draws the effect of a deleterious mutation from a gamma distribution
+func CalcGammaDelMutationFitness(uniformRandom *rand.Rand) float64 {
+	return -math.Min(GammaRand(uniformRandom, config.Cfg.Mutations.Dfe_shape_del, config.Cfg.Mutations.Dfe_scale_del), 1.0)
+}
+
+func CalcGammaFavMutationFitness(uniformRandom *rand.Rand) float64 {
+	return math.Min(GammaRand(uniformRandom, config.Cfg.Mutations.Dfe_shape_fav, config.Cfg.Mutations.Dfe_scale_fav), config.Cfg.Mutations.Max_fav_fitness_gain)
+}
+
+// CalcLognormalDelMutationFitness draws the effect of a deleterious mutation from a lognormal distribution
+func CalcLognormalDelMutationFitness(uniformRandom *rand.Rand) float64 {
+	return -math.Min(config.Cfg.Mutations.Dfe_scale_del * math.Exp(config.Cfg.Mutations.Dfe_shape_del * uniformRandom.NormFloat64()), 1.0)
+}
+
+func CalcLognormalFavMutationFitness(uniformRandom *rand.Rand) float64 {
+	return math.Min(config.Cfg.Mutations.Dfe_scale_fav * math.Exp(config.Cfg.Mutations.Dfe_shape_fav * uniformRandom.NormFloat64()), config.Cfg.Mutations.Max_fav_fitness_gain)
+}
+
+// CalcExponentialDelMutationFitness draws the effect of a deleterious mutation from an exponential distribution
+func CalcExponentialDelMutationFitness(uniformRandom *rand.Rand) float64 {
+	return -math.Min(config.Cfg.Mutations.Dfe_scale_del * uniformRandom.ExpFloat64(), 1.0)
+}
+
+func CalcExponentialFavMutationFitness(uniformRandom *rand.Rand) float64 {
+	return math.Min(config.Cfg.Mutations.Dfe_scale_fav * uniformRandom.ExpFloat64(), config.Cfg.Mutations.Max_fav_fitness_gain)
+}
+
+// CalcEmpiricalDelMutationFitness draws the effect of a deleterious mutation from the deleterious effects in dfe_empirical_file
+func CalcEmpiricalDelMutationFitness(uniformRandom *rand.Rand) float64 { return -empiricalDfe[0].draw(uniformRandom) }
+
+func CalcEmpiricalFavMutationFitness(uniformRandom *rand.Rand) float64 { return empiricalDfe[1].draw(uniformRandom) }
+
+
+// GammaRand returns a random number from the gamma distribution with the given shape and scale, using the method of Marsaglia and Tsang (2000)
+func GammaRand(uniformRandom *rand.Rand, shape, scale float64) float64 {
+	if shape < 1.0 {
+		// Boost the shape above 1 and then scale the result back down: if X ~ gamma(shape+1) and U ~ uniform(0,1), X * U^(1/shape) ~ gamma(shape)
+		u := uniformRandom.Float64()
+		for u == 0.0 { u = uniformRandom.Float64() }
+		return GammaRand(uniformRandom, shape + 1.0, scale) * math.Pow(u, 1.0 / shape)
+	}
+	d := shape - 1.0/3.0
+	c := 1.0 / math.Sqrt(9.0 * d)
+	for {
+		x := uniformRandom.NormFloat64()
+		v := 1.0 + c * x
+		if v <= 0.0 { continue }
+		v = v * v * v
+		u := uniformRandom.Float64()
+		if u < 1.0 - 0.0331 * x*x*x*x { return d * v * scale }
+		if u > 0.0 && math.Log(u) < 0.5 * x*x + d * (1.0 - v + math.Log(v)) { return d * v * scale }
+	}
+}
+
+
+// EmpiricalDfe is the table of effect sizes of 1 type (deleterious or favorable) from dfe_empirical_file
+type EmpiricalDfe struct {
+	Effects []float64
+	CumWeights []float64		// the running total of the weights, so an effect can be chosen with a binary search
+}
+
+// empiricalDfe is the deleterious (0) and favorable (1) tables, set by SetModels() when fitness_effect_model=empirical
+var empiricalDfe [2]*EmpiricalDfe
+
+
+// draw returns a random effect from the table, with the probability of each effect proportional to its weight
+func (e *EmpiricalDfe) draw(uniformRandom *rand.Rand) float64 {
+	if e == nil || len(e.Effects) == 0 { log.Fatalln("System Error: an empirical fitness effect was needed for a type of mutation that has none in dfe_empirical_file") }
+	r := uniformRandom.Float64() * e.CumWeights[len(e.CumWeights)-1]
+	i := sort.SearchFloat64s(e.CumWeights, r)
+	if i < len(e.CumWeights) && e.CumWeights[i] == r { i++ }		// r is at the upper end of element i, so it belongs to the next one
+	if i >= len(e.Effects) { i = len(e.Effects) - 1 }
+	return e.Effects[i]
+}
+
+
+// ReadEmpiricalDfe reads the table of effect sizes for fitness_effect_model=empirical. Each non-blank, non-comment (#) line of the file is:
+//   type  effect  [weight]
+// where type is del or fav, effect is the size of the fitness effect (>= 0.0, deleterious effects are negated when used, and must be <= 1.0, and
+// favorable effects must be <= max_fav_fitness_gain), and weight is the relative probability of the effect (default 1.0).
+func ReadEmpiricalDfe(fileName string) (del, fav *EmpiricalDfe) {
+	file, err := os.Open(fileName)
+	if err != nil { log.Fatalf("Error opening dfe_empirical_file %v: %v", fileName, err) }
+	defer file.Close()
+
+	del, fav = &EmpiricalDfe{}, &EmpiricalDfe{}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") { continue }
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 { log.Fatalf("Error: line %d of %v has %d fields instead of: type effect [weight]", lineNum, fileName, len(fields)) }
+
+		var table *EmpiricalDfe
+		switch strings.ToLower(fields[0]) {
+		case "del":
+			table = del
+		case "fav":
+			table = fav
+		default:
+			log.Fatalf("Error: invalid type %v on line %d of %v, it must be del or fav", fields[0], lineNum, fileName)
+		}
+		effect, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || effect < 0.0 { log.Fatalf("Error: invalid effect %v on line %d of %v, it must be >= 0.0", fields[1], lineNum, fileName) }
+		if table == del && effect > 1.0 { log.Fatalf("Error: invalid effect %v on line %d of %v, a deleterious effect must be <= 1.0", fields[1], lineNum, fileName) }
+		if table == fav && effect > config.Cfg.Mutations.Max_fav_fitness_gain { log.Fatalf("Error: invalid effect %v on line %d of %v, a favorable effect must be <= max_fav_fitness_gain (%v)", fields[1], lineNum, fileName, config.Cfg.Mutations.Max_fav_fitness_gain) }
+		weight := 1.0
+		if len(fields) == 3 {
+			if weight, err = strconv.ParseFloat(fields[2], 64); err != nil || weight <= 0.0 { log.Fatalf("Error: invalid weight %v on line %d of %v, it must be > 0.0", fields[2], lineNum, fileName) }
+		}
+		var total float64
+		if len(table.CumWeights) > 0 { total = table.CumWeights[len(table.CumWeights)-1] }
+		table.Effects = append(table.Effects, effect)
+		table.CumWeights = append(table.CumWeights, total + weight)
+	}
+	if err := scanner.Err(); err != nil { log.Fatalf("Error reading dfe_empirical_file %v: %v", fileName, err) }
+	return
+}
@@ -0,0 +1,96 @@
+package dna
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+)
+
+// dfeTestConfig reads the defaults plus the given [mutations] settings and sets the dna models from them
+func dfeTestConfig(t *testing.T, mutations string) {
+	inputFile := filepath.Join(t.TempDir(), "dfe.ini")
+	if err := os.WriteFile(inputFile, []byte("[mutations]\n" + mutations), 0644); err != nil { t.Fatalf("Error writing %v: %v", inputFile, err) }
+	config.CmdArgs = &config.CommandArgs{DefaultFile: "../mendel-defaults.ini", DataPath: t.TempDir()}
+	if err := config.ReadFromFile(inputFile); err != nil { t.Fatalf("Error reading %v: %v", inputFile, err) }
+	SetModels(config.Cfg)
+}
+
+// meanEffect draws n effects from fn and returns their mean and the fraction of them that are 0
+func meanEffect(fn CalcMutationFitnessType, n int, uniformRandom *rand.Rand) (mean, fracZero float64) {
+	var sum float64
+	var zeros int
+	for i := 0; i < n; i++ {
+		effect := fn(uniformRandom)
+		sum += effect
+		if effect == 0.0 { zeros++ }
+	}
+	return sum / float64(n), float64(zeros) / float64(n)
+}
+
+// The sample means of the parametric distributions must match their theoretical means, with deleterious effects negative. For the mixture model
+// the zero-effect part must be neutral mutations, so the effects of the rest are from the gamma distribution.
+func TestParametricDfe(t *testing.T) {
+	const n = 200000
+	uniformRandom := rand.New(rand.NewSource(1))
+	tests := []struct {
+		model string
+		delMean, favMean float64
+		fractionNeutral float64
+	}{
+		{"gamma", -0.3 * 0.01, 0.5 * 0.001, 0.5},
+		{"lognormal", -0.01 * math.Exp(0.5 * 0.3 * 0.3), 0.001 * math.Exp(0.5 * 0.5 * 0.5), 0.5},
+		{"exponential", -0.01, 0.001, 0.5},
+		{"mixture", -0.3 * 0.01, 0.5 * 0.001, 0.5 + 0.5 * 0.75},
+	}
+	for _, tt := range tests {
+		dfeTestConfig(t, "fitness_effect_model = \"" + tt.model + "\"\nfraction_neutral = 0.5\ndfe_shape_del = 0.3\ndfe_scale_del = 0.01\ndfe_shape_fav = 0.5\ndfe_scale_fav = 0.001\ndfe_neutral_fraction = 0.75\n")
+		delMean, delZero := meanEffect(Mdl.CalcDelMutationFitness, n, uniformRandom)
+		favMean, _ := meanEffect(Mdl.CalcFavMutationFitness, n, uniformRandom)
+		if math.Abs(delMean - tt.delMean) > 0.05 * math.Abs(tt.delMean) { t.Errorf("%v deleterious mean effect is %v, expected %v", tt.model, delMean, tt.delMean) }
+		if math.Abs(favMean - tt.favMean) > 0.05 * tt.favMean { t.Errorf("%v favorable mean effect is %v, expected %v", tt.model, favMean, tt.favMean) }
+		if delZero != 0.0 { t.Errorf("%v has %v of its deleterious effects 0, they should be neutral mutations instead", tt.model, delZero) }
+		var numNeutral int
+		for i := 0; i < n; i++ {
+			if CalcMutationType(uniformRandom) == NEUTRAL { numNeutral++ }
+		}
+		if frac := float64(numNeutral) / n; math.Abs(frac - tt.fractionNeutral) > 0.01 { t.Errorf("%v has %v of its mutations neutral, expected %v", tt.model, frac, tt.fractionNeutral) }
+	}
+}
+
+// No favorable effect can be more than max_fav_fitness_gain
+func TestDfeMaxFavFitnessGain(t *testing.T) {
+	uniformRandom := rand.New(rand.NewSource(1))
+	for _, model := range []string{"gamma", "lognormal", "exponential", "mixture"} {
+		dfeTestConfig(t, "fitness_effect_model = \"" + model + "\"\nmax_fav_fitness_gain = 0.001\ndfe_shape_fav = 0.5\ndfe_scale_fav = 0.001\n")
+		for i := 0; i < 10000; i++ {
+			if effect := Mdl.CalcFavMutationFitness(uniformRandom); effect > 0.001 { t.Fatalf("%v favorable effect %v is more than max_fav_fitness_gain", model, effect) }
+		}
+	}
+}
+
+// The empirical model must draw only the effects in the file, in proportion to their weights
+func TestEmpiricalDfe(t *testing.T) {
+	dfeFile := filepath.Join(t.TempDir(), "dfe.txt")
+	contents := "# type effect weight\ndel 0.1 3\ndel 0.001\n\nfav 0.002\n"
+	if err := os.WriteFile(dfeFile, []byte(contents), 0644); err != nil { t.Fatalf("Error writing %v: %v", dfeFile, err) }
+	dfeTestConfig(t, "fitness_effect_model = \"empirical\"\ndfe_empirical_file = \"" + dfeFile + "\"\n")
+
+	const n = 100000
+	uniformRandom := rand.New(rand.NewSource(1))
+	var numLarge int
+	for i := 0; i < n; i++ {
+		switch effect := Mdl.CalcDelMutationFitness(uniformRandom); effect {
+		case -0.1:
+			numLarge++
+		case -0.001:
+		default:
+			t.Fatalf("Deleterious effect %v is not in %v", effect, dfeFile)
+		}
+		if effect := Mdl.CalcFavMutationFitness(uniformRandom); effect != 0.002 { t.Fatalf("Favorable effect %v is not in %v", effect, dfeFile) }
+	}
+	if frac := float64(numLarge) / n; math.Abs(frac - 0.75) > 0.01 { t.Errorf("%v of the deleterious effects are 0.1, expected 0.75", frac) }
+}
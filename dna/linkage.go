@@ -6,7 +6,6 @@ import (
 	//"log"
 	//"unsafe"
 	"github.com/genetic-algorithms/mendel-go/utils"
-	"log"
 )
 
 // Note: with a typical 10K population (30K during mating) and 989 LBs per individual there are a lot of LBs, so saving
@@ -15,14 +14,17 @@ import (
 // LinkageBlock represents 1 linkage block in the genome of an individual. It tracks the mutations in this LB and the cumulative fitness affect on the individual's fitness.
 type LinkageBlock struct {
 	mutn []Mutation		// holds deleterious, neutral, favorable, initial deleterious, initial favorable
-	// Note: instead of adding the space of another LB member var, we could always make sure the mutn array is barely big enough so the builtin append() would naturally copy it
-	IsPtrToParent bool		// whether or not the mutn slice is still a reference to its parents mutn array. We don't copy it until we add a mutation. During create of a new LB, this will naturally be set to false.
-	fitnessEffect float32
+	fitnessEffect float32		// the additive combination of the fitness effects of all of the mutations in this LB (tracked or not)
+	multFitnessEffect float32	// the multiplicative combination of the fitness effects of all of the mutations in this LB, stored as (product of (1+effect)) - 1 so the zero value means no effect
+	delFitnessEffect float32	// the additive combination of the fitness effects of only the deleterious mutations in this LB (tracked or not), used for synergistic epistasis
 	numDeleterious         uint16
 	numFavorable           uint16
 	numNeutrals            uint16               // this is used instead of the array above if track_neutrals==false
 	numDelAllele uint16
 	numFavAllele uint16
+	// Note: instead of adding the space of another LB member var, we could always make sure the mutn array is barely big enough so the builtin append() would naturally copy it
+	IsPtrToParent bool		// whether or not the mutn slice is still a reference to its parents mutn array. We don't copy it until we add a mutation. During create of a new LB, this will naturally be set to false.
+	// Note: IsPtrToParent is last so it fits in the padding after the uint16's, which keeps the struct at 48 bytes
 }
 
 
@@ -32,20 +34,31 @@ func (lb *LinkageBlock) GetNumMutations() uint32 {
 }
 
 
-// AppendMutation creates and adds a mutation to this LB.
-func (lb *LinkageBlock) AppendMutation(mutId uint64, uniformRandom *rand.Rand) (mType MutationType, fitnessEffect float32) {
+// AppendMutation creates and adds a mutation to this LB. If allow_back_mutn is set and the new mutation hits the site of an existing
+// tracked mutation, that mutation is reverted (removed) instead, and reverted is returned as true along with the type of the removed
+// mutation and the change in fitness (the negative of its fitness effect). If trackAll is true the mutation is tracked even if it is below tracking_threshold.
+func (lb *LinkageBlock) AppendMutation(mutId uint64, trackAll bool, uniformRandom *rand.Rand) (mType MutationType, fitnessEffect float32, reverted bool) {
+	// Note: only draw the random number when back mutations are allowed, so the random number sequence is unchanged when they are not
+	if config.Cfg.Mutations.Allow_back_mutn && len(lb.mutn) > 0 && uniformRandom.Float64() < float64(len(lb.mutn)) / config.Computed.Sites_per_lb {
+		mType, fitnessEffect = lb.revertMutn(uniformRandom.Intn(len(lb.mutn)))
+		reverted = true
+		return
+	}
+
 	mType = CalcMutationType(uniformRandom)
 	switch mType {
 	case DELETERIOUS_DOMINANT:
 		fallthrough
 	case DELETERIOUS_RECESSIVE:
 		fitnessEffect = calcDelMutationAttrs(mType, uniformRandom)
-		if config.Cfg.Computation.Tracking_threshold == 0.0 || fitnessEffect < -config.Cfg.Computation.Tracking_threshold {
+		if trackAll || config.Cfg.Computation.Tracking_threshold == 0.0 || fitnessEffect < -config.Cfg.Computation.Tracking_threshold {
 			// We are tracking this mutation, so create it and append
 			lb.appendMutn(Mutation{Id: mutId, Type: mType, FitnessEffect: fitnessEffect})
 		}
 		lb.numDeleterious++
-		lb.fitnessEffect += fitnessEffect		// currently only the additive combination model is supported, so this is appropriate
+		lb.fitnessEffect += fitnessEffect
+		lb.multFitnessEffect = MultCombine(lb.multFitnessEffect, fitnessEffect)
+		lb.delFitnessEffect += fitnessEffect
 	case NEUTRAL:
 		if config.Cfg.Computation.Track_neutrals {
 			lb.appendMutn(Mutation{Id: mutId, Type: NEUTRAL})
@@ -55,17 +68,22 @@ func (lb *LinkageBlock) AppendMutation(mutId uint64, uniformRandom *rand.Rand) (
 		fallthrough
 	case FAVORABLE_RECESSIVE:
 		fitnessEffect = calcFavMutationAttrs(mType, uniformRandom)
-		if config.Cfg.Computation.Tracking_threshold == 0.0 || fitnessEffect > config.Cfg.Computation.Tracking_threshold {
+		if trackAll || config.Cfg.Computation.Tracking_threshold == 0.0 || fitnessEffect > config.Cfg.Computation.Tracking_threshold {
 			// We are tracking this mutation, so create it and append
 			lb.appendMutn(Mutation{Id: mutId, Type: mType, FitnessEffect: fitnessEffect})
 		}
 		lb.numFavorable++
-		lb.fitnessEffect += fitnessEffect	// currently only the additive combination model is supported, so this is appropriate
+		lb.fitnessEffect += fitnessEffect
+		lb.multFitnessEffect = MultCombine(lb.multFitnessEffect, fitnessEffect)
 	}
 	return
 }
 
 
+// GetMutations returns the tracked mutations in this LB. The caller must not modify them, because the array may be shared with other LBs.
+func (lb *LinkageBlock) GetMutations() []Mutation { return lb.mutn }
+
+
 // appendMutn adds a mutation to the LB slice, but only adds 2 elements (instead of Go's default of doubling) if it needs to be made bigger
 // because for typical input parameters usually 0 or 1 mutation gets added to an LB in a generation.
 func (lb *LinkageBlock) appendMutn(mutn Mutation) {
@@ -88,6 +106,75 @@ func (lb *LinkageBlock) appendMutn(mutn Mutation) {
 }
 
 
+// AddMutation adds an already created mutation (e.g. read from a file) to this LB and updates the LB's aggregates. Unlike AppendMutation(),
+// the mutation is always tracked (except neutrals when track_neutrals==false), like initial alleles.
+func (lb *LinkageBlock) AddMutation(mutn Mutation) {
+	if mutn.Type != NEUTRAL || config.Cfg.Computation.Track_neutrals { lb.appendMutn(mutn) }
+	switch mutn.Type {
+	case DELETERIOUS_DOMINANT:
+		fallthrough
+	case DELETERIOUS_RECESSIVE:
+		lb.numDeleterious++
+		lb.delFitnessEffect += mutn.FitnessEffect
+	case NEUTRAL:
+		lb.numNeutrals++
+		return		// no fitness change
+	case FAVORABLE_DOMINANT:
+		fallthrough
+	case FAVORABLE_RECESSIVE:
+		lb.numFavorable++
+	case DEL_ALLELE:
+		lb.numDelAllele++
+	case FAV_ALLELE:
+		lb.numFavAllele++
+	}
+	lb.fitnessEffect += mutn.FitnessEffect
+	lb.multFitnessEffect = MultCombine(lb.multFitnessEffect, mutn.FitnessEffect)
+}
+
+
+// revertMutn removes the mutation at index i of the mutn slice and subtracts it from the LB's aggregates. Returns the type of the
+// mutation removed and the change in this LB's additive fitness.
+func (lb *LinkageBlock) revertMutn(i int) (mType MutationType, fitnessChange float32) {
+	mutn := lb.mutn[i]
+	if lb.IsPtrToParent {
+		// The mutn array is still shared with the parent (and maybe siblings), so make our own copy without this mutation
+		newSlice := make([]Mutation, len(lb.mutn)-1, len(lb.mutn)+1)
+		copy(newSlice, lb.mutn[:i])
+		copy(newSlice[i:], lb.mutn[i+1:])
+		lb.mutn = newSlice
+		lb.IsPtrToParent = false
+	} else {
+		copy(lb.mutn[i:], lb.mutn[i+1:])
+		lb.mutn = lb.mutn[:len(lb.mutn)-1]
+	}
+
+	mType = mutn.Type
+	switch mType {
+	case DELETERIOUS_DOMINANT:
+		fallthrough
+	case DELETERIOUS_RECESSIVE:
+		lb.numDeleterious--
+		lb.delFitnessEffect -= mutn.FitnessEffect
+	case NEUTRAL:
+		lb.numNeutrals--
+		return		// no fitness change
+	case FAVORABLE_DOMINANT:
+		fallthrough
+	case FAVORABLE_RECESSIVE:
+		lb.numFavorable--
+	case DEL_ALLELE:
+		lb.numDelAllele--
+	case FAV_ALLELE:
+		lb.numFavAllele--
+	}
+	fitnessChange = -mutn.FitnessEffect
+	lb.fitnessEffect += fitnessChange
+	lb.multFitnessEffect = MultRemove(lb.multFitnessEffect, mutn.FitnessEffect)
+	return
+}
+
+
 // AppendInitialContrastingAlleles adds a random initial contrasting allele pair to 2 LBs (favorable to 1, deleterious to the other).
 // The 2 LBs passed in are typically the same LB position on the same chromosome number, 1 from each parent.
 func AppendInitialContrastingAlleles(lb1, lb2 *LinkageBlock, uniqueInt *utils.UniqueInt, uniformRandom *rand.Rand) (fitnessEffect1, fitnessEffect2 float32) {
@@ -102,12 +189,14 @@ func AppendInitialContrastingAlleles(lb1, lb2 *LinkageBlock, uniqueInt *utils.Un
 	lb1.mutn = append(lb1.mutn, Mutation{Id: uniqueInt.NextInt(), Type: FAV_ALLELE, FitnessEffect: fitnessEffect1})
 	lb1.numFavAllele++
 	lb1.fitnessEffect += fitnessEffect1
+	lb1.multFitnessEffect = MultCombine(lb1.multFitnessEffect, fitnessEffect1)
 
 	// Add a deleterious allele to the 2nd LB
 	fitnessEffect2 = float32(-fitnessEffect)
 	lb2.mutn = append(lb2.mutn, Mutation{Id: uniqueInt.NextInt(), Type: DEL_ALLELE, FitnessEffect: fitnessEffect2})
 	lb2.numDelAllele++
 	lb2.fitnessEffect += fitnessEffect2
+	lb2.multFitnessEffect = MultCombine(lb2.multFitnessEffect, fitnessEffect2)
 	return
 }
 
@@ -118,11 +207,13 @@ func AppendInitialAllelePair(lb1, lb2 *LinkageBlock, favMutn, delMutn Mutation)
 	lb1.mutn = append(lb1.mutn, favMutn)
 	lb1.numFavAllele++
 	lb1.fitnessEffect += favMutn.FitnessEffect
+	lb1.multFitnessEffect = MultCombine(lb1.multFitnessEffect, favMutn.FitnessEffect)
 
 	// Add a deleterious allele to the 2nd LB
 	lb2.mutn = append(lb2.mutn, delMutn)
 	lb2.numDelAllele++
 	lb2.fitnessEffect += delMutn.FitnessEffect
+	lb2.multFitnessEffect = MultCombine(lb2.multFitnessEffect, delMutn.FitnessEffect)
 }
 
 
@@ -133,9 +224,25 @@ func (lb *LinkageBlock) SumFitness() (fitness float32) {
 }
 
 
+// MultFitness combines the fitness effect of all of its mutations in the multiplicative method. The result is (product of (1+effect)) - 1.
+func (lb *LinkageBlock) MultFitness() float32 { return lb.multFitnessEffect }
+
+
+// DelFitness returns the additive combination of the fitness effects of only the deleterious mutations in this LB (not including initial alleles)
+func (lb *LinkageBlock) DelFitness() float32 { return lb.delFitnessEffect }
+
+
+// MultCombine combines 2 fitness effects multiplicatively. Both are in the form (factor - 1), and so is the result: (1+a)*(1+b) - 1.
+// Keeping them in this form means 0 is no effect, and does not lose the precision of small effects.
+func MultCombine(a, b float32) float32 { return a + b + a*b }
+
+
+// MultRemove is the inverse of MultCombine: it removes effect b from the combined effect a, giving (1+a)/(1+b) - 1.
+func MultRemove(a, b float32) float32 { return (a - b) / (1 + b) }
+
+
 // GetMutationStats returns the number of deleterious, neutral, favorable mutations, and deleterious and favorable initial alleles.
 func (lb *LinkageBlock) GetMutationStats() (deleterious, neutral, favorable, delAllele, favAllele uint32) {
-	// Note: this is only valid for the additive combination method
 	deleterious = uint32(lb.numDeleterious)
 	neutral = uint32(lb.numNeutrals)
 	favorable = uint32(lb.numFavorable)
@@ -145,85 +252,7 @@ func (lb *LinkageBlock) GetMutationStats() (deleterious, neutral, favorable, del
 }
 
 
-// CountAlleles counts all of this LB's alleles (both mutations and initial alleles) and adds them to the given struct
-func (lb *LinkageBlock) CountAlleles(allelesForThisIndiv *AlleleCount) {
-	// We are getting the alleles for just this individual so we don't want to double count the same allele from both parents,
-	// so we only ever set the value to 1 for a particular allele id.
-	for _, m := range lb.mutn {
-		id := m.Id
-		switch m.Type {
-		case DELETERIOUS_DOMINANT:
-			if allele, ok := allelesForThisIndiv.DeleteriousDom[id]; ok {
-				// It already exists, update it
-				if config.Cfg.Computation.Count_duplicate_alleles {
-					allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: allele.Count+1, FitnessEffect: m.FitnessEffect}
-				}
-				// else we already did this: allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			} else {
-				allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			}
-		case DELETERIOUS_RECESSIVE:
-			if allele, ok := allelesForThisIndiv.DeleteriousRec[id]; ok {
-				// It already exists, update it
-				if config.Cfg.Computation.Count_duplicate_alleles {
-					allelesForThisIndiv.DeleteriousRec[id] = Allele{Count: allele.Count+1, FitnessEffect: m.FitnessEffect}
-				}
-				// else we already did this: allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			} else {
-				allelesForThisIndiv.DeleteriousRec[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			}
-		case NEUTRAL:
-			if allele, ok := allelesForThisIndiv.Neutral[id]; ok {
-				// It already exists, update it
-				if config.Cfg.Computation.Count_duplicate_alleles {
-					allelesForThisIndiv.Neutral[id] = Allele{Count: allele.Count+1, FitnessEffect: m.FitnessEffect}
-				}
-				// else we already did this: allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			} else {
-				allelesForThisIndiv.Neutral[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			}
-		case FAVORABLE_DOMINANT:
-			if allele, ok := allelesForThisIndiv.FavorableDom[id]; ok {
-				// It already exists, update it
-				if config.Cfg.Computation.Count_duplicate_alleles {
-					allelesForThisIndiv.FavorableDom[id] = Allele{Count: allele.Count+1, FitnessEffect: m.FitnessEffect}
-				}
-				// else we already did this: allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			} else {
-				allelesForThisIndiv.FavorableDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			}
-		case FAVORABLE_RECESSIVE:
-			if allele, ok := allelesForThisIndiv.FavorableRec[id]; ok {
-				// It already exists, update it
-				if config.Cfg.Computation.Count_duplicate_alleles {
-					allelesForThisIndiv.FavorableRec[id] = Allele{Count: allele.Count+1, FitnessEffect: m.FitnessEffect}
-				}
-				// else we already did this: allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			} else {
-				allelesForThisIndiv.FavorableRec[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			}
-		case DEL_ALLELE:
-			if allele, ok := allelesForThisIndiv.DelInitialAlleles[id]; ok {
-				// It already exists, update it
-				if config.Cfg.Computation.Count_duplicate_alleles {
-					allelesForThisIndiv.DelInitialAlleles[id] = Allele{Count: allele.Count+1, FitnessEffect: m.FitnessEffect}
-				}
-				// else we already did this: allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			} else {
-				allelesForThisIndiv.DelInitialAlleles[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			}
-		case FAV_ALLELE:
-			if allele, ok := allelesForThisIndiv.FavInitialAlleles[id]; ok {
-				// It already exists, update it
-				if config.Cfg.Computation.Count_duplicate_alleles {
-					allelesForThisIndiv.FavInitialAlleles[id] = Allele{Count: allele.Count+1, FitnessEffect: m.FitnessEffect}
-				}
-				// else we already did this: allelesForThisIndiv.DeleteriousDom[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			} else {
-				allelesForThisIndiv.FavInitialAlleles[id] = Allele{Count: 1, FitnessEffect: m.FitnessEffect}
-			}
-		default:
-			log.Fatalf("Error: unknown Mutation type %v found when counting alleles.", m.Type)
-		}
-	}
+// CountAlleles adds all of this LB's alleles (both mutations and initial alleles) to the given struct
+func (lb *LinkageBlock) CountAlleles(alleles *AlleleCount) {
+	for _, m := range lb.mutn { alleles.Add(m) }
 }
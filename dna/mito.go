@@ -0,0 +1,91 @@
+package dna
+
+import (
+	"github.com/genetic-algorithms/mendel-go/config"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// The mitochondrial genome (when mito_linkage_subunits > 0) is a Chromosome that each individual has only 1 copy of. It is inherited whole from
+// the mother, so its mutations are never masked by another copy: they are created as DELETERIOUS_DOMINANT with their full fitness effect (no
+// dominant_hetero_expression), drawn from their own distribution (mito_fitness_effect_model, mito_dfe_shape, mito_dfe_scale).
+
+// CalcFixedMitoMutationFitness gives every deleterious mitochondrial mutation the effect mito_dfe_scale
+func CalcFixedMitoMutationFitness(_ *rand.Rand) float64 { return -math.Min(config.Cfg.Mutations.Mito_dfe_scale, 1.0) }
+
+// CalcGammaMitoMutationFitness draws the effect of a deleterious mitochondrial mutation from a gamma distribution
+func CalcGammaMitoMutationFitness(uniformRandom *rand.Rand) float64 {
+	return -math.Min(GammaRand(uniformRandom, config.Cfg.Mutations.Mito_dfe_shape, config.Cfg.Mutations.Mito_dfe_scale), 1.0)
+}
+
+// CalcLognormalMitoMutationFitness draws the effect of a deleterious mitochondrial mutation from a lognormal distribution
+func CalcLognormalMitoMutationFitness(uniformRandom *rand.Rand) float64 {
+	return -math.Min(config.Cfg.Mutations.Mito_dfe_scale * math.Exp(config.Cfg.Mutations.Mito_dfe_shape * uniformRandom.NormFloat64()), 1.0)
+}
+
+// CalcExponentialMitoMutationFitness draws the effect of a deleterious mitochondrial mutation from an exponential distribution
+func CalcExponentialMitoMutationFitness(uniformRandom *rand.Rand) float64 {
+	return -math.Min(config.Cfg.Mutations.Mito_dfe_scale * uniformRandom.ExpFloat64(), 1.0)
+}
+
+
+// setMitoModels sets the distribution of the mitochondrial mutation effects, if there is a mitochondrial genome. Returns the model name.
+func setMitoModels(c *config.Config) []string {
+	if c.Population.Mito_linkage_subunits == 0 { return nil }
+	if c.Mutations.Mito_dfe_scale <= 0.0 { log.Fatalf("Error: if mito_linkage_subunits > 0, mito_dfe_scale must be > 0.0") }
+	switch MutationFitnessModelType(strings.ToLower(c.Mutations.Mito_fitness_effect_model)) {
+	case FIXED_FITNESS_EFFECT:
+		Mdl.CalcMitoMutationFitness = CalcFixedMitoMutationFitness
+		return []string{"CalcFixedMitoMutationFitness"}
+	case GAMMA_FITNESS_EFFECT:
+		if c.Mutations.Mito_dfe_shape <= 0.0 { log.Fatalf("Error: if mito_fitness_effect_model==%v, mito_dfe_shape must be > 0.0", c.Mutations.Mito_fitness_effect_model) }
+		Mdl.CalcMitoMutationFitness = CalcGammaMitoMutationFitness
+		return []string{"CalcGammaMitoMutationFitness"}
+	case LOGNORMAL_FITNESS_EFFECT:
+		if c.Mutations.Mito_dfe_shape <= 0.0 { log.Fatalf("Error: if mito_fitness_effect_model==%v, mito_dfe_shape must be > 0.0", c.Mutations.Mito_fitness_effect_model) }
+		Mdl.CalcMitoMutationFitness = CalcLognormalMitoMutationFitness
+		return []string{"CalcLognormalMitoMutationFitness"}
+	case EXPONENTIAL_FITNESS_EFFECT:
+		Mdl.CalcMitoMutationFitness = CalcExponentialMitoMutationFitness
+		return []string{"CalcExponentialMitoMutationFitness"}
+	default:
+		log.Fatalf("Error: unrecognized value for mito_fitness_effect_model: %v, it must be fixed, gamma, lognormal, or exponential", c.Mutations.Mito_fitness_effect_model)
+	}
+	return nil
+}
+
+
+// appendMitoMutation creates a new mitochondrial mutation (neutral or deleterious) and adds it to this LB. Returns the type of the mutation and its fitness effect.
+func (lb *LinkageBlock) appendMitoMutation(mutId uint64, uniformRandom *rand.Rand) (mType MutationType, fitnessEffect float32) {
+	if uniformRandom.Float64() < config.Cfg.Mutations.Mito_fraction_neutral {
+		if config.Cfg.Computation.Track_neutrals { lb.appendMutn(Mutation{Id: mutId, Type: NEUTRAL}) }
+		lb.numNeutrals++
+		return NEUTRAL, 0.0
+	}
+	mType = DELETERIOUS_DOMINANT
+	fitnessEffect = float32(Mdl.CalcMitoMutationFitness(uniformRandom))
+	if config.Cfg.Computation.Tracking_threshold == 0.0 || fitnessEffect < -config.Cfg.Computation.Tracking_threshold {
+		lb.appendMutn(Mutation{Id: mutId, Type: mType, FitnessEffect: fitnessEffect})
+	}
+	lb.numDeleterious++
+	lb.fitnessEffect += fitnessEffect
+	lb.multFitnessEffect = MultCombine(lb.multFitnessEffect, fitnessEffect)
+	lb.delFitnessEffect += fitnessEffect
+	return
+}
+
+
+// AppendMitoMutation creates a new mitochondrial mutation in the LB specified of this mitochondrial genome. Returns the type of mutation added.
+func (c *Chromosome) AppendMitoMutation(lbInChr int, mutId uint64, uniformRandom *rand.Rand) MutationType {
+	lb := &c.LinkageBlocks[lbInChr]
+	oldDelFitness := lb.DelFitness()
+	mType, fitnessEffect := lb.appendMitoMutation(mutId, uniformRandom)
+	c.FitnessEffect += fitnessEffect
+	c.MultFitnessEffect = MultCombine(c.MultFitnessEffect, fitnessEffect)
+	newDelFitness := lb.DelFitness()
+	c.DelFitnessEffect += newDelFitness - oldDelFitness
+	c.DelFitnessSqr += newDelFitness * newDelFitness - oldDelFitness * oldDelFitness
+	return mType
+}
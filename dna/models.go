@@ -11,6 +11,11 @@ const (
 	FIXED_FITNESS_EFFECT MutationFitnessModelType = "fixed"
 	UNIFORM_FITNESS_EFFECT MutationFitnessModelType = "uniform"
 	WEIBULL_FITNESS_EFFECT MutationFitnessModelType = "weibull"
+	GAMMA_FITNESS_EFFECT MutationFitnessModelType = "gamma"
+	LOGNORMAL_FITNESS_EFFECT MutationFitnessModelType = "lognormal"
+	EXPONENTIAL_FITNESS_EFFECT MutationFitnessModelType = "exponential"
+	MIXTURE_FITNESS_EFFECT MutationFitnessModelType = "mixture"
+	EMPIRICAL_FITNESS_EFFECT MutationFitnessModelType = "empirical"
 )
 
 type CrossoverModelType string
@@ -25,8 +30,13 @@ const (
 type Models struct {
 	CalcDelMutationFitness CalcMutationFitnessType
 	CalcFavMutationFitness CalcMutationFitnessType
+	CalcMitoMutationFitness CalcMutationFitnessType		// only set if mito_linkage_subunits > 0
 	Crossover CrossoverType
 	CalcAlleleFitness CalcAlleleFitnessType		// this goes with pop.InitialAlleleModelType
+	RecordLbSources bool		// whether TransferLB() records which parent chromosome each LB came from, for the tree sequence output
+	MutationLbs *AliasTable		// if mutn_rate_map_file is set, chooses the LB (in the whole genome) of each new mutation
+	CrossoverPoints []*AliasTable		// if crossover_map_file is set, chooses the crossover points of each chromosome (an index i is the point before LB i+1)
+	CrossoverScales []float64		// if crossover_map_file is set, the factor to multiply the mean number of crossovers of each chromosome by
 }
 
 // Mdl is the singleton instance of Models that can be accessed throughout the dna package. It gets set in SetModels().
@@ -56,11 +66,49 @@ func SetModels(c *config.Config) {
 		mdlNames = append(mdlNames, "CalcWeibullDelMutationFitness")
 		Mdl.CalcFavMutationFitness = CalcWeibullFavMutationFitness
 		mdlNames = append(mdlNames, "CalcWeibullFavMutationFitness")
+	case GAMMA_FITNESS_EFFECT:
+		checkDfeParams(c, true)
+		Mdl.CalcDelMutationFitness = CalcGammaDelMutationFitness
+		mdlNames = append(mdlNames, "CalcGammaDelMutationFitness")
+		Mdl.CalcFavMutationFitness = CalcGammaFavMutationFitness
+		mdlNames = append(mdlNames, "CalcGammaFavMutationFitness")
+	case LOGNORMAL_FITNESS_EFFECT:
+		checkDfeParams(c, true)
+		Mdl.CalcDelMutationFitness = CalcLognormalDelMutationFitness
+		mdlNames = append(mdlNames, "CalcLognormalDelMutationFitness")
+		Mdl.CalcFavMutationFitness = CalcLognormalFavMutationFitness
+		mdlNames = append(mdlNames, "CalcLognormalFavMutationFitness")
+	case EXPONENTIAL_FITNESS_EFFECT:
+		checkDfeParams(c, false)
+		Mdl.CalcDelMutationFitness = CalcExponentialDelMutationFitness
+		mdlNames = append(mdlNames, "CalcExponentialDelMutationFitness")
+		Mdl.CalcFavMutationFitness = CalcExponentialFavMutationFitness
+		mdlNames = append(mdlNames, "CalcExponentialFavMutationFitness")
+	case MIXTURE_FITNESS_EFFECT:
+		checkDfeParams(c, true)
+		if c.Mutations.Dfe_neutral_fraction < 0.0 || c.Mutations.Dfe_neutral_fraction > 1.0 { log.Fatal("Error: if fitness_effect_model==mixture, dfe_neutral_fraction must be between 0.0 and 1.0.") }
+		// The zero-effect part of the mixture is made neutral by CalcMutationType(), so the rest is just the gamma distribution
+		Mdl.CalcDelMutationFitness = CalcGammaDelMutationFitness
+		mdlNames = append(mdlNames, "CalcGammaDelMutationFitness")
+		Mdl.CalcFavMutationFitness = CalcGammaFavMutationFitness
+		mdlNames = append(mdlNames, "CalcGammaFavMutationFitness")
+	case EMPIRICAL_FITNESS_EFFECT:
+		if c.Mutations.Dfe_empirical_file == "" { log.Fatal("Error: if fitness_effect_model==empirical, you must set dfe_empirical_file.") }
+		empiricalDfe[0], empiricalDfe[1] = ReadEmpiricalDfe(c.Mutations.Dfe_empirical_file)
+		if len(empiricalDfe[0].Effects) == 0 && c.Mutations.Frac_fav_mutn < 1.0 && c.Mutations.Fraction_neutral < 1.0 { log.Fatalf("Error: dfe_empirical_file %v has no del effects", c.Mutations.Dfe_empirical_file) }
+		if len(empiricalDfe[1].Effects) == 0 && c.Mutations.Frac_fav_mutn > 0.0 && c.Mutations.Fraction_neutral < 1.0 { log.Fatalf("Error: dfe_empirical_file %v has no fav effects, but frac_fav_mutn > 0.0", c.Mutations.Dfe_empirical_file) }
+		Mdl.CalcDelMutationFitness = CalcEmpiricalDelMutationFitness
+		mdlNames = append(mdlNames, "CalcEmpiricalDelMutationFitness")
+		Mdl.CalcFavMutationFitness = CalcEmpiricalFavMutationFitness
+		mdlNames = append(mdlNames, "CalcEmpiricalFavMutationFitness")
 	default:
 		log.Fatalf("Error: unrecognized value for fitness_effect_model: %v", c.Mutations.Fitness_effect_model)
 	}
 
-	switch CrossoverModelType(strings.ToLower(c.Population.Crossover_model)) {
+	// With recombination_model 2 (suppressed) the chromosomes are inherited whole, which is the same as no crossover
+	crossoverModel := CrossoverModelType(strings.ToLower(c.Population.Crossover_model))
+	if c.Population.Recombination_model == 2 { crossoverModel = NO_CROSSOVER }
+	switch crossoverModel {
 	case NO_CROSSOVER:
 		Mdl.Crossover = NoCrossover
 		mdlNames = append(mdlNames, "NoCrossover")
@@ -74,5 +122,23 @@ func SetModels(c *config.Config) {
 		log.Fatalf("Error: unrecognized value for crossover_model: %v", c.Population.Crossover_model)
 	}
 
+	mdlNames = append(mdlNames, setMitoModels(c)...)
+
+	Mdl.RecordLbSources = config.FMgr != nil && config.FMgr.IsTreeSequenceOutput()
+	setMutationRateMap(c)
+	setCrossoverMap(c)
+	if c.Mutations.Mutn_rate_map_file != "" { mdlNames = append(mdlNames, "MutationRateMap") }
+	if c.Population.Crossover_map_file != "" { mdlNames = append(mdlNames, "CrossoverMap") }
+
 	config.Verbose(1, "Running with these dna models: %v", strings.Join(mdlNames, ", "))
 }
+
+
+// checkDfeParams verifies the dfe_* params of the distributions that use them. The fav params are only used when frac_fav_mutn > 0.
+func checkDfeParams(c *config.Config, usesShape bool) {
+	if usesShape && c.Mutations.Dfe_shape_del <= 0.0 { log.Fatalf("Error: if fitness_effect_model==%v, dfe_shape_del must be > 0.0", c.Mutations.Fitness_effect_model) }
+	if c.Mutations.Dfe_scale_del <= 0.0 { log.Fatalf("Error: if fitness_effect_model==%v, dfe_scale_del must be > 0.0", c.Mutations.Fitness_effect_model) }
+	if c.Mutations.Frac_fav_mutn <= 0.0 { return }
+	if usesShape && c.Mutations.Dfe_shape_fav <= 0.0 { log.Fatalf("Error: if fitness_effect_model==%v and frac_fav_mutn > 0.0, dfe_shape_fav must be > 0.0", c.Mutations.Fitness_effect_model) }
+	if c.Mutations.Dfe_scale_fav <= 0.0 { log.Fatalf("Error: if fitness_effect_model==%v and frac_fav_mutn > 0.0, dfe_scale_fav must be > 0.0", c.Mutations.Fitness_effect_model) }
+}
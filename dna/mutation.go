@@ -21,6 +21,26 @@ const (
 	FAV_ALLELE MutationType = iota
 )
 
+// MutationTypeNames maps the names used in input files (e.g. upload_mutations_file) to the mutation types
+var MutationTypeNames = map[string]MutationType{
+	"deleterious-dominant": DELETERIOUS_DOMINANT,
+	"deleterious-recessive": DELETERIOUS_RECESSIVE,
+	"neutral": NEUTRAL,
+	"favorable-dominant": FAVORABLE_DOMINANT,
+	"favorable-recessive": FAVORABLE_RECESSIVE,
+	"del-allele": DEL_ALLELE,
+	"fav-allele": FAV_ALLELE,
+}
+
+
+// Name returns the name of this mutation type in MutationTypeNames
+func (t MutationType) Name() string {
+	for name, mType := range MutationTypeNames {
+		if mType == t { return name }
+	}
+	return "unknown"
+}
+
 
 // A simple struct that is embedded in the LB arrays. (Not a ptr to it.) A lot of mutations exist, so need to keep its size to a minimum.
 type Mutation struct {
@@ -49,51 +69,21 @@ type Mutator interface {
 }
 */
 
-type Allele struct {
-	Count         uint32
-	FitnessEffect float32
-}
-
-// The number of occurrences of each allele (both mutations and initial alleles) in 1 generation. The map key is the unique id of mutation.
-// Note: this is defined here instead of population.go to avoid circular dependencies
-type AlleleCount struct {
-	DeleteriousDom         map[uint64]Allele
-	DeleteriousRec         map[uint64]Allele
-	Neutral         map[uint64]Allele
-	FavorableDom         map[uint64]Allele
-	FavorableRec         map[uint64]Allele
-	DelInitialAlleles         map[uint64]Allele
-	FavInitialAlleles         map[uint64]Allele
-}
-
-func AlleleCountFactory() *AlleleCount {
-	ac := &AlleleCount{}
-	ac.DeleteriousDom = make(map[uint64]Allele)
-	ac.DeleteriousRec = make(map[uint64]Allele)
-	ac.Neutral = make(map[uint64]Allele)
-	ac.FavorableDom = make(map[uint64]Allele)
-	ac.FavorableRec = make(map[uint64]Allele)
-	ac.DelInitialAlleles = make(map[uint64]Allele)
-	ac.FavInitialAlleles = make(map[uint64]Allele)
-	return ac
-}
-
-
 // CalcMutationType determines if the next mutation should be deleterious/neutral/favorable based on a random number and the various relevant rates for this population.
 // This is used by the LB to determine which of the Mutation subclasses to create.
 func CalcMutationType(uniformRandom *rand.Rand) (mType MutationType) {
 
 	// Determine if this mutation is deleterious, neutral, or favorable.
-	// Frac_fav_mutn is the fraction of the non-neutral mutations that are favorable.
+	// Frac_fav_mutn is the fraction of the non-neutral mutations that are favorable. The neutral fraction includes the zero-effect part of a mixture DFE.
 	rnd := uniformRandom.Float64()
-	if rnd < config.Cfg.Mutations.Frac_fav_mutn * (1.0 - config.Cfg.Mutations.Fraction_neutral) {
+	if rnd < config.Cfg.Mutations.Frac_fav_mutn * (1.0 - config.Computed.Fraction_neutral) {
 		dominant := config.Cfg.Mutations.Fraction_recessive < uniformRandom.Float64()
 		if dominant {
 			mType = FAVORABLE_DOMINANT
 		} else {
 			mType = FAVORABLE_RECESSIVE
 		}
-	} else if rnd < 1.0 - config.Cfg.Mutations.Fraction_neutral {
+	} else if rnd < 1.0 - config.Computed.Fraction_neutral {
 		dominant := config.Cfg.Mutations.Fraction_recessive < uniformRandom.Float64()
 		if dominant {
 			mType = DELETERIOUS_DOMINANT
@@ -139,6 +129,21 @@ func calcFavMutationAttrs(mType MutationType, uniformRandom *rand.Rand) (fitness
 }
 
 
+// HemizygousEffect returns the fitness effect of a mutation when it is the only copy of its LB in the individual (e.g. on the X of an XY male),
+// so it is not reduced by the heterozygous expression factor it was created with. Initial alleles are co-dominant, so their effect is doubled.
+func HemizygousEffect(mType MutationType, fitnessEffect float32) float32 {
+	switch mType {
+	case DELETERIOUS_DOMINANT, FAVORABLE_DOMINANT:
+		if config.Cfg.Mutations.Dominant_hetero_expression > 0.0 { return fitnessEffect / float32(config.Cfg.Mutations.Dominant_hetero_expression) }
+	case DELETERIOUS_RECESSIVE, FAVORABLE_RECESSIVE:
+		if config.Cfg.Mutations.Recessive_hetero_expression > 0.0 { return fitnessEffect / float32(config.Cfg.Mutations.Recessive_hetero_expression) }
+	case DEL_ALLELE, FAV_ALLELE:
+		return 2.0 * fitnessEffect
+	}
+	return 0.0
+}
+
+
 // These are the different algorithms for assigning a fitness factor to a mutation. Pointers to 2 of them are chosen at initialization time.
 type CalcMutationFitnessType func(uniformRandom *rand.Rand) float64
 func CalcFixedDelMutationFitness(_ *rand.Rand) float64 { return -config.Cfg.Mutations.Uniform_fitness_effect_del }
@@ -0,0 +1,135 @@
+package dna
+
+import (
+	"bufio"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A rate map file (mutn_rate_map_file or crossover_map_file) gives the relative rate along the genome. It contains 1 non-negative weight per
+// line (blank lines and lines starting with # are ignored), either 1 per LB (num_linkage_subunits of them, in chromosome order) or 1 per
+// chromosome (haploid_chromosome_number of them, which applies the weight to every LB of that chromosome).
+
+// AliasTable chooses an index with probability proportional to its weight in O(1) time, using Vose's alias method
+type AliasTable struct {
+	Prob []float64		// the probability of choosing index i itself when column i is picked
+	Alias []int			// the index chosen when column i is picked, but index i itself is not
+}
+
+
+// NewAliasTable builds the alias table for the given weights. Returns nil if the weights sum to 0.
+func NewAliasTable(weights []float64) *AliasTable {
+	n := len(weights)
+	var sum float64
+	for _, w := range weights { sum += w }
+	if n == 0 || sum <= 0.0 { return nil }
+	t := &AliasTable{Prob: make([]float64, n), Alias: make([]int, n)}
+
+	// Scale the weights so the mean is 1, then pair each column that is under 1 with one that is over 1 to fill it up
+	scaled := make([]float64, n)
+	var small, large []int
+	for i, w := range weights {
+		scaled[i] = w * float64(n) / sum
+		if scaled[i] < 1.0 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s := small[len(small)-1]
+		small = small[:len(small)-1]
+		l := large[len(large)-1]
+		t.Prob[s] = scaled[s]
+		t.Alias[s] = l
+		scaled[l] -= 1.0 - scaled[s]
+		if scaled[l] < 1.0 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// What is left is 1 except for floating point error
+	for _, i := range large { t.Prob[i], t.Alias[i] = 1.0, i }
+	for _, i := range small { t.Prob[i], t.Alias[i] = 1.0, i }
+	return t
+}
+
+
+// Sample returns a random index, with the probability of each index proportional to its weight
+func (t *AliasTable) Sample(uniformRandom *rand.Rand) int {
+	i := uniformRandom.Intn(len(t.Prob))
+	if uniformRandom.Float64() < t.Prob[i] { return i }
+	return t.Alias[i]
+}
+
+
+// ReadRateMap reads a rate map file and returns the weight of each LB of the genome, in chromosome order
+func ReadRateMap(fileName string, chromosomeLbs []uint32) []float64 {
+	file, err := os.Open(fileName)
+	if err != nil { log.Fatalf("Error opening rate map file %v: %v", fileName, err) }
+	defer file.Close()
+
+	var weights []float64
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") { continue }
+		w, err := strconv.ParseFloat(line, 64)
+		if err != nil || w < 0.0 { log.Fatalf("Error: invalid weight %v on line %d of %v, it must be >= 0.0", line, lineNum, fileName) }
+		weights = append(weights, w)
+	}
+	if err := scanner.Err(); err != nil { log.Fatalf("Error reading rate map file %v: %v", fileName, err) }
+
+	var numLbs int
+	for _, n := range chromosomeLbs { numLbs += int(n) }
+	switch len(weights) {
+	case numLbs:
+		return weights
+	case len(chromosomeLbs):
+		lbWeights := make([]float64, 0, numLbs)
+		for chr, w := range weights {
+			for lb := uint32(0); lb < chromosomeLbs[chr]; lb++ { lbWeights = append(lbWeights, w) }
+		}
+		return lbWeights
+	default:
+		log.Fatalf("Error: rate map file %v has %d weights, it must have 1 per LB (%d) or 1 per chromosome (%d)", fileName, len(weights), numLbs, len(chromosomeLbs))
+	}
+	return nil
+}
+
+
+// setMutationRateMap builds the alias table AddMutations() uses to choose the LB of each new mutation, if mutn_rate_map_file is set
+func setMutationRateMap(c *config.Config) {
+	if c.Mutations.Mutn_rate_map_file == "" { return }
+	weights := ReadRateMap(c.Mutations.Mutn_rate_map_file, config.Computed.Chromosome_lbs)
+	if Mdl.MutationLbs = NewAliasTable(weights); Mdl.MutationLbs == nil { log.Fatalf("Error: the weights in mutn_rate_map_file %v must not all be 0", c.Mutations.Mutn_rate_map_file) }
+}
+
+
+// setCrossoverMap builds the alias tables PartialCrossover() uses to choose the crossover points of each chromosome, if crossover_map_file is set.
+// The weight of an LB is the relative probability of a crossover between it and the previous LB (so the weight of the 1st LB of a chromosome is
+// not used). The number of crossovers of each chromosome is scaled by the total weight of the chromosome relative to the mean of all of them.
+func setCrossoverMap(c *config.Config) {
+	if c.Population.Crossover_map_file == "" { return }
+	chromosomeLbs := config.Computed.Chromosome_lbs
+	weights := ReadRateMap(c.Population.Crossover_map_file, chromosomeLbs)
+
+	numChromosomes := len(chromosomeLbs)
+	Mdl.CrossoverPoints = make([]*AliasTable, numChromosomes)
+	Mdl.CrossoverScales = make([]float64, numChromosomes)
+	var total float64
+	for chr, offset := range config.Computed.Chromosome_offsets {
+		chrWeights := weights[offset+1 : offset+chromosomeLbs[chr]]		// crossover points are before LBs 1 - (chromosomeLbs[chr]-1)
+		Mdl.CrossoverPoints[chr] = NewAliasTable(chrWeights)
+		for _, w := range chrWeights { Mdl.CrossoverScales[chr] += w }
+		total += Mdl.CrossoverScales[chr]
+	}
+	if total <= 0.0 { log.Fatalf("Error: the weights in crossover_map_file %v must not all be 0", c.Population.Crossover_map_file) }
+	for chr := range Mdl.CrossoverScales { Mdl.CrossoverScales[chr] *= float64(numChromosomes) / total }
+}
@@ -0,0 +1,23 @@
+package dna
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// The alias table must choose each index in proportion to its weight, and never choose one with a weight of 0
+func TestAliasTable(t *testing.T) {
+	weights := []float64{1.0, 0.0, 3.0, 0.5, 0.5, 5.0}
+	table := NewAliasTable(weights)
+	const n = 200000
+	counts := make([]int, len(weights))
+	uniformRandom := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ { counts[table.Sample(uniformRandom)]++ }
+	for i, w := range weights {
+		expected := w / 10.0
+		if frac := float64(counts[i]) / n; math.Abs(frac - expected) > 0.01 { t.Errorf("Index %d was chosen %v of the time, expected %v", i, frac, expected) }
+	}
+	if counts[1] != 0 { t.Errorf("Index 1 has a weight of 0, but was chosen %d times", counts[1]) }
+	if NewAliasTable([]float64{0.0, 0.0}) != nil { t.Errorf("An alias table with all weights 0 should be nil") }
+}
@@ -34,7 +34,7 @@ import (
 )
 
 // Initialize initializes variables, objects, and settings.
-func initialize() *rand.Rand {
+func initialize() (*rand.Rand, *random.Xoshiro256) {
 	config.Verbose(5, "Initializing...\n")
 
 	if config.Cfg.Computation.Force_gc {
@@ -51,7 +51,7 @@ func initialize() *rand.Rand {
 	pop.SetModels(config.Cfg)
 
 	random.NextSeed = config.Cfg.Computation.Random_number_seed
-	return random.RandFactory()
+	return random.TrackedRandFactory()		// the main random number generator is tracked so it can be checkpointed
 }
 
 // CreateSpcZip zips up the output in a form suitable for importing into SPC for data visualization
@@ -190,35 +190,50 @@ func main() {
 		log.Fatalf("Error: unrecognized value for performance_profile: %v", config.Cfg.Computation.Performance_profile)
 	}
 
-	uniformRandom := initialize()
+	uniformRandom, randSource := initialize()
 
 	maxGenNum := config.Cfg.Basic.Num_generations
-	parentSpecies := pop.SpeciesFactory().Initialize(maxGenNum, uniformRandom)
-
-	popMaxIsSet := pop.PopulationGrowthModelType(strings.ToLower(config.Cfg.Population.Pop_growth_model))==pop.EXPONENTIAL_POPULATON_GROWTH && config.Cfg.Population.Max_pop_size>0
-	//popMax := config.Cfg.Population.Max_pop_size
-
-	// If num gens is 0 and not exponential growth, only report on genesis pop and then exit
-	zeroGens := maxGenNum == 0 && !popMaxIsSet
-	if config.Cfg.Population.Num_contrasting_alleles > 0 && (zeroGens || config.Cfg.Computation.Plot_allele_gens == 1) {
-		totalInterimTime := utils.Measure.GetInterimTime("Total")
-		//parentPop.ReportEachGen(0, zeroGens)
-		parentSpecies.ReportEachGen(0, zeroGens, totalInterimTime, 0.0)
-		if zeroGens {
-			shutdown() // Finish up
-			os.Exit(0)
+	var parentSpecies *pop.Species
+	startGen := uint32(1)
+	if config.CmdArgs.RestartFile != "" {
+		// Continue the run from where the checkpoint left off. The output files already have the initial and earlier gen info in them.
+		parentSpecies, uniformRandom, randSource = pop.ReadCheckpoint(config.CmdArgs.RestartFile)
+		startGen = config.Restart.Gen_0 + 1
+	} else {
+		parentSpecies = pop.SpeciesFactory().Initialize(maxGenNum, uniformRandom)
+		parentSpecies.RecordPedigree(0)		// only does something if track_pedigree is enabled
+		parentSpecies.TrackTrajectories(0)		// only does something if num_trajectories>0 and trajectory_gen=0
+
+		popMaxIsSet := pop.PopulationGrowthModelType(strings.ToLower(config.Cfg.Population.Pop_growth_model))==pop.EXPONENTIAL_POPULATON_GROWTH && config.Cfg.Population.Max_pop_size>0
+		//popMax := config.Cfg.Population.Max_pop_size
+
+		// If num gens is 0 and not exponential growth, only report on genesis pop and then exit
+		zeroGens := maxGenNum == 0 && !popMaxIsSet
+		parentSpecies.RecordTreeSequence(0, zeroGens && config.Cfg.Population.Num_contrasting_alleles > 0)		// only does something if track_tree_sequence is enabled. The genesis pop is the last gen if we exit below.
+		if config.Cfg.Population.Num_contrasting_alleles > 0 && (zeroGens || config.Cfg.Computation.Plot_allele_gens == 1) {
+			totalInterimTime := utils.Measure.GetInterimTime("Total")
+			//parentPop.ReportEachGen(0, zeroGens)
+			parentSpecies.ReportEachGen(0, zeroGens, totalInterimTime, 0.0)
+			if zeroGens {
+				shutdown() // Finish up
+				os.Exit(0)
+			}
 		}
 	}
 
 	// Main generation loop.
-	for gen := uint32(1); ; gen++ {
+	for gen := startGen; ; gen++ {
 		utils.Measure.Start("Generations")		// this is stopped in ReportEachGen() so it can report each delta
 		childrenSpecies := parentSpecies.GetNextGeneration(gen)	// this creates the PopulationParts too
+		parentSpecies.TribalCompetition(childrenSpecies, gen, uniformRandom)		// only does something if tribal_competition is enabled
 		parentSpecies.Mate(childrenSpecies, uniformRandom)		// this fills in the next gen populations object with the offspring
 		utils.Measure.CheckAmountMemoryUsed()
 		parentSpecies = nil 	// give GC a chance to reclaim the previous generation
 		if config.Cfg.Computation.Force_gc { utils.CollectGarbage() }
 		childrenSpecies.Select(uniformRandom)
+		childrenSpecies.Migrate(gen, uniformRandom)		// only does something every migration_generations, if migration is enabled
+		childrenSpecies.Fission(gen, uniformRandom)		// only does something if tribal_fission is enabled
+		childrenSpecies.RecordPedigree(gen)		// only does something if track_pedigree is enabled
 
 		// Check if we should stop the run
 		lastGen := false
@@ -233,13 +248,22 @@ func main() {
 			lastGen = true
 		}
 
+		childrenSpecies.RecordTreeSequence(gen, lastGen)		// only does something if track_tree_sequence is enabled
+		childrenSpecies.TrackFixation(gen, lastGen)		// only does something if track_fixation is enabled. This must be before ReportEachGen(), which frees the individuals in the last gen.
+		childrenSpecies.TrackTrajectories(gen)		// only does something if num_trajectories>0
+		childrenSpecies.CalcLinkageDisequilibrium(gen, lastGen)		// only does something if ld_sample_size>0. This must be before ReportEachGen(), which frees the individuals in the last gen.
+
 		totalInterimTime := utils.Measure.GetInterimTime("Total")
 		genTime := utils.Measure.Stop("Generations")
 		childrenSpecies.ReportEachGen(gen, lastGen, totalInterimTime, genTime)
 		childrenSpecies.MarkDonePops()		// effectively stops the tribes that have gone extinct or reached pop max
 		if lastGen { break }
+		if config.Cfg.Computation.Checkpoint_gens > 0 && gen % config.Cfg.Computation.Checkpoint_gens == 0 {
+			childrenSpecies.WriteCheckpoint(gen, randSource)
+		}
 		parentSpecies = childrenSpecies        // for the next iteration
 	}
 
+	pop.PrunePedigree()		// only does something if prune_pedigree is enabled
 	shutdown()	// Finish up
 }
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -94,6 +95,17 @@ func TestMendelCase14(t *testing.T) {
 	mendelCaseTribeBin(t, 14, 14, "00000050.json", true)
 }
 
+// Same as TestMendelCase14 except it writes checkpoints, and then is restarted from the last one. Both runs should produce the same output as case 14.
+func TestMendelCase16(t *testing.T) {
+	mendelCaseTribeBin(t, 16, 14, "00000050.json", true)
+	mendelCaseRestartTribeBin(t, 16, 14, "00000050.json", true)
+}
+
+// Restart with output dirs that have files written after the last checkpoint (one of them partly), which must be replaced by the restarted run
+func TestMendelCase17(t *testing.T) {
+	mendelCaseRestartDirs(t, 17, "00000050.json")
+}
+
 // Multiple tribes going extinct
 /*todo: the results don't quite match the expected
 func TestMendelCase15(t *testing.T) {
@@ -103,6 +115,11 @@ func TestMendelCase15(t *testing.T) {
 
 // mendelCase runs a typical test case with an input file number and expected output file number.
 func mendelCase(t *testing.T, num, expNum int) {
+	mendelCaseWithArgs(t, num, expNum)
+}
+
+// mendelCaseWithArgs is like mendelCase, except passes additional args to mendel-go
+func mendelCaseWithArgs(t *testing.T, num, expNum int, extraArgs ...string) {
 	numStr := strconv.Itoa(num)
 	expNumStr := strconv.Itoa(expNum)
 	//outputFileBase := "mendel"
@@ -118,7 +135,8 @@ func mendelCase(t *testing.T, num, expNum int) {
 
 	cmdString := "./mendel-go"
 	cmdFailed := false
-	stdoutBytes, stderrBytes, err := runCmd(t, cmdString, "-f", inFileName, "-O", dataPath)
+	args := append([]string{"-f", inFileName, "-O", dataPath}, extraArgs...)
+	stdoutBytes, stderrBytes, err := runCmd(t, cmdString, args...)
 	if err != nil {
 		t.Errorf("Error running command %v: %v", cmdString, err)
 		cmdFailed = true
@@ -174,12 +192,61 @@ func compareBinFiles(t *testing.T, numStr, expNumStr, binFile string, andDistBin
 
 func mendelCaseTribeBin(t *testing.T, num, expNum int, binFile string, andDistBins bool) {
 	mendelCase(t, num, expNum) // compare the summary files in the top dir
+	compareTribeFiles(t, num, expNum, binFile, andDistBins)
+}
+
+// mendelCaseRestartTribeBin restarts a tribe test case from the checkpoint file a previous run of it wrote, and compares all of the output
+func mendelCaseRestartTribeBin(t *testing.T, num, expNum int, binFile string, andDistBins bool) {
+	checkpointFile := OUT_FILE_BASE + strconv.Itoa(num) + "/" + config.CHECKPOINT_FILENAME
+	mendelCaseWithArgs(t, num, expNum, "-r", checkpointFile) // compare the summary files in the top dir
+	compareTribeFiles(t, num, expNum, binFile, andDistBins)
+}
+
+// mendelCaseRestartDirs runs a test case that writes checkpoints, damages the output dirs the way a run interrupted after the last checkpoint
+// would leave them (dirFile only partly written and a file of a later gen), restarts it, and compares all of its output to the first run's.
+func mendelCaseRestartDirs(t *testing.T, num int, dirFile string) {
+	dataPath := OUT_FILE_BASE + strconv.Itoa(num)
+	os.RemoveAll(dataPath)
+	if err := os.MkdirAll(dataPath, 0755); err != nil { t.Fatalf("Error creating %v: %v", dataPath, err) }
+	args := []string{"-f", IN_FILE_BASE + strconv.Itoa(num) + ".ini", "-O", dataPath}
+	if stdoutBytes, stderrBytes, err := runCmd(t, "./mendel-go", args...); err != nil { t.Fatalf("Error running mendel-go: %v\nstdout: %s\nstderr: %s", err, stdoutBytes, stderrBytes) }
+	firstRun := readOutputFiles(t, dataPath)
+
+	binFile := dataPath + BIN_SUBDIR + dirFile
+	if err := ioutil.WriteFile(binFile, firstRun[BIN_SUBDIR[1:]+dirFile][:10], 0644); err != nil { t.Fatalf("Error writing %v: %v", binFile, err) }
+	if err := ioutil.WriteFile(dataPath+BIN_SUBDIR+"99999999.json", []byte("{}"), 0644); err != nil { t.Fatalf("Error writing %v: %v", binFile, err) }
+
+	args = append(args, "-r", dataPath+"/"+config.CHECKPOINT_FILENAME)
+	if stdoutBytes, stderrBytes, err := runCmd(t, "./mendel-go", args...); err != nil { t.Fatalf("Error restarting mendel-go: %v\nstdout: %s\nstderr: %s", err, stdoutBytes, stderrBytes) }
+	restarted := readOutputFiles(t, dataPath)
+	for name, contents := range firstRun {
+		if !bytes.Equal(restarted[name], contents) { t.Errorf("Output file %v of the restarted run does not match the first run", name) }
+	}
+	for name := range restarted {
+		if _, ok := firstRun[name]; !ok { t.Errorf("Output file %v of the restarted run was not written by the first run", name) }
+	}
+}
+
+// readOutputFiles returns the contents of all of the output files under dir (except the checkpoint), keyed by their path relative to dir
+func readOutputFiles(t *testing.T, dir string) map[string][]byte {
+	files := make(map[string][]byte)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() == config.CHECKPOINT_FILENAME { return err }
+		contents, err := ioutil.ReadFile(path)
+		files[strings.TrimPrefix(path, dir+"/")] = contents
+		return err
+	})
+	if err != nil { t.Fatalf("Error reading the output files in %v: %v", dir, err) }
+	return files
+}
+
+func compareTribeFiles(t *testing.T, num, expNum int, binFile string, andDistBins bool) {
 	numStr := strconv.Itoa(num)
 	expNumStr := strconv.Itoa(expNum)
 	// Go into each of the tribe dirs can compare everything in there
 	for _, tribeDir := range getTribeDirs(t, OUT_FILE_BASE+numStr) {
 		outFileDir := OUT_FILE_BASE + numStr + "/" + tribeDir
-		expFileDir := EXP_FILE_BASE + numStr + "/" + tribeDir
+		expFileDir := EXP_FILE_BASE + expNumStr + "/" + tribeDir
 		comparePlainFiles(t, numStr, expNumStr, outFileDir, expFileDir)
 		compareBinFiles(t, numStr, expNumStr, binFile, andDistBins, outFileDir, expFileDir)
 	}
@@ -0,0 +1,265 @@
+package pop
+
+import (
+	"bufio"
+	"encoding/gob"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"github.com/genetic-algorithms/mendel-go/random"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"log"
+	"math/rand"
+	"os"
+)
+
+// A checkpoint file is a gob stream of: a checkpointHeader, then for each population a populationCheckpoint followed by an
+// individualCheckpoint for each of its individuals. The individuals are streamed 1 at a time so we never need a 2nd copy of the
+// whole species in memory while writing or reading.
+
+type checkpointHeader struct {
+	Restart config.RestartValues
+	NumPopulations uint32
+	Fixation *fixationTracker		// nil unless track_fixation is enabled
+	Trajectories *trajectoryTracker		// nil unless num_trajectories>0 and the alleles have been chosen
+}
+
+type populationCheckpoint struct {
+	TribeNum uint32
+	ParamsTribeNum uint32
+	TargetSize uint32
+	Done bool
+	Split bool
+	BottleNecks *Bottlenecks
+	Num_offspring float64
+	NumIndivs uint32
+
+	// Stats that are reported for the gen the checkpoint was written at
+	ActualAvgOffspring float64
+	PreSelGenoFitnessMean, PreSelGenoFitnessVariance, PreSelGenoFitnessStDev float64
+	EnvironNoise float64
+	NumImmigrants, NumEmigrants uint32
+	NumBackMutations uint32
+	PolygenicFirstGen, PolygenicFixedGen uint32
+}
+
+type individualCheckpoint struct {
+	GenoFitness, PhenoFitness float64
+	Male bool
+	NumMutations uint32
+	NumDeleterious, NumNeutral, NumFavorable uint32
+	NumDelAllele, NumFavAllele uint32
+	PolygenicSeq uint32
+	FamilyId uint64
+	PedigreeId uint64		// the id in mendel.ped, so the individual's offspring can refer to it
+	NodeId int64
+	NewMutnArrays [][]dna.Mutation		// the mutn arrays 1st referenced by this individual's LBs
+	ChromosomesFromDad []dna.ChromosomeCheckpoint
+	ChromosomesFromMom []dna.ChromosomeCheckpoint
+	Mitochondrion dna.ChromosomeCheckpoint
+}
+
+
+// WriteCheckpoint saves everything needed to continue this run after generation genNum: the species, the state of the main random
+// number generator, the next unique mutation id, and the sizes of the output files. It is written to a temporary file first and then
+// renamed, so an interruption while writing it does not clobber the previous checkpoint.
+func (s *Species) WriteCheckpoint(genNum uint32, randSource *random.Xoshiro256) {
+	defer utils.Measure.Start("WriteCheckpoint").Stop("WriteCheckpoint")
+	if err := os.MkdirAll(config.FMgr.DataFilePath, 0755); err != nil { log.Fatalf("Error creating data_file_path %v: %v", config.FMgr.DataFilePath, err) }
+	fileName := config.FMgr.DataFilePath + "/" + config.CHECKPOINT_FILENAME
+	tmpFileName := fileName + ".tmp"
+	file, err := os.Create(tmpFileName)
+	if err != nil { log.Fatalf("Error creating checkpoint file %v: %v", tmpFileName, err) }
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+
+	header := checkpointHeader{
+		Restart: config.RestartValues{
+			Gen_0: genNum,
+			MainRandState: randSource.GetState(),
+			NextUniqueInt: utils.GlobalUniqueInt.GetNextInt(),
+			LastIndivId: lastIndivId,
+			NextNodeId: nextNodeId,
+			NextSiteId: nextSiteId,
+			FileSizes: config.FMgr.GetFileSizes(),
+		},
+		NumPopulations: s.GetNumPopulations(),
+		Fixation: fixation,
+		Trajectories: trajectories,
+	}
+	if err := encoder.Encode(&header); err != nil { log.Fatalf("Error writing checkpoint file %v: %v", tmpFileName, err) }
+
+	table := dna.MutnArrayTableFactory()
+	for _, p := range s.Populations {
+		popCp := p.checkpoint()
+		if err := encoder.Encode(&popCp); err != nil { log.Fatalf("Error writing checkpoint file %v: %v", tmpFileName, err) }
+		for _, indRef := range p.IndivRefs {
+			indCp := indRef.Indiv.checkpoint(table)
+			if err := encoder.Encode(&indCp); err != nil { log.Fatalf("Error writing checkpoint file %v: %v", tmpFileName, err) }
+		}
+	}
+
+	if err := writer.Flush(); err != nil { log.Fatalf("Error writing checkpoint file %v: %v", tmpFileName, err) }
+	if err := file.Close(); err != nil { log.Fatalf("Error closing checkpoint file %v: %v", tmpFileName, err) }
+	if err := os.Rename(tmpFileName, fileName); err != nil { log.Fatalf("Error renaming %v to %v: %v", tmpFileName, fileName, err) }
+	config.Verbose(1, "Wrote checkpoint of generation %d to %v", genNum, fileName)
+}
+
+
+// ReadCheckpoint recreates the species from a checkpoint file written by WriteCheckpoint(), and returns it along with the main random
+// number generator restored to the same point in its sequence. As a side effect it sets config.Restart, restores the next unique
+// mutation id, and cuts the output files back to where they were when the checkpoint was written.
+func ReadCheckpoint(fileName string) (s *Species, uniformRandom *rand.Rand, randSource *random.Xoshiro256) {
+	defer utils.Measure.Start("ReadCheckpoint").Stop("ReadCheckpoint")
+	file, err := os.Open(fileName)
+	if err != nil { log.Fatalf("Error opening checkpoint file %v: %v", fileName, err) }
+	defer file.Close()
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+
+	// Note: gob does not set fields that have the zero value, so we must always decode into a new struct
+	var header checkpointHeader
+	if err := decoder.Decode(&header); err != nil { log.Fatalf("Error reading checkpoint file %v: %v", fileName, err) }
+	config.Restart = &header.Restart
+
+	s = SpeciesFactory()
+	if config.Cfg.Tribes.Tribal_fission {
+		s.Populations = make([]*Population, header.NumPopulations)		// fission adds and removes tribes during the run
+	} else if header.NumPopulations != s.GetNumPopulations() { log.Fatalf("Error: checkpoint file %v has %d tribes, but the input file specifies %d", fileName, header.NumPopulations, s.GetNumPopulations()) }
+	table := dna.MutnArrayTableFactory()
+	for i := range s.Populations {
+		var popCp populationCheckpoint
+		if err := decoder.Decode(&popCp); err != nil { log.Fatalf("Error reading checkpoint file %v: %v", fileName, err) }
+		p := popCp.restore(s.PartsPerPop)
+		part := p.Parts[0]		// all of the restored individuals go in 1 part, the next Mate() will split the work up again
+		part.Indivs = make([]*Individual, 0, popCp.NumIndivs)
+		for j := uint32(0); j < popCp.NumIndivs; j++ {
+			var indCp individualCheckpoint
+			if err := decoder.Decode(&indCp); err != nil { log.Fatalf("Error reading checkpoint file %v: %v", fileName, err) }
+			table.AddRead(indCp.NewMutnArrays)
+			ind := IndividualFactory(part, true)
+			ind.restore(&indCp, table)
+			part.Indivs = append(part.Indivs, ind)
+		}
+		p.makeAndFillIndivRefs()
+		s.Populations[i] = p
+	}
+
+	utils.GlobalUniqueInt.SetNextInt(header.Restart.NextUniqueInt)
+	lastIndivId = header.Restart.LastIndivId
+	nextNodeId, nextSiteId = header.Restart.NextNodeId, header.Restart.NextSiteId
+	fixation = header.Fixation
+	trajectories = header.Trajectories
+	if config.Cfg.Tribes.Tribal_fission { s.restoreTribeFiles() }
+	config.FMgr.RestoreFileSizes(header.Restart.FileSizes)
+	uniformRandom, randSource = random.RestoreRand(header.Restart.MainRandState)
+	config.Verbose(1, "Restored %d individuals from checkpoint %v, continuing after generation %d", s.GetCurrentSize(), fileName, header.Restart.Gen_0)
+	return
+}
+
+
+// checkpoint returns the population-level values to write to a checkpoint file
+func (p *Population) checkpoint() populationCheckpoint {
+	return populationCheckpoint{
+		TribeNum: p.TribeNum,
+		ParamsTribeNum: p.ParamsTribeNum,
+		TargetSize: p.TargetSize,
+		Done: p.Done,
+		Split: p.Split,
+		BottleNecks: p.BottleNecks,
+		Num_offspring: p.Num_offspring,
+		NumIndivs: p.GetCurrentSize(),
+		ActualAvgOffspring: p.ActualAvgOffspring,
+		PreSelGenoFitnessMean: p.PreSelGenoFitnessMean,
+		PreSelGenoFitnessVariance: p.PreSelGenoFitnessVariance,
+		PreSelGenoFitnessStDev: p.PreSelGenoFitnessStDev,
+		EnvironNoise: p.EnvironNoise,
+		NumImmigrants: p.NumImmigrants,
+		NumEmigrants: p.NumEmigrants,
+		NumBackMutations: p.NumBackMutations,
+		PolygenicFirstGen: p.PolygenicFirstGen,
+		PolygenicFixedGen: p.PolygenicFixedGen,
+	}
+}
+
+
+// restore creates a population from the values read from a checkpoint file. The individuals are added by the caller.
+func (cp *populationCheckpoint) restore(partsPerPop uint32) *Population {
+	p := &Population{
+		TribeNum: cp.TribeNum,
+		ParamsTribeNum: cp.ParamsTribeNum,
+		Cfg: config.TribeConfig(cp.ParamsTribeNum),
+		Mdl: TribeModels(cp.ParamsTribeNum),
+		Parts: make([]*PopulationPart, 0, partsPerPop),
+		TargetSize: cp.TargetSize,
+		Done: cp.Done,
+		Split: cp.Split,
+		BottleNecks: cp.BottleNecks,
+		Num_offspring: cp.Num_offspring,
+		LBsPerChromosome: config.Computed.Chromosome_lbs,		// the genome layout comes from the config, which must be the same as the run that wrote the checkpoint
+		ActualAvgOffspring: cp.ActualAvgOffspring,
+		PreSelGenoFitnessMean: cp.PreSelGenoFitnessMean,
+		PreSelGenoFitnessVariance: cp.PreSelGenoFitnessVariance,
+		PreSelGenoFitnessStDev: cp.PreSelGenoFitnessStDev,
+		EnvironNoise: cp.EnvironNoise,
+		NumImmigrants: cp.NumImmigrants,
+		NumEmigrants: cp.NumEmigrants,
+		NumBackMutations: cp.NumBackMutations,
+		PolygenicFirstGen: cp.PolygenicFirstGen,
+		PolygenicFixedGen: cp.PolygenicFixedGen,
+	}
+	p.Parts = append(p.Parts, PopulationPartFactory(0, p))
+	return p
+}
+
+
+// checkpoint returns the contents of this individual to write to a checkpoint file
+func (ind *Individual) checkpoint(table *dna.MutnArrayTable) (cp individualCheckpoint) {
+	cp = individualCheckpoint{
+		GenoFitness: ind.GenoFitness,
+		PhenoFitness: ind.PhenoFitness,
+		Male: ind.Male,
+		NumMutations: ind.NumMutations,
+		NumDeleterious: ind.NumDeleterious,
+		NumNeutral: ind.NumNeutral,
+		NumFavorable: ind.NumFavorable,
+		NumDelAllele: ind.NumDelAllele,
+		NumFavAllele: ind.NumFavAllele,
+		PolygenicSeq: ind.PolygenicSeq,
+		FamilyId: ind.FamilyId,
+		PedigreeId: pedigreeIds[ind],
+		NodeId: ind.NodeId,
+		ChromosomesFromDad: make([]dna.ChromosomeCheckpoint, len(ind.ChromosomesFromDad)),
+		ChromosomesFromMom: make([]dna.ChromosomeCheckpoint, len(ind.ChromosomesFromMom)),
+	}
+	for c := range ind.ChromosomesFromDad { cp.ChromosomesFromDad[c] = ind.ChromosomesFromDad[c].Checkpoint(table) }
+	for c := range ind.ChromosomesFromMom { cp.ChromosomesFromMom[c] = ind.ChromosomesFromMom[c].Checkpoint(table) }
+	cp.Mitochondrion = ind.Mitochondrion.Checkpoint(table)
+	cp.NewMutnArrays = table.TakeNew()
+	return
+}
+
+
+// restore sets the contents of this individual from what was read from a checkpoint file
+func (ind *Individual) restore(cp *individualCheckpoint, table *dna.MutnArrayTable) {
+	if len(cp.ChromosomesFromDad) != len(ind.ChromosomesFromDad) || len(cp.ChromosomesFromMom) != len(ind.ChromosomesFromMom) {
+		log.Fatalf("Error: checkpoint individual has %d chromosomes, but this run has %d", len(cp.ChromosomesFromDad), len(ind.ChromosomesFromDad))
+	}
+	ind.GenoFitness = cp.GenoFitness
+	ind.PhenoFitness = cp.PhenoFitness
+	ind.Male = cp.Male
+	ind.NumMutations = cp.NumMutations
+	ind.NumDeleterious = cp.NumDeleterious
+	ind.NumNeutral = cp.NumNeutral
+	ind.NumFavorable = cp.NumFavorable
+	ind.NumDelAllele = cp.NumDelAllele
+	ind.NumFavAllele = cp.NumFavAllele
+	ind.PolygenicSeq = cp.PolygenicSeq
+	ind.FamilyId = cp.FamilyId
+	if cp.PedigreeId != 0 {
+		if pedigreeIds == nil { pedigreeIds = make(map[*Individual]uint64) }
+		pedigreeIds[ind] = cp.PedigreeId
+	}
+	ind.NodeId = cp.NodeId
+	for c := range cp.ChromosomesFromDad { ind.ChromosomesFromDad[c].Restore(&cp.ChromosomesFromDad[c], table) }
+	for c := range cp.ChromosomesFromMom { ind.ChromosomesFromMom[c].Restore(&cp.ChromosomesFromMom[c], table) }
+	ind.Mitochondrion.Restore(&cp.Mitochondrion, table)
+}
@@ -0,0 +1,130 @@
+package pop
+
+import (
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"log"
+	"math"
+	"math/rand"
+)
+
+// Tribal competition (tribal_competition=true) is group selection between the tribes: the total carrying capacity of the species is reapportioned
+// among the tribes every generation according to their relative fitness, so fitter tribes grow at the expense of less fit tribes.
+
+// IsTribalCompetitionEnabled returns true if the tribes compete with each other for the carrying capacity of the species in this run
+func IsTribalCompetitionEnabled() bool {
+	return (config.Cfg.Tribes.Num_tribes > 1 || config.Cfg.Tribes.Tribal_fission) && config.Cfg.Tribes.Tribal_competition
+}
+
+
+// ValidateTribalCompetition checks the tribal competition config values
+func ValidateTribalCompetition(c *config.Config) {
+	if c.Tribes.Tc_scaling_factor < 0.0 { log.Fatalln("tc_scaling_factor can not be < 0.0") }
+	if c.Tribes.Group_heritability <= 0.0 || c.Tribes.Group_heritability > 1.0 { log.Fatalln("group_heritability must be > 0.0 and <= 1.0") }
+	if c.Tribes.Social_bonus_factor < 0.0 { log.Fatalln("social_bonus_factor can not be < 0.0") }
+}
+
+
+// tribalFitness returns the fitness of each tribe (indexed like s.Populations) that is used for competition between the tribes. This is the mean
+// fitness of the tribe with its deviation from 1.0 multiplied by social_bonus_factor, plus random noise according to group_heritability.
+// Tribes that are done have a fitness of 0.0.
+func (s *Species) tribalFitness(uniformRandom *rand.Rand) (fitness []float64) {
+	fitness = make([]float64, len(s.Populations))
+	var sum, sumSqr float64
+	numTribes := 0
+	for i, p := range s.Populations {
+		if p.Done { continue }
+		meanFit, _, _, _, _ := p.GetFitnessStats()
+		fitness[i] = 1.0 + config.Cfg.Tribes.Social_bonus_factor * (meanFit - 1.0)
+		sum += fitness[i]
+		sumSqr += fitness[i] * fitness[i]
+		numTribes++
+	}
+
+	// Like heritability for individuals, group_heritability is the fraction of the variance of tribal fitness that comes from the tribes' genomes
+	herit := config.Cfg.Tribes.Group_heritability
+	if herit < 1.0 && numTribes > 1 {
+		mean := sum / float64(numTribes)
+		variance := math.Max(0.0, sumSqr / float64(numTribes) - mean * mean)
+		noise := math.Sqrt(variance * (1.0-herit) / herit)
+		for i, p := range s.Populations {
+			if p.Done { continue }
+			fitness[i] += uniformRandom.NormFloat64() * noise
+		}
+	}
+	return
+}
+
+
+// TribalCompetition reapportions the total target size of the next generation's tribes in proportion to each tribe's target size times
+// (tribe fitness / mean tribe fitness) ^ tc_scaling_factor, using the fitness of the parent tribes. A tribe whose new target size falls below
+// min_tribe_size is absorbed into the fittest tribe: its individuals join that tribe before mating and it stops as a separate tribe.
+func (parentS *Species) TribalCompetition(childrenS *Species, genNum uint32, uniformRandom *rand.Rand) {
+	if !IsTribalCompetitionEnabled() || genNum <= 1 { return }		// the genesis tribes do not have their fitness calculated, so they start out equal
+	defer utils.Measure.Start("TribalCompetition").Stop("TribalCompetition")
+
+	fitness := parentS.tribalFitness(uniformRandom)
+	var totalSize uint32
+	var meanFitness float64
+	numTribes := 0
+	for i, p := range childrenS.Populations {
+		if p.Done { continue }
+		totalSize += p.TargetSize
+		meanFitness += fitness[i]
+		numTribes++
+	}
+	if numTribes < 2 { return }
+	meanFitness = meanFitness / float64(numTribes)
+	if meanFitness <= 0.0 { return }
+
+	weights := make([]float64, len(childrenS.Populations))
+	var totalWeight float64
+	fittest := -1
+	for i, p := range childrenS.Populations {
+		if p.Done { continue }
+		if fittest < 0 || fitness[i] > fitness[fittest] { fittest = i }
+		if fitness[i] <= 0.0 { continue }		// a tribe with no fitness gets no share
+		weights[i] = float64(p.TargetSize) * math.Pow(fitness[i] / meanFitness, config.Cfg.Tribes.Tc_scaling_factor)
+		totalWeight += weights[i]
+	}
+	if totalWeight <= 0.0 { return }
+	for i, p := range childrenS.Populations {
+		if p.Done { continue }
+		p.TargetSize = uint32(utils.RoundInt(float64(totalSize) * weights[i] / totalWeight))
+		config.Verbose(3, "Gen %d: tribe %d has tribal fitness %v and target size %d", genNum, p.TribeNum, fitness[i], p.TargetSize)
+	}
+
+	for i, p := range childrenS.Populations {
+		if p.Done || i == fittest || p.TargetSize >= config.Cfg.Tribes.Min_tribe_size { continue }
+		parentS.absorbTribe(childrenS, i, fittest, genNum)
+	}
+}
+
+
+// absorbTribe moves all of the individuals of parent tribe srcIndex into parent tribe destIndex (before they mate), gives the target size of
+// the tribe to the destination tribe, and marks the tribe as done.
+func (parentS *Species) absorbTribe(childrenS *Species, srcIndex, destIndex int, genNum uint32) {
+	src := parentS.Populations[srcIndex]
+	dest := parentS.Populations[destIndex]
+	for _, indRef := range src.IndivRefs {
+		indRef.Indiv.popPart = dest.Parts[0]		// so the indiv gets the attributes of its new tribe when it mates
+		dest.Parts[0].Indivs = append(dest.Parts[0].Indivs, indRef.Indiv)		// so the part it now belongs to has it, like the part it was created in
+		dest.IndivRefs = append(dest.IndivRefs, indRef)
+	}
+	log.Printf("Gen %d: tribe %d fell below min_tribe_size and its %d individuals were absorbed into tribe %d", genNum, src.TribeNum, len(src.IndivRefs), dest.TribeNum)
+	src.IndivRefs = nil
+	src.Done = true
+	src.invalidateStats()
+	dest.invalidateStats()
+	childrenS.Populations[destIndex].TargetSize += childrenS.Populations[srcIndex].TargetSize
+	childrenS.Populations[srcIndex] = src		// this is what PopulationFactory() does for tribes that are done
+}
+
+
+// GetNumActiveTribes returns the number of tribes that are not done
+func (s *Species) GetNumActiveTribes() (numTribes uint32) {
+	for _, p := range s.Populations {
+		if !p.Done { numTribes++ }
+	}
+	return
+}
@@ -0,0 +1,42 @@
+package pop
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+)
+
+// Fitter tribes must get a larger share of the carrying capacity, and a tribe that falls below min_tribe_size must be absorbed by the fittest tribe
+func TestTribalCompetition(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Tribes.Num_tribes = 3
+		c.Tribes.Tribal_competition = true
+		c.Tribes.Tc_scaling_factor = 1.0
+		c.Tribes.Min_tribe_size = 15
+	})
+	uniformRandom := rand.New(rand.NewSource(1))
+	parentS := SpeciesFactory()
+	tribeFitness := []float64{1.0, 0.9, 0.5}
+	for i := range parentS.Populations {
+		parentS.Populations[i] = PopulationFactory(nil, 0, uint32(i+1), parentS.PartsPerPop)
+		for _, indRef := range parentS.Populations[i].IndivRefs { indRef.Indiv.GenoFitness = tribeFitness[i] }
+	}
+	absorbed := parentS.Populations[2].IndivRefs
+	childrenS := parentS.GetNextGeneration(2)
+	parentS.TribalCompetition(childrenS, 2, uniformRandom)
+
+	// With tc_scaling_factor 1.0 the shares are proportional to the fitness: 25, 22.5, 12.5 of the total of 60
+	if childrenS.Populations[1].TargetSize < 22 || childrenS.Populations[1].TargetSize > 23 { t.Errorf("Tribe 2 has target size %d, expected 22 or 23", childrenS.Populations[1].TargetSize) }
+	if !parentS.Populations[2].Done || childrenS.Populations[2] != parentS.Populations[2] || parentS.Populations[2].GetCurrentSize() != 0 { t.Errorf("Tribe 3 fell below min_tribe_size, but was not absorbed") }
+	if parentS.Populations[0].GetCurrentSize() != 40 { t.Errorf("Tribe 1 has %d individuals after absorbing tribe 3, expected 40", parentS.Populations[0].GetCurrentSize()) }
+	for _, indRef := range parentS.Populations[0].IndivRefs {
+		if indRef.Indiv.popPart.Pop != parentS.Populations[0] { t.Errorf("Absorbed individual does not point to its new tribe") }
+	}
+	for _, indRef := range absorbed {
+		if !partHasIndiv(indRef.Indiv.popPart, indRef.Indiv) { t.Errorf("Absorbed individual is not in the part of its new tribe") }
+	}
+	if size := childrenS.Populations[0].TargetSize; size < 37 || size > 38 { t.Errorf("Tribe 1 has target size %d after absorbing tribe 3, expected 37 or 38", size) }
+	if childrenS.GetNumActiveTribes() != 2 { t.Errorf("There are %d active tribes, expected 2", childrenS.GetNumActiveTribes()) }
+}
@@ -0,0 +1,144 @@
+package pop
+
+import (
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"log"
+	"math/rand"
+	"sort"
+)
+
+// Tribal fission (tribal_fission=true) splits a tribe that has outgrown fission_pop_size into 2 new tribes, which models a founder event
+// arising from within the simulation. The original tribe stops, its output files are closed, and the 2 new tribes (with new tribe numbers) are
+// added to the end of Species.Populations. The original tribe is removed from the species at the start of the next gen (it is kept until then
+// because the rest of this gen's processing still needs the offspring it had). No tribe splits once there are max_tribes active tribes.
+
+type FissionModelType string
+const (
+	RANDOM_FISSION FissionModelType = "random"		// the individuals are divided randomly
+	KINSHIP_FISSION FissionModelType = "kinship"		// individuals from the same paternal line (FamilyId) stay together
+)
+
+// Algorithms for dividing the individuals of a tribe into the 2 groups that will become new tribes
+type SplitTribeType func(p *Population, uniformRandom *rand.Rand) (group1, group2 []IndivRef)
+
+
+// RandomSplitTribe shuffles the individuals of the tribe and divides them in half
+func RandomSplitTribe(p *Population, uniformRandom *rand.Rand) (group1, group2 []IndivRef) {
+	refs := make([]IndivRef, len(p.IndivRefs))
+	copy(refs, p.IndivRefs)
+	uniformRandom.Shuffle(len(refs), func(i, j int) { refs[i], refs[j] = refs[j], refs[i] })
+	half := len(refs) / 2
+	return refs[:half], refs[half:]
+}
+
+
+// KinshipSplitTribe sorts the individuals of the tribe by the founder of their paternal line and divides them in half, so related
+// individuals stay together (except the 1 family that straddles the middle).
+func KinshipSplitTribe(p *Population, _ *rand.Rand) (group1, group2 []IndivRef) {
+	refs := make([]IndivRef, len(p.IndivRefs))
+	copy(refs, p.IndivRefs)
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].Indiv.FamilyId < refs[j].Indiv.FamilyId })
+	half := len(refs) / 2
+	return refs[:half], refs[half:]
+}
+
+
+// Fission splits every tribe whose size after selection is more than fission_pop_size into 2 new tribes. This is called after selection
+// (and migration), so the new tribes mate separately starting in the next gen.
+func (s *Species) Fission(genNum uint32, uniformRandom *rand.Rand) {
+	if !config.Cfg.Tribes.Tribal_fission { return }
+	defer utils.Measure.Start("Fission").Stop("Fission")
+	numPops := len(s.Populations)		// the new tribes are appended, and are not candidates for fission until the next gen
+	for i := 0; i < numPops; i++ {
+		p := s.Populations[i]
+		if p.Done || p.GetCurrentSize() <= config.Cfg.Tribes.Fission_pop_size { continue }
+		if config.Cfg.Tribes.Max_tribes > 0 && s.GetNumActiveTribes() >= config.Cfg.Tribes.Max_tribes {
+			config.Verbose(2, "Gen %d: tribe %d (size %d) did not split because there are already max_tribes (%d) tribes", genNum, p.TribeNum, p.GetCurrentSize(), config.Cfg.Tribes.Max_tribes)
+			continue
+		}
+		group1, group2 := Mdl.SplitTribe(p, uniformRandom)
+		tribe1 := s.addTribe(p, group1)
+		tribe2 := s.addTribe(p, group2)
+		log.Printf("Gen %d: tribe %d (size %d) split into tribe %d (size %d) and tribe %d (size %d)", genNum, p.TribeNum, p.GetCurrentSize(), tribe1.TribeNum, tribe1.GetCurrentSize(), tribe2.TribeNum, tribe2.GetCurrentSize())
+		p.IndivRefs = nil
+		p.Done = true
+		p.Split = true
+		p.invalidateStats()
+		config.FMgr.RemoveTribe(p.TribeNum)
+	}
+}
+
+
+// addTribe creates a new tribe from the individuals of parent tribe p, adds it to the species, and opens its output files
+func (s *Species) addTribe(p *Population, refs []IndivRef) *Population {
+	newP := &Population{
+		TribeNum: s.nextTribeNum(),
+		ParamsTribeNum: p.ParamsTribeNum,		// the new tribe keeps the parameters of the tribe it came from
+		Cfg: p.Cfg,
+		Mdl: p.Mdl,
+		Parts: make([]*PopulationPart, 0, s.PartsPerPop),
+		IndivRefs: refs,
+		TargetSize: uint32(len(refs)),		// the pop growth model grows the new tribe from here
+		Num_offspring: p.Num_offspring,
+		LBsPerChromosome: p.LBsPerChromosome,
+		ActualAvgOffspring: p.ActualAvgOffspring,
+		PreSelGenoFitnessMean: p.PreSelGenoFitnessMean,
+		PreSelGenoFitnessVariance: p.PreSelGenoFitnessVariance,
+		PreSelGenoFitnessStDev: p.PreSelGenoFitnessStDev,
+		EnvironNoise: p.EnvironNoise,
+		PolygenicFirstGen: p.PolygenicFirstGen,
+		PolygenicFixedGen: p.PolygenicFixedGen,
+	}
+	if p.BottleNecks != nil {
+		bottleNecks := *p.BottleNecks		// each tribe moves thru the bottlenecks separately
+		newP.BottleNecks = &bottleNecks
+	}
+	for i := uint32(1); i <= s.PartsPerPop; i++ { newP.Parts = append(newP.Parts, PopulationPartFactory(0, newP)) }
+	for _, indRef := range refs {
+		indRef.Indiv.popPart = newP.Parts[0]		// so the indivs get the attributes of their new tribe when they mate
+		newP.Parts[0].Indivs = append(newP.Parts[0].Indivs, indRef.Indiv)
+	}
+	s.Populations = append(s.Populations, newP)
+
+	config.FMgr.AddTribe(newP.TribeNum)
+	newP.ReportInitial()
+	return newP
+}
+
+
+// nextTribeNum returns the tribe number for a new tribe. The tribes that were split are removed, but they always have lower numbers than the
+// tribes they were split into, so 1 more than the highest current number has never been used.
+func (s *Species) nextTribeNum() (tribeNum uint32) {
+	for _, p := range s.Populations {
+		if p.TribeNum > tribeNum { tribeNum = p.TribeNum }
+	}
+	return tribeNum + 1
+}
+
+
+// removeSplitTribes removes the tribes that were split by Fission() in the previous gen from the species
+func (s *Species) removeSplitTribes() {
+	if !config.Cfg.Tribes.Tribal_fission { return }
+	pops := s.Populations[:0]
+	for _, p := range s.Populations {
+		if !p.Split { pops = append(pops, p) }
+	}
+	s.Populations = pops
+}
+
+
+// restoreTribeFiles makes the open output files match the tribes restored from a checkpoint. FileMgrFactory() opened the files of the
+// num_tribes initial tribes, but fission may have split some of them and added others.
+func (s *Species) restoreTribeFiles() {
+	active := make(map[uint32]bool)
+	for _, p := range s.Populations {
+		if !p.Split { active[p.TribeNum] = true }
+	}
+	for tribeNum := uint32(1); tribeNum <= config.Cfg.Tribes.Num_tribes; tribeNum++ {
+		if !active[tribeNum] { config.FMgr.RemoveTribe(tribeNum) }
+	}
+	for _, p := range s.Populations {
+		if active[p.TribeNum] && p.TribeNum > config.Cfg.Tribes.Num_tribes { config.FMgr.AddTribe(p.TribeNum) }
+	}
+}
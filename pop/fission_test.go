@@ -0,0 +1,72 @@
+package pop
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+)
+
+// A tribe that is bigger than fission_pop_size must split into 2 new tribes, and the kinship model must keep families together
+func TestFission(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Tribes.Tribal_fission = true
+		c.Tribes.Fission_pop_size = 10
+		c.Tribes.Fission_model = string(KINSHIP_FISSION)
+	})
+	config.FileMgrFactory(t.TempDir(), config.FITNESS_FILENAME)
+	s := SpeciesFactory()
+	s.Populations[0] = PopulationFactory(nil, 0, 1, s.PartsPerPop)
+	for i, indRef := range s.Populations[0].IndivRefs { indRef.Indiv.FamilyId = uint64(i % 2) }		// 2 families, interleaved
+
+	s.Fission(1, rand.New(rand.NewSource(1)))
+	if len(s.Populations) != 3 { t.Fatalf("There are %d tribes after fission, expected 3", len(s.Populations)) }
+	if !s.Populations[0].Done || s.Populations[0].GetCurrentSize() != 0 { t.Errorf("The original tribe should be done and empty after fission") }
+	if config.FMgr.GetFile(config.FITNESS_FILENAME, 1) != nil { t.Errorf("The output files of the original tribe are still open after fission") }
+	for _, p := range s.Populations[1:] {
+		if p.GetCurrentSize() != 10 { t.Errorf("Tribe %d has %d individuals, expected 10", p.TribeNum, p.GetCurrentSize()) }
+		if config.FMgr.GetFile(config.FITNESS_FILENAME, p.TribeNum) == nil { t.Errorf("The output files of new tribe %d are not open", p.TribeNum) }
+		for _, indRef := range p.IndivRefs {
+			if indRef.Indiv.popPart.Pop != p { t.Errorf("Individual does not point to its new tribe %d", p.TribeNum) }
+			if !partHasIndiv(indRef.Indiv.popPart, indRef.Indiv) { t.Errorf("Individual is not in the part of its new tribe %d", p.TribeNum) }
+			if indRef.Indiv.FamilyId != p.IndivRefs[0].Indiv.FamilyId { t.Errorf("Tribe %d has individuals from more than 1 family", p.TribeNum) }
+		}
+	}
+
+	s.Fission(2, rand.New(rand.NewSource(1)))		// the new tribes are not bigger than fission_pop_size
+	if len(s.Populations) != 3 { t.Errorf("There are %d tribes after the 2nd fission, expected 3", len(s.Populations)) }
+
+	// The split tribe is removed at the start of the next gen
+	childrenS := s.GetNextGeneration(3)
+	if len(s.Populations) != 2 || len(childrenS.Populations) != 2 { t.Fatalf("There are %d parent tribes and %d children tribes after the split tribe was removed, expected 2", len(s.Populations), len(childrenS.Populations)) }
+	for i, p := range childrenS.Populations {
+		if p.TribeNum != uint32(i+2) { t.Errorf("Children tribe %d has tribe number %d, expected %d", i, p.TribeNum, i+2) }
+	}
+}
+
+// No tribe may split once there are max_tribes active tribes
+func TestFissionMaxTribes(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Tribes.Tribal_fission = true
+		c.Tribes.Fission_pop_size = 4
+		c.Tribes.Max_tribes = 3
+	})
+	config.FileMgrFactory(t.TempDir(), "")
+	s := SpeciesFactory()
+	s.Populations[0] = PopulationFactory(nil, 0, 1, s.PartsPerPop)
+	uniformRandom := rand.New(rand.NewSource(1))
+	s.Fission(1, uniformRandom)
+	s.removeSplitTribes()
+	s.Fission(2, uniformRandom)		// only 1 of the 2 tribes can split before there are max_tribes
+	s.removeSplitTribes()
+	if numTribes := s.GetNumActiveTribes(); numTribes != 3 || len(s.Populations) != 3 { t.Fatalf("There are %d active tribes of %d, expected 3", numTribes, len(s.Populations)) }
+	seen := make(map[uint32]bool)
+	for _, p := range s.Populations {
+		if seen[p.TribeNum] { t.Errorf("Tribe number %d is used twice", p.TribeNum) }
+		seen[p.TribeNum] = true
+	}
+	s.Fission(3, uniformRandom)
+	if len(s.Populations) != 3 { t.Errorf("There are %d tribes after fission with max_tribes tribes, expected 3", len(s.Populations)) }
+}
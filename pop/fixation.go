@@ -0,0 +1,197 @@
+package pop
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"log"
+	"math"
+	"sort"
+)
+
+// Fixation tracking (track_fixation=true) follows every new tracked mutation from the generation it arose in until it is lost from the species or
+// fixed (carried by every chromosome of every individual), and writes its fate to mendel.fix. At the end of the run it adds a summary of the
+// observed fixation probability of each effect size class next to Kimura's theoretical fixation probability, so they can be compared.
+// Each line of mendel.fix is:  id type fitness_effect origin_gen fate fate_gen
+
+const FIXATION_HEADER = "# id type fitness_effect origin_gen fate fate_gen\n"
+
+// The mutations are grouped into these classes by type and size of their fitness effect. The deleterious and favorable classes are decades of |fitness_effect|.
+var FIXATION_CLASS_NAMES = []string{"neutral",
+	"del <1e-5", "del 1e-5:1e-4", "del 1e-4:1e-3", "del 1e-3:1e-2", "del >=1e-2",
+	"fav <1e-5", "fav 1e-5:1e-4", "fav 1e-4:1e-3", "fav 1e-3:1e-2", "fav >=1e-2"}
+const NUM_FIXATION_DECADES = 5
+
+// fixationRecord is a mutation that is still segregating in the species
+type fixationRecord struct {
+	Type dna.MutationType
+	FitnessEffect float32
+	OriginGen uint32
+}
+
+// fixationClassStats are the totals for 1 effect size class
+type fixationClassStats struct {
+	NumMutations, NumFixed, NumLost uint32
+	SumKimuraProb float64		// divide by NumMutations to get the mean theoretical fixation probability of the class
+}
+
+// fixationTracker is all of the fixation tracking state, which is saved in the checkpoint
+type fixationTracker struct {
+	Active map[uint64]fixationRecord
+	Classes []fixationClassStats
+}
+
+// fixation is the fixation tracking state of the run, or nil if fixation tracking is not enabled
+var fixation *fixationTracker
+
+
+// IsFixationEnabled returns true if the fate of each new mutation should be tracked and written to mendel.fix
+func IsFixationEnabled() bool { return config.FMgr.IsFile(config.FIXATION_FILENAME) }
+
+
+func fixationTrackerFactory() *fixationTracker {
+	return &fixationTracker{Active: make(map[uint64]fixationRecord), Classes: make([]fixationClassStats, len(FIXATION_CLASS_NAMES))}
+}
+
+
+// KimuraFixationProb returns Kimura's probability that a mutation with selection coefficient s (the fitness effect of 1 copy, negative for
+// deleterious) and starting frequency p fixes in a population of n individuals: (1 - exp(-4Nsp)) / (1 - exp(-4Ns)).
+func KimuraFixationProb(s float64, n uint32, p float64) float64 {
+	if n == 0 { return 0.0 }
+	N := float64(n)
+	if math.Abs(4.0 * N * s) < 1.e-9 { return p }		// the neutral limit
+	return math.Expm1(-4.0 * N * s * p) / math.Expm1(-4.0 * N * s)
+}
+
+
+// fixationClass returns the index in FIXATION_CLASS_NAMES of the effect size class of a mutation
+func fixationClass(mType dna.MutationType, fitnessEffect float32) int {
+	var first int
+	switch mType {
+	case dna.DELETERIOUS_DOMINANT, dna.DELETERIOUS_RECESSIVE, dna.DEL_ALLELE:
+		first = 1
+	case dna.FAVORABLE_DOMINANT, dna.FAVORABLE_RECESSIVE, dna.FAV_ALLELE:
+		first = 1 + NUM_FIXATION_DECADES
+	default:
+		return 0
+	}
+	s := math.Abs(float64(fitnessEffect))
+	if s == 0.0 { return 0 }
+	decade := int(math.Floor(math.Log10(s))) + 6		// 1e-5:1e-4 is decade 1, and anything smaller is decade 0
+	decade = utils.MinInt(utils.MaxInt(decade, 0), NUM_FIXATION_DECADES-1)
+	return first + decade
+}
+
+
+// recordFixationMutation notes the new mutation with id mutId in LB lbInChr of chromo, if it is tracked, so TrackFixation() can start following it
+func (child *Individual) recordFixationMutation(chromo *dna.Chromosome, lbInChr int, mutId uint64) {
+	mutns := chromo.LinkageBlocks[lbInChr].GetMutations()
+	if len(mutns) == 0 || mutns[len(mutns)-1].Id != mutId { return }
+	child.popPart.newMutns = append(child.popPart.newMutns, mutns[len(mutns)-1])
+}
+
+
+// TrackFixation starts following the mutations that arose in this generation, counts the copies of each mutation being followed, and writes the
+// mutations that were lost or fixed. This is called after selection (and migration/fission) each generation. At the end of the run it also
+// writes the mutations that are still segregating and the summary by effect size class.
+func (s *Species) TrackFixation(genNum uint32, lastGen bool) {
+	if !IsFixationEnabled() { return }
+	defer utils.Measure.Start("TrackFixation").Stop("TrackFixation")
+	if fixation == nil { fixation = fixationTrackerFactory() }		// restarted from a checkpoint of a run that was not tracking fixation
+	fixFile := config.FMgr.GetFile(config.FIXATION_FILENAME, 0)
+	writer := bufio.NewWriter(fixFile)
+	if genNum == 1 && config.Restart == nil {
+		if _, err := writer.WriteString(FIXATION_HEADER); err != nil { log.Fatalf("Error writing %v: %v", config.FIXATION_FILENAME, err) }
+	}
+
+	// Start following the new mutations. This includes the tribes that are done, because fission makes the tribe it splits done after mating.
+	for _, p := range s.Populations {
+		// A new mutation starts out as 1 copy among all of the offspring born, which selection then culls down to the pop size
+		var numBorn int
+		for _, part := range p.Parts { numBorn += part.NextIndivIndex }
+		startFreq := 1.0 / float64(2 * utils.MaxInt(numBorn, 1))
+		for _, part := range p.Parts {
+			for _, m := range part.newMutns {
+				fixation.Active[m.Id] = fixationRecord{Type: m.Type, FitnessEffect: m.FitnessEffect, OriginGen: genNum}
+				class := &fixation.Classes[fixationClass(m.Type, m.FitnessEffect)]
+				class.NumMutations++
+				class.SumKimuraProb += KimuraFixationProb(float64(m.FitnessEffect), p.TargetSize, startFreq)
+			}
+			part.newMutns = nil
+		}
+	}
+
+	// Count the copies of each mutation we are following in the whole species. The mutations on the sex chromosomes stay on the X (or Z) or
+	// Y (or W) they arose on, so record which they are on to know how many copies would make them fixed.
+	counts := make(map[uint64]uint32)
+	sexLinked := make(map[uint64]bool)		// true if the mutation is on the Y (or W)
+	for _, p := range s.Populations {
+		for _, indRef := range p.IndivRefs {
+			for i, chromosomes := range [][]dna.Chromosome{indRef.Indiv.ChromosomesFromDad, indRef.Indiv.ChromosomesFromMom} {
+				for c := range chromosomes {
+					isSexChromosome := config.Cfg.Population.Separate_sexes && c == sexChromosome()
+					for lb := range chromosomes[c].LinkageBlocks {
+						for _, m := range chromosomes[c].LinkageBlocks[lb].GetMutations() {
+							if _, ok := fixation.Active[m.Id]; ok {
+								counts[m.Id]++
+								if isSexChromosome { sexLinked[m.Id] = indRef.Indiv.onHeteroChromosome(c, i == 0) }
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Write the mutations that were lost or fixed, in id order so the output is reproducible
+	totalCopies := 2 * s.GetCurrentSize()
+	var males, females uint32
+	if config.Cfg.Population.Separate_sexes { males, females = s.GetSexCounts() }
+	ids := make([]uint64, 0, len(fixation.Active))
+	for id := range fixation.Active { ids = append(ids, id) }
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		rec := fixation.Active[id]
+		class := &fixation.Classes[fixationClass(rec.Type, rec.FitnessEffect)]
+		copies := totalCopies
+		if onHetero, ok := sexLinked[id]; ok { copies = sexChromosomeCopies(onHetero, males, females) }
+		var fate string
+		switch count := counts[id]; {
+		case count == 0:
+			fate = "lost"
+			class.NumLost++
+		case count >= copies:
+			fate = "fixed"
+			class.NumFixed++
+		case lastGen:
+			fate = "segregating"
+		default:
+			continue
+		}
+		if _, err := fmt.Fprintf(writer, "%d %s %v %d %s %d\n", id, rec.Type.Name(), rec.FitnessEffect, rec.OriginGen, fate, genNum); err != nil { log.Fatalf("Error writing %v: %v", config.FIXATION_FILENAME, err) }
+		delete(fixation.Active, id)
+	}
+
+	if lastGen { fixation.writeSummary(writer) }
+	if err := writer.Flush(); err != nil { log.Fatalf("Error writing %v: %v", config.FIXATION_FILENAME, err) }		// flush every gen to support restart
+}
+
+
+// writeSummary writes the observed and theoretical fixation probabilities of each effect size class as comments at the end of mendel.fix.
+// The observed probability only includes the mutations whose fate is known.
+func (f *fixationTracker) writeSummary(writer *bufio.Writer) {
+	lines := []string{"#\n", "# Fixation probabilities by effect size class. kimura_prob is the mean of Kimura's probability for each mutation, using the size of the tribe it arose in and a starting frequency of 1 copy among the offspring born that gen.\n",
+		"# class num_mutations num_fixed num_lost num_segregating observed_prob kimura_prob\n"}
+	for i, class := range f.Classes {
+		if class.NumMutations == 0 { continue }
+		observed := 0.0
+		if class.NumFixed + class.NumLost > 0 { observed = float64(class.NumFixed) / float64(class.NumFixed + class.NumLost) }
+		kimura := class.SumKimuraProb / float64(class.NumMutations)
+		lines = append(lines, fmt.Sprintf("# %q %d %d %d %d %.6g %.6g\n", FIXATION_CLASS_NAMES[i], class.NumMutations, class.NumFixed, class.NumLost, class.NumMutations - class.NumFixed - class.NumLost, observed, kimura))
+	}
+	for _, line := range lines {
+		if _, err := writer.WriteString(line); err != nil { log.Fatalf("Error writing %v: %v", config.FIXATION_FILENAME, err) }
+	}
+}
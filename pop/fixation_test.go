@@ -0,0 +1,36 @@
+package pop
+
+import (
+	"math"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/dna"
+)
+
+// Kimura's fixation probability must be 1/2N for a neutral mutation, about 2s for a strongly favorable one, and tiny for a strongly deleterious one
+func TestKimuraFixationProb(t *testing.T) {
+	startFreq := 1.0 / 2000.0
+	if p := KimuraFixationProb(0.0, 1000, startFreq); p != startFreq { t.Errorf("Neutral fixation probability is %v, expected %v", p, startFreq) }
+	if p := KimuraFixationProb(0.01, 1000, startFreq); math.Abs(p - 2.0*0.01) > 0.001 { t.Errorf("Favorable fixation probability is %v, expected about %v", p, 2.0*0.01) }
+	if p := KimuraFixationProb(-0.01, 1000, startFreq); p < 0.0 || p > 1.e-15 { t.Errorf("Deleterious fixation probability is %v, expected about 0", p) }
+	if p, q := KimuraFixationProb(1.e-5, 1000, startFreq), KimuraFixationProb(-1.e-5, 1000, startFreq); !(q < startFreq && startFreq < p) { t.Errorf("Nearly neutral fixation probabilities %v and %v should be on either side of 1/2N", p, q) }
+}
+
+// The effect size classes must be the decades of |fitness effect|, with the extremes in the 1st and last class
+func TestFixationClass(t *testing.T) {
+	tests := []struct {
+		mType dna.MutationType
+		fitnessEffect float32
+		class string
+	}{
+		{dna.NEUTRAL, 0.0, "neutral"},
+		{dna.DELETERIOUS_DOMINANT, -1.e-8, "del <1e-5"},
+		{dna.DELETERIOUS_RECESSIVE, -5.e-4, "del 1e-4:1e-3"},
+		{dna.DELETERIOUS_DOMINANT, -0.5, "del >=1e-2"},
+		{dna.FAVORABLE_DOMINANT, 2.e-5, "fav 1e-5:1e-4"},
+		{dna.FAVORABLE_RECESSIVE, 3.e-3, "fav 1e-3:1e-2"},
+	}
+	for _, tt := range tests {
+		if class := FIXATION_CLASS_NAMES[fixationClass(tt.mType, tt.fitnessEffect)]; class != tt.class { t.Errorf("Mutation with fitness effect %v is in class %q, expected %q", tt.fitnessEffect, class, tt.class) }
+	}
+}
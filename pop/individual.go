@@ -6,6 +6,7 @@ import (
 	"github.com/genetic-algorithms/mendel-go/utils"
 	"github.com/genetic-algorithms/mendel-go/random"
 	"log"
+	"math"
 	"math/rand"
 )
 
@@ -16,15 +17,22 @@ type Individual struct {
 	GenoFitness     float64		// fitness due to genomic mutations
 	PhenoFitness     float64		// fitness due to GenoFitness plus environmental noise and selection noise
 	Dead            bool 		// if true, selection has identified it for elimination
+	Male bool		// the sex of this individual, only used when separate_sexes==true
 	NumMutations uint32		// keep a running total of the mutations. This is both mutations and initial alleles.
 
 	// Note: we currently don't really need to cache these, because p.GetMutationStats caches its values, and the only other function that currently uses these is ind.Report() which only gets called for small populations.
 	//		But it would only save 0.56 MB for 10,000 population, so let's wait and see if we need them cached for more stats in the future.
 	NumDeleterious, NumNeutral, NumFavorable uint32		// cache some of the stats we usually gather
 	NumDelAllele, NumFavAllele uint32		// cache some of the stats we usually gather about initial alleles
+	PolygenicSeq uint32		// the nucleotides of the polygenic trait (see encodePolygenic()), only used when polygenic_beneficials==true. It fits in the padding after the counts above.
+	FamilyId uint64		// identifies the genesis individual this individual descends from in its paternal line, used by fission_model==kinship
+	NodeId int64		// the tree sequence node of the chromosomes from dad (the chromosomes from mom are NodeId+1). Only used when track_tree_sequence==true.
+	treeSeqEdges []treeSeqEdge		// the LB sections inherited from each parent node, recorded at mating and written if this individual survives selection
+	treeSeqMutns []treeSeqMutation		// the tracked mutations that arose in this individual
 
 	ChromosomesFromDad []dna.Chromosome
 	ChromosomesFromMom []dna.Chromosome
+	Mitochondrion dna.Chromosome		// the haploid mitochondrial genome inherited from mom, only has LBs when mito_linkage_subunits > 0
 }
 
 
@@ -35,8 +43,10 @@ func IndividualFactory(popPart *PopulationPart, _ bool) *Individual {
 		ChromosomesFromMom: make([]dna.Chromosome, config.Cfg.Population.Haploid_chromosome_number),
 	}
 
-	for i := range ind.ChromosomesFromDad { ind.ChromosomesFromDad[i].ChromosomeFactory(popPart.Pop.LBsPerChromosome) }
-	for i := range ind.ChromosomesFromMom { ind.ChromosomesFromMom[i].ChromosomeFactory(popPart.Pop.LBsPerChromosome) }
+	for i := range ind.ChromosomesFromDad { ind.ChromosomesFromDad[i].ChromosomeFactory(popPart.Pop.LBsPerChromosome[i]) }
+	for i := range ind.ChromosomesFromMom { ind.ChromosomesFromMom[i].ChromosomeFactory(popPart.Pop.LBsPerChromosome[i]) }
+	if hasMito() { ind.Mitochondrion.ChromosomeFactory(config.Cfg.Population.Mito_linkage_subunits) }
+	if config.Cfg.Mutations.Polygenic_beneficials { ind.PolygenicSeq = polygenicInit }		// offspring will replace this with a parent's
 
 	return ind
 }
@@ -64,13 +74,11 @@ func (ind *Individual) Reinitialize() *Individual {
 func (ind *Individual) GetNumChromosomes() uint32 { return uint32(len(ind.ChromosomesFromDad)) }
 
 
-// Mate combines this person with the specified person to create a list of offspring.
+// Mate combines this person with the specified person to create a list of offspring. If otherInd is ind, this is self-fertilization.
 // The offspring are added to newPopPart
 func (ind *Individual) Mate(otherInd *Individual, newPopPart *PopulationPart, uniformRandom *rand.Rand) /*[]*Individual*/ {
-	if RecombinationType(config.Cfg.Population.Recombination_model) != FULL_SEXUAL { utils.NotImplementedYet("Recombination models other than FULL_SEXUAL are not yet supported") }
-
 	// Mate ind and otherInd to create offspring
-	actual_offspring := Mdl.CalcNumOffspring(ind, uniformRandom)
+	actual_offspring := Mdl.CalcNumOffspring(ind, otherInd, uniformRandom)
 	offspr := make([]*Individual, actual_offspring) 	// temporary slice of the children created
 	for child:=uint32(0); child<actual_offspring; child++ {
 		offspr[child] = ind.OneOffspring(otherInd, newPopPart, uniformRandom)
@@ -79,69 +87,127 @@ func (ind *Individual) Mate(otherInd *Individual, newPopPart *PopulationPart, un
 	// Add mutations to each offspring. Note: this is done after mating is completed for these parents, because as an optimization
 	// we use copy-on-write for the children LBs. I'm not sure that matters.
 	for _, child := range offspr {
-		child.AddMutations(ind.popPart.Pop.LBsPerChromosome, uniformRandom)
+		child.AddMutations(uniformRandom)
 	}
 
 	return
 }
 
 
+// Clone creates offspring of this individual by itself (recombination_model==clonal). The offspring are added to newPopPart
+func (ind *Individual) Clone(newPopPart *PopulationPart, uniformRandom *rand.Rand) {
+	actual_offspring := Mdl.CalcNumOffspring(ind, ind, uniformRandom)
+	offspr := make([]*Individual, actual_offspring) 	// temporary slice of the children created
+	for child:=uint32(0); child<actual_offspring; child++ {
+		offspr[child] = ind.OneClone(newPopPart)
+	}
+
+	// Add mutations to each offspring after they are all created, like Mate() does
+	for _, child := range offspr {
+		child.AddMutations(uniformRandom)
+	}
+}
+
+
+// OneClone returns 1 offspring that has an exact copy of both sets of this individual's chromosomes.
+func (parent *Individual) OneClone(newPopPart *PopulationPart) *Individual {
+	offspr := newPopPart.GetIndividual()	// this gives us an indiv ready to use, with chromosomes and LBs, and ensures it is on the pop part list
+
+	for c:=uint32(0); c<parent.GetNumChromosomes(); c++ {
+		offspr.addInheritedMutations(parent.ChromosomesFromDad[c].Copy(&offspr.ChromosomesFromDad[c]))
+		offspr.addInheritedMutations(parent.ChromosomesFromMom[c].Copy(&offspr.ChromosomesFromMom[c]))
+	}
+	if hasMito() { parent.Mitochondrion.Copy(&offspr.Mitochondrion) }
+	offspr.PolygenicSeq = parent.PolygenicSeq
+	offspr.FamilyId = parent.FamilyId
+	newPopPart.recordPedigreeParents(offspr, parent, parent)
+	if dna.Mdl.RecordLbSources { offspr.recordTreeSeqEdges(parent, parent) }
+
+	return offspr
+}
+
+
+// addInheritedMutations adds the numbers of each kind of mutation in a chromosome it inherited to this individual's totals
+func (offspr *Individual) addInheritedMutations(deleterious, neutral, favorable, delAllele, favAllele uint32) {
+	offspr.NumMutations += deleterious + neutral + favorable + delAllele + favAllele
+	offspr.NumDeleterious += deleterious
+	offspr.NumNeutral += neutral
+	offspr.NumFavorable += favorable
+	offspr.NumDelAllele += delAllele
+	offspr.NumFavAllele += favAllele
+}
+
+
 // Offspring returns 1 offspring of this person (dad) and the specified person (mom).
 func (dad *Individual) OneOffspring(mom *Individual, newPopPart *PopulationPart, uniformRandom *rand.Rand) *Individual {
 	offspr := newPopPart.GetIndividual()	// this gives us an indiv ready to use, with chromosomes and LBs, and ensures it is on the pop part list
 	lBsPerChromosome := dad.popPart.Pop.LBsPerChromosome 		// doesn't matter which parent we get this from
 
-	// Loop thru each chromosome and inherit linkage blocks
-	for c:=uint32(0); c<dad.GetNumChromosomes(); c++ {
+	// Loop thru each chromosome and inherit linkage blocks. With separate sexes the sex chromosome is inherited differently.
+	numChromosomes := dad.GetNumChromosomes()
+	if config.Cfg.Population.Separate_sexes { numChromosomes-- }
+	for c:=uint32(0); c<numChromosomes; c++ {
 		// Meiosis() implements the crossover model specified in the config file
 		// For your chromosome coming from your dad, combine LBs from his dad and mom
-		var deleterious, neutral, favorable, delAllele, favAllele uint32
 		offsprChr := &offspr.ChromosomesFromDad[c]
 		//deleterious, neutral, favorable, delAllele, favAllele = dad.ChromosomesFromDad[c].Meiosis(&dad.ChromosomesFromMom[c], offsprChr, lBsPerChromosome, uniformRandom)
-		deleterious, neutral, favorable, delAllele, favAllele = dna.Mdl.Crossover(&dad.ChromosomesFromDad[c], &dad.ChromosomesFromMom[c], offsprChr, lBsPerChromosome, uniformRandom)
-		offspr.NumMutations += deleterious + neutral + favorable + delAllele + favAllele
-		offspr.NumDeleterious += deleterious
-		offspr.NumNeutral += neutral
-		offspr.NumFavorable += favorable
-		offspr.NumDelAllele += delAllele
-		offspr.NumFavAllele += favAllele
+		offspr.addInheritedMutations(dna.Mdl.Crossover(&dad.ChromosomesFromDad[c], &dad.ChromosomesFromMom[c], offsprChr, c, lBsPerChromosome[c], uniformRandom))
 
 		// For your chromosome coming from your mom, combine LBs from her dad and mom
 		offsprChr = &offspr.ChromosomesFromMom[c]
 		//deleterious, neutral, favorable, delAllele, favAllele = mom.ChromosomesFromDad[c].Meiosis(&mom.ChromosomesFromMom[c], offsprChr, lBsPerChromosome, uniformRandom)
-		deleterious, neutral, favorable, delAllele, favAllele = dna.Mdl.Crossover(&mom.ChromosomesFromDad[c], &mom.ChromosomesFromMom[c], offsprChr, lBsPerChromosome, uniformRandom)
-		offspr.NumMutations += deleterious + neutral + favorable + delAllele + favAllele
-		offspr.NumDeleterious += deleterious
-		offspr.NumNeutral += neutral
-		offspr.NumFavorable += favorable
-		offspr.NumDelAllele += delAllele
-		offspr.NumFavAllele += favAllele
+		offspr.addInheritedMutations(dna.Mdl.Crossover(&mom.ChromosomesFromDad[c], &mom.ChromosomesFromMom[c], offsprChr, c, lBsPerChromosome[c], uniformRandom))
 	}
+	if config.Cfg.Population.Separate_sexes { offspr.inheritSexChromosome(dad, mom, uniformRandom) }
+	if hasMito() { mom.Mitochondrion.Copy(&offspr.Mitochondrion) }		// the mitochondrial mutations are not included in the mutation counts
+	if config.Cfg.Mutations.Polygenic_beneficials { offspr.inheritPolygenic(dad, mom, uniformRandom) }
+	offspr.FamilyId = dad.FamilyId
+	newPopPart.recordPedigreeParents(offspr, dad, mom)
+	if dna.Mdl.RecordLbSources { offspr.recordTreeSeqEdges(dad, mom) }
 
 	return offspr
 }
 
 
 // AddMutations adds new mutations to this child right after mating.
-func (child *Individual) AddMutations(lBsPerChromosome uint32, uniformRandom *rand.Rand) {
+func (child *Individual) AddMutations(uniformRandom *rand.Rand) {
 	// Apply new mutations
-	numMutations := Mdl.CalcNumMutations(uniformRandom)
-	//log.Printf("DEBUG: adding %d mutations to this individual", numMutations)
 	popPart := child.popPart
+	numMutations := popPart.Pop.Mdl.CalcNumMutations(popPart.Pop.Cfg.Mutations.Mutn_rate, uniformRandom)		// the mutn rate can be different for each tribe
+	//log.Printf("DEBUG: adding %d mutations to this individual", numMutations)
+	var numReverted, numPolygenic uint32
 	for m:=uint32(1); m<=numMutations; m++ {
+		if config.Cfg.Mutations.Polygenic_beneficials && child.mutatePolygenic(uniformRandom) {
+			// This mutation hit the polygenic nucleotide string instead of a LB
+			numPolygenic++
+			continue
+		}
 		// Note: we are choosing the LB this way to keep the random number generation the same as when we didn't have chromosomes.
 		//		Can change this in the future if you want.
-		lb := uniformRandom.Intn(int(config.Cfg.Population.Num_linkage_subunits))	// choose a random LB within the individual
-		chr := lb / int(lBsPerChromosome) 		// get the chromosome index
-		lbInChr := lb % int(lBsPerChromosome)	// get index of LB within the chromosome
+		var lb int
+		if dna.Mdl.MutationLbs != nil {
+			lb = dna.Mdl.MutationLbs.Sample(uniformRandom)		// choose an LB according to mutn_rate_map_file
+		} else {
+			lb = uniformRandom.Intn(int(config.Cfg.Population.Num_linkage_subunits))	// choose a random LB within the individual
+		}
+		chr, lbInChr := dna.LocateLb(lb) 		// get the chromosome index and the index of the LB within the chromosome
 
 		// Randomly choose the LB from dad or mom to put the mutation in.
 		// Note: AppendMutation() creates a mutation with deleterious/neutral/favorable, dominant/recessive, etc. based on the relevant input parameter rates
-		var mType dna.MutationType
-		if uniformRandom.Intn(2) == 0 {
-			mType = child.ChromosomesFromDad[chr].AppendMutation(lbInChr, popPart.MyUniqueInt.NextInt(), uniformRandom)
+		fromDad := uniformRandom.Intn(2) == 0
+		var chromo *dna.Chromosome
+		if fromDad {
+			chromo = &child.ChromosomesFromDad[chr]
 		} else {
-			mType = child.ChromosomesFromMom[chr].AppendMutation(lbInChr, popPart.MyUniqueInt.NextInt(), uniformRandom)
+			chromo = &child.ChromosomesFromMom[chr]
+		}
+		mutId := popPart.MyUniqueInt.NextInt()
+		mType, reverted := chromo.AppendMutation(lbInChr, mutId, config.Cfg.Population.Separate_sexes && chr == sexChromosome(), uniformRandom)
+		if reverted {
+			// The new mutation hit the site of an existing mutation and reverted it
+			child.removeMutationCount(mType)
+			numReverted++
+			continue
 		}
 		switch mType {
 		case dna.DELETERIOUS_DOMINANT:
@@ -155,16 +221,43 @@ func (child *Individual) AddMutations(lBsPerChromosome uint32, uniformRandom *ra
 		case dna.FAVORABLE_RECESSIVE:
 			child.NumFavorable++
 		}
+		if dna.Mdl.RecordLbSources { child.recordTreeSeqMutation(chromo, fromDad, chr, lbInChr, mutId) }
+		if fixation != nil { child.recordFixationMutation(chromo, lbInChr, mutId) }
 	}
-	child.NumMutations += numMutations
+	child.NumMutations += numMutations - numReverted - numPolygenic
+	popPart.NumBackMutations += numReverted
+	if hasMito() { child.addMitoMutations(uniformRandom) }
 
-	child.GenoFitness = Mdl.CalcIndivFitness(child) 		// store resulting fitness
+	child.GenoFitness = Mdl.CalcIndivFitness(child) - Mdl.CalcEpistasis(child) 		// store resulting fitness
+	if config.Cfg.Mutations.Polygenic_beneficials { child.GenoFitness += PolygenicFitness(child) }
 	if child.GenoFitness <= 0.0 { child.Dead = true }
 
 	return
 }
 
 
+// removeMutationCount decrements this individual's count for a mutation of type mType that has been removed by a back mutation
+func (ind *Individual) removeMutationCount(mType dna.MutationType) {
+	ind.NumMutations--
+	switch mType {
+	case dna.DELETERIOUS_DOMINANT:
+		fallthrough
+	case dna.DELETERIOUS_RECESSIVE:
+		ind.NumDeleterious--
+	case dna.NEUTRAL:
+		ind.NumNeutral--
+	case dna.FAVORABLE_DOMINANT:
+		fallthrough
+	case dna.FAVORABLE_RECESSIVE:
+		ind.NumFavorable--
+	case dna.DEL_ALLELE:
+		ind.NumDelAllele--
+	case dna.FAV_ALLELE:
+		ind.NumFavAllele--
+	}
+}
+
+
 // AddInitialContrastingAlleles adds numAlleles pairs of contrasting alleles to this individual
 func (ind *Individual) AddInitialContrastingAlleles(numAlleles uint32, uniformRandom *rand.Rand) (uint32, uint32) {
 	// Spread the allele pairs throughout the LBs as evenly as possible: if numAlleles < num_linkage_subunits then skip some LBs to
@@ -219,21 +312,54 @@ func (ind *Individual) AddInitialAllelePair(chromoIndex, lbIndexOnChr int, favMu
 }
 
 
-// Various algorithms for determining the random number of offspring for a mating pair of individuals
-type CalcNumOffspringType func(ind *Individual, uniformRandom *rand.Rand) uint32
+// AddUploadedMutation adds a mutation read from upload_mutations_file to 1 of this individual's chromosomes
+func (ind *Individual) AddUploadedMutation(chromoIndex, lbIndexOnChr int, fromDad bool, mutn dna.Mutation) {
+	if fromDad {
+		ind.ChromosomesFromDad[chromoIndex].AddMutation(lbIndexOnChr, mutn)
+	} else {
+		ind.ChromosomesFromMom[chromoIndex].AddMutation(lbIndexOnChr, mutn)
+	}
+	ind.NumMutations++
+	switch mutn.Type {
+	case dna.DELETERIOUS_DOMINANT:
+		fallthrough
+	case dna.DELETERIOUS_RECESSIVE:
+		ind.NumDeleterious++
+	case dna.NEUTRAL:
+		ind.NumNeutral++
+	case dna.FAVORABLE_DOMINANT:
+		fallthrough
+	case dna.FAVORABLE_RECESSIVE:
+		ind.NumFavorable++
+	case dna.DEL_ALLELE:
+		ind.NumDelAllele++
+	case dna.FAV_ALLELE:
+		ind.NumFavAllele++
+	}
+}
+
+
+// Various algorithms for determining the random number of offspring for a mating pair of individuals. For clonal reproduction and self-fertilization otherInd is ind.
+type CalcNumOffspringType func(ind *Individual, otherInd *Individual, uniformRandom *rand.Rand) uint32
+
+// meanNumOffspring returns the average number of offspring ind and otherInd should have: (Num_offspring*2) for a mating pair, or Num_offspring for an individual reproducing by itself
+func meanNumOffspring(ind *Individual, otherInd *Individual) float64 {
+	if otherInd == ind { return ind.popPart.Pop.Num_offspring }
+	return ind.popPart.Pop.Num_offspring * 2
+}
 
 // A uniform algorithm for calculating the number of offspring that gives an even distribution between 1 and 2*(Num_offspring*2)-1
-func CalcUniformNumOffspring(ind *Individual, uniformRandom *rand.Rand) uint32 {
+func CalcUniformNumOffspring(ind *Individual, otherInd *Individual, uniformRandom *rand.Rand) uint32 {
 	// If (Num_offspring*2) is 4.5, we want a range from 1-8
-	maxRange := (2 * ind.popPart.Pop.Num_offspring * 2) - 2 		// subtract 2 to get a buffer of 1 at each end
+	maxRange := (2 * meanNumOffspring(ind, otherInd)) - 2 		// subtract 2 to get a buffer of 1 at each end
 	numOffspring := uniformRandom.Float64() * maxRange 		// some float between 0 and maxRange
 	return uint32(random.Round(uniformRandom, numOffspring + 1)) 	// shift it so it is between 1 and maxRange+1, then get to an uint32
 }
 
 
 // Randomly rounds the desired number of offspring to the integer below or above, proportional to how close it is to each (so the resulting average should be (Num_offspring*2) )
-func CalcSemiFixedNumOffspring(ind *Individual, uniformRandom *rand.Rand) uint32 {
-	return uint32(random.Round(uniformRandom, ind.popPart.Pop.Num_offspring*2))
+func CalcSemiFixedNumOffspring(ind *Individual, otherInd *Individual, uniformRandom *rand.Rand) uint32 {
+	return uint32(random.Round(uniformRandom, meanNumOffspring(ind, otherInd)))
 }
 
 
@@ -254,27 +380,34 @@ func CalcFortranNumOffspring(ind *Individual, uniformRandom *rand.Rand) uint32 {
 */
 
 
-// Randomly choose a number of offspring that is, on average, proportional to the individual's fitness
-func CalcFitnessNumOffspring(ind *Individual, uniformRandom *rand.Rand) uint32 {
-	// in the fortran version this is controlled by fitness_dependent_fertility
-	utils.NotImplementedYet("CalcFitnessNumOffspring not implemented yet")
-	return uint32(random.Round(uniformRandom, ind.popPart.Pop.Num_offspring*2))
+// Randomly choose a number of offspring that is, on average, proportional to the mating pair's fitness relative to the mean fitness of the population.
+// So the pop as a whole still averages (Num_offspring*2) per pair, but the fitter pairs contribute more of the next generation.
+func CalcFitnessNumOffspring(ind *Individual, otherInd *Individual, uniformRandom *rand.Rand) uint32 {
+	parentPop := ind.popPart.Pop
+	numOffspring := meanNumOffspring(ind, otherInd)
+	// Note: Population.Mate() makes sure the parent pop's MeanFitness is calculated before the mating go routines are started, so it is safe to just read it here.
+	//		The genesis pop does not have its fitness calculated, so in that case every pair gets the same expected number of offspring.
+	if parentPop.MeanFitness > 0.0 {
+		pairFitness := math.Max(0.0, (ind.GenoFitness + otherInd.GenoFitness) / 2.0)
+		numOffspring = numOffspring * pairFitness / parentPop.MeanFitness
+	}
+	return uint32(random.Round(uniformRandom, numOffspring))
 }
 
 
 // Algorithms for determining the number of additional mutations a specific offspring should be given
-type CalcNumMutationsType func(uniformRandom *rand.Rand) uint32
+type CalcNumMutationsType func(mutnRate float64, uniformRandom *rand.Rand) uint32
 
-// Randomly round Mutn_rate to the uint32 below or above, proportional to how close it is to each (so the resulting average should be Mutn_rate)
-func CalcSemiFixedNumMutations (uniformRandom *rand.Rand) uint32 {
-	numMutations := uint32(random.Round(uniformRandom, config.Cfg.Mutations.Mutn_rate))
+// Randomly round mutnRate to the uint32 below or above, proportional to how close it is to each (so the resulting average should be mutnRate)
+func CalcSemiFixedNumMutations (mutnRate float64, uniformRandom *rand.Rand) uint32 {
+	numMutations := uint32(random.Round(uniformRandom, mutnRate))
 	return numMutations
 }
 
-// Use a poisson distribution to choose a number of mutations, with the mean of number of mutations for all individuals being Mutn_rate
-func CalcPoissonNumMutations (uniformRandom *rand.Rand) uint32 {
-	numMutations := uint32(random.Poisson(uniformRandom, config.Cfg.Mutations.Mutn_rate))
-	if config.Cfg.Mutations.Mutn_rate == 0.0 { numMutations = 0 }		// no positive Poisson() will always return 0 for a 0.0 mutn rate
+// Use a poisson distribution to choose a number of mutations, with the mean of number of mutations for all individuals being mutnRate
+func CalcPoissonNumMutations (mutnRate float64, uniformRandom *rand.Rand) uint32 {
+	numMutations := uint32(random.Poisson(uniformRandom, mutnRate))
+	if mutnRate == 0.0 { numMutations = 0 }		// no positive Poisson() will always return 0 for a 0.0 mutn rate
 	return numMutations
 }
 
@@ -284,6 +417,8 @@ type CalcIndivFitnessType func(ind *Individual) float64
 
 // SumIndivFitness adds together the fitness factors of all of the mutations. An individual's fitness starts at 1 and then deleterious
 // mutations subtract from that and favorable mutations add to it. A total fitness of 0 means the individual is dead.
+// The sex chromosomes of the heterogametic sex use their hemizygous effects (separate_sexes can not be used with the other fitness models or epistasis).
+// The mitochondrial genome (if any) only has 1 copy, so its mutations always have their full effects.
 func SumIndivFitness(ind *Individual) (fitness float64) {
 	// Sum all the chromosome fitness numbers
 	fitness = 1.0
@@ -294,17 +429,55 @@ func SumIndivFitness(ind *Individual) (fitness float64) {
 	for _, c := range ind.ChromosomesFromMom {
 		fitness += c.SumFitness()
 	}
+	fitness += ind.hemizygousFitness()
+	fitness += ind.Mitochondrion.SumFitness()
 	// Note: AddMutations() will cache the fitness
 	return
 }
 
-// Note implemented yet. MultIndivFitness aggregates the fitness factors of all of the mutations using a combination of additive and mutliplicative,
-// based on config.Cfg.Mutations.Multiplicative_weighting
-func MultIndivFitness(_ *Individual) (fitness float64) {
-	fitness = 1.0
-	// do not know the exact formula to use for this yet
-	utils.NotImplementedYet("Multiplicative_weighting not implemented yet")
-	return fitness
+// Algorithms for calculating the fitness penalty due to interactions between mutations. This is subtracted from the result of CalcIndivFitnessType.
+type CalcEpistasisType func(ind *Individual) float64
+
+// NoEpistasis is used when synergistic_epistasis==false
+func NoEpistasis(_ *Individual) float64 { return 0.0 }
+
+// SynergisticEpistasis returns the fitness penalty of the deleterious mutations interacting with each other (as in mendel-f90). With d_lb the sum of the
+// deleterious fitness effects in an LB and D the sum over the whole genome, the penalty is se_linked_scaling * sum(d_lb^2) / 2 + se_nonlinked_scaling *
+// (D^2 - sum(d_lb^2)) / 2. The nonlinked term is exactly the sum of the products of every pair of mutations in different LBs. The linked term is the sum of
+// the products of every pair of mutations in the same LB plus half the square of each mutation's own effect. This only needs the running totals each
+// chromosome keeps of its LBs' deleterious fitness effects (which include the mutations not tracked because of tracking_threshold).
+func SynergisticEpistasis(ind *Individual) float64 {
+	var delFitness, sumLBDelFitnessSqr float64
+	for _, c := range ind.ChromosomesFromDad {
+		delFitness += float64(c.DelFitnessEffect)
+		sumLBDelFitnessSqr += float64(c.DelFitnessSqr)
+	}
+	for _, c := range ind.ChromosomesFromMom {
+		delFitness += float64(c.DelFitnessEffect)
+		sumLBDelFitnessSqr += float64(c.DelFitnessSqr)
+	}
+	linked := sumLBDelFitnessSqr / 2.0
+	nonLinked := (delFitness * delFitness - sumLBDelFitnessSqr) / 2.0
+	return config.Cfg.Mutations.Se_linked_scaling * linked + config.Cfg.Mutations.Se_nonlinked_scaling * nonLinked
+}
+
+
+// MultIndivFitness aggregates the fitness factors of all of the mutations using a combination of additive and mutliplicative,
+// based on config.Cfg.Mutations.Multiplicative_weighting: fitness = (1-w) * additiveFitness + w * multiplicativeFitness, where the
+// multiplicative fitness is the product of (1+effect) of every mutation. A weighting of 0.0 gives exactly the result of SumIndivFitness.
+func MultIndivFitness(ind *Individual) (fitness float64) {
+	weighting := config.Cfg.Mutations.Multiplicative_weighting
+	multFitness := 1.0
+	for _, c := range ind.ChromosomesFromDad {
+		// Note: each chromosome keeps a running product of its LBs, which keep a running product of all of their mutations (tracked or not)
+		multFitness *= 1.0 + c.MultFitness()
+	}
+	for _, c := range ind.ChromosomesFromMom {
+		multFitness *= 1.0 + c.MultFitness()
+	}
+	multFitness *= 1.0 + ind.Mitochondrion.MultFitness()
+	fitness = (1.0 - weighting) * SumIndivFitness(ind) + weighting * multFitness
+	return
 }
 
 
@@ -324,41 +497,19 @@ func (ind *Individual) GetInitialAlleleStats() (uint32, uint32) {
 
 // CountAlleles counts all of this individual's alleles (both mutations and initial alleles) and adds them to the given struct
 func (ind *Individual) CountAlleles(alleles *dna.AlleleCount) {
-	// Get the alleles for this individual
+	if config.Cfg.Population.Separate_sexes && config.FMgr.IsDir(config.ALLELE_SFS_DIRECTORY) { ind.markSexChromosomeAlleles(alleles) }
 	// Note: even when Count_duplicate_alleles=true, we won't find duplicate allele ids in 1 LB, because it is only ever inherited from 1 parent or the other
-	//todo: if we decide Count_duplicate_alleles should always be true, we can eliminate this struct and add them directly to alleles
-	allelesForThisIndiv := dna.AlleleCountFactory()		// so we don't double count the same allele from both parents if Count_duplicate_alleles=false (in this case, the count in this map for each allele id found is always 1)
+	if config.Cfg.Computation.Count_duplicate_alleles {
+		for _, c := range ind.ChromosomesFromDad { c.CountAlleles(alleles) }
+		for _, c := range ind.ChromosomesFromMom { c.CountAlleles(alleles) }
+		return
+	}
+
+	// Gather the alleles of this individual separately so we don't double count the same allele from both parents
+	allelesForThisIndiv := dna.AlleleCountFactory()
 	for _, c := range ind.ChromosomesFromDad { c.CountAlleles(allelesForThisIndiv) }
 	for _, c := range ind.ChromosomesFromMom { c.CountAlleles(allelesForThisIndiv) }
-
-	// Add the alleles found for this individual to the alleles map for the whole population
-	// Note: map returns the zero value of the value type for keys which are not yet in the map (zero value for int is 0), so we do not need to check if it is there with: if count, ok := alleles.Deleterious[id]; ok {
-	for id, al := range allelesForThisIndiv.DeleteriousDom {
-		//if al.Count > 2 { log.Printf("warning: individual's allele %d has count %d", id, al.Count) }
-		alleles.DeleteriousDom[id] = dna.Allele{Count: alleles.DeleteriousDom[id].Count+al.Count, FitnessEffect: al.FitnessEffect}
-	}
-	for id, al := range allelesForThisIndiv.DeleteriousRec {
-		//if al.Count > 2 { log.Printf("warning: individual's allele %d has count %d", id, al.Count) }
-		alleles.DeleteriousRec[id] = dna.Allele{Count: alleles.DeleteriousRec[id].Count+al.Count, FitnessEffect: al.FitnessEffect}
-	}
-	for id, al := range allelesForThisIndiv.Neutral {
-		//if al.Count > 2 { log.Printf("warning: individual's allele %d has count %d", id, al.Count) }
-		alleles.Neutral[id] = dna.Allele{Count: alleles.Neutral[id].Count+al.Count, FitnessEffect: al.FitnessEffect}
-	}
-	for id, al := range allelesForThisIndiv.FavorableDom {
-		//if al.Count > 2 { log.Printf("warning: individual's allele %d has count %d", id, al.Count) }
-		alleles.FavorableDom[id] = dna.Allele{Count: alleles.FavorableDom[id].Count+al.Count, FitnessEffect: al.FitnessEffect}
-	}
-	for id, al := range allelesForThisIndiv.FavorableRec {
-		//if al.Count > 2 { log.Printf("warning: individual's allele %d has count %d", id, al.Count) }
-		alleles.FavorableRec[id] = dna.Allele{Count: alleles.FavorableRec[id].Count+al.Count, FitnessEffect: al.FitnessEffect}
-	}
-	for id, al := range allelesForThisIndiv.DelInitialAlleles {
-		alleles.DelInitialAlleles[id] = dna.Allele{Count: alleles.DelInitialAlleles[id].Count+al.Count, FitnessEffect: al.FitnessEffect}
-	}
-	for id, al := range allelesForThisIndiv.FavInitialAlleles {
-		alleles.FavInitialAlleles[id] = dna.Allele{Count: alleles.FavInitialAlleles[id].Count+al.Count, FitnessEffect: al.FitnessEffect}
-	}
+	alleles.AddDistinct(allelesForThisIndiv)
 }
 
 
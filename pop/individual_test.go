@@ -0,0 +1,308 @@
+package pop
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"github.com/genetic-algorithms/mendel-go/utils"
+)
+
+// setUpPopTest reads the defaults file and lets the test adjust the config values before the models are set.
+func setUpPopTest(t *testing.T, adjustConfig func(c *config.Config)) {
+	config.CmdArgs = &config.CommandArgs{DefaultFile: "../mendel-defaults.ini", DataPath: t.TempDir()}
+	if err := config.ReadFromFile(config.CmdArgs.DefaultFile); err != nil {
+		t.Fatalf("Error reading %v: %v", config.CmdArgs.DefaultFile, err)
+	}
+	config.Cfg.Computation.Verbosity = 0
+	config.Cfg.Computation.Num_threads = 1
+	if adjustConfig != nil {
+		adjustConfig(config.Cfg)
+		config.Computed = config.ComputedValuesFactory()		// some of the computed values depend on the config values that were adjusted
+	}
+	utils.MeasurerFactory(0)
+	utils.GlobalUniqueIntFactory()
+	dna.SetModels(config.Cfg)
+	SetModels(config.Cfg)
+}
+
+// matePopulation mates the genesis population for the specified number of generations and returns the last one
+func matePopulation(numGens uint32, uniformRandom *rand.Rand) *Population {
+	p := PopulationFactory(nil, 0, 1, 1)
+	Mdl.GenerateInitialAlleles(p, uniformRandom)
+	for gen := uint32(1); gen <= numGens; gen++ {
+		newP := PopulationFactory(p, gen, 1, 1)
+		p.Mate(newP, uniformRandom)
+		newP.Select(uniformRandom)
+		p = newP
+	}
+	return p
+}
+
+// A multiplicative_weighting of 0.0 must give exactly the same fitness as the additive model, with and without tracking_threshold pooling
+func TestMultIndivFitnessZeroWeighting(t *testing.T) {
+	for _, trackingThreshold := range []float32{0.0, 1.e-5, 9.0} {
+		setUpPopTest(t, func(c *config.Config) {
+			c.Basic.Pop_size = 20
+			c.Computation.Tracking_threshold = trackingThreshold
+			c.Population.Num_contrasting_alleles = 10
+			c.Population.Initial_alleles_frequencies = "1.0:0.5"
+		})
+		p := matePopulation(10, rand.New(rand.NewSource(1)))
+		for _, indRef := range p.IndivRefs {
+			ind := indRef.Indiv
+			if sum, mult := SumIndivFitness(ind), MultIndivFitness(ind); sum != mult {
+				t.Errorf("With tracking_threshold %v and multiplicative_weighting 0.0, MultIndivFitness returned %v instead of the SumIndivFitness value %v", trackingThreshold, mult, sum)
+			}
+		}
+	}
+}
+
+// A partial weighting must be the weighted combination of the fully additive and fully multiplicative fitness
+func TestMultIndivFitnessPartialWeighting(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Mutations.Frac_fav_mutn = 0.0
+		c.Mutations.Multiplicative_weighting = 1.0
+	})
+	p := matePopulation(10, rand.New(rand.NewSource(1)))
+	for _, indRef := range p.IndivRefs {
+		ind := indRef.Indiv
+		sum := SumIndivFitness(ind)
+		mult := MultIndivFitness(ind)
+		// With only deleterious mutations, the product of (1-s) is always >= 1 - sum(s)
+		if mult < sum {
+			t.Errorf("Multiplicative fitness %v is less than additive fitness %v", mult, sum)
+		}
+		config.Cfg.Mutations.Multiplicative_weighting = 0.25
+		partial := MultIndivFitness(ind)
+		config.Cfg.Mutations.Multiplicative_weighting = 1.0
+		if expected := 0.75*sum + 0.25*mult; math.Abs(partial-expected) > 1.e-12 {
+			t.Errorf("With multiplicative_weighting 0.25 fitness is %v, expected %v", partial, expected)
+		}
+	}
+}
+
+// With the fitness num_offspring_model a pair's average number of offspring must scale with its fitness relative to the pop mean
+func TestCalcFitnessNumOffspring(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Population.Num_offspring_model = "fitness"
+	})
+	p := matePopulation(1, rand.New(rand.NewSource(1)))
+	p.MeanFitness = 0.8
+	ind := p.IndivRefs[0].Indiv
+	otherInd := p.IndivRefs[1].Indiv
+	ind.GenoFitness, otherInd.GenoFitness = 1.0, 0.6
+	rnd := rand.New(rand.NewSource(1))
+	var total uint32
+	const numTrials = 10000
+	for i := 0; i < numTrials; i++ { total += Mdl.CalcNumOffspring(ind, otherInd, rnd) }
+	if avg, expected := float64(total)/numTrials, p.Num_offspring*2; math.Abs(avg-expected) > 0.05 {
+		t.Errorf("Average number of offspring for a pair with the mean fitness is %v, expected %v", avg, expected)
+	}
+
+	ind.GenoFitness, otherInd.GenoFitness = 1.2, 1.2
+	total = 0
+	for i := 0; i < numTrials; i++ { total += Mdl.CalcNumOffspring(ind, otherInd, rnd) }
+	if avg, expected := float64(total)/numTrials, p.Num_offspring*2*1.5; math.Abs(avg-expected) > 0.05 {
+		t.Errorf("Average number of offspring for a pair 1.5 times as fit as the mean is %v, expected %v", avg, expected)
+	}
+}
+
+// A clone must inherit exactly the same chromosomes as its parent
+func TestOneClone(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Population.Recombination_model = uint32(CLONAL)
+	})
+	p := matePopulation(5, rand.New(rand.NewSource(1)))
+	newP := PopulationFactory(p, 6, 1, 1)
+	for _, indRef := range p.IndivRefs {
+		parent := indRef.Indiv
+		child := parent.OneClone(newP.Parts[0])
+		if child.NumMutations != parent.NumMutations || child.NumDeleterious != parent.NumDeleterious || child.NumFavorable != parent.NumFavorable {
+			t.Errorf("Clone has %d mutations (%d deleterious, %d favorable), parent has %d (%d deleterious, %d favorable)", child.NumMutations, child.NumDeleterious, child.NumFavorable, parent.NumMutations, parent.NumDeleterious, parent.NumFavorable)
+		}
+		// The chromosome fitness is a float32 running total, so it can differ in the last digits depending on the order the LBs were added in
+		if cf, pf := SumIndivFitness(child), SumIndivFitness(parent); math.Abs(cf-pf) > 1.e-6 { t.Errorf("Clone fitness %v is not the same as the parent fitness %v", cf, pf) }
+	}
+}
+
+// With suppressed recombination each chromosome a child inherits from a parent must be a whole copy of 1 of that parent's 2 chromosomes
+func TestSuppressedRecombination(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Population.Recombination_model = uint32(SUPPRESSED)
+		c.Population.Crossover_model = "full"
+		c.Computation.Track_neutrals = true
+	})
+	const markerId = uint64(1) << 62		// the id must not be one a new mutation can get
+	p := PopulationFactory(nil, 0, 1, 1)
+	// Mark every LB of the chromosomes each parent got from its dad, so the children show whether there was crossover
+	for _, indRef := range p.IndivRefs {
+		for c := range indRef.Indiv.ChromosomesFromDad {
+			chr := &indRef.Indiv.ChromosomesFromDad[c]
+			for lb := range chr.LinkageBlocks { chr.AddMutation(lb, dna.Mutation{Id: markerId, Type: dna.NEUTRAL}) }
+		}
+	}
+	newP := PopulationFactory(p, 1, 1, 1)
+	p.Mate(newP, rand.New(rand.NewSource(1)))
+	if newP.GetCurrentSize() == 0 { t.Fatalf("No children were created") }
+	var numMarkedChrs int
+	for _, indRef := range newP.IndivRefs {
+		for _, chromosomes := range [][]dna.Chromosome{indRef.Indiv.ChromosomesFromDad, indRef.Indiv.ChromosomesFromMom} {
+			for c := range chromosomes {
+				var numMarked int
+				for lb := range chromosomes[c].LinkageBlocks {
+					if len(chromosomes[c].LinkageBlocks[lb].GetMutations()) > 0 && chromosomes[c].LinkageBlocks[lb].GetMutations()[0].Id == markerId { numMarked++ }
+				}
+				if numMarked != 0 && numMarked != len(chromosomes[c].LinkageBlocks) { t.Fatalf("Chromosome %d of a child has %d of its %d LBs from 1 of the parent's chromosomes", c, numMarked, len(chromosomes[c].LinkageBlocks)) }
+				if numMarked > 0 { numMarkedChrs++ }
+			}
+		}
+	}
+	if numMarkedChrs == 0 { t.Errorf("None of the children inherited a chromosome their parent got from its dad") }
+}
+
+// With fraction_self_fertilization, about that fraction of the children must have both sets of chromosomes from a single parent
+func TestSelfFertilization(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 400
+		c.Population.Fraction_self_fertilization = 0.3
+		c.Computation.Track_neutrals = true
+	})
+	const markerId = uint64(1) << 62		// the ids must not be ones a new mutation can get
+	p := PopulationFactory(nil, 0, 1, 1)
+	// Give each parent its own marker on the first LB of both copies of the first chromosome, so the children show who their parents were
+	for i, indRef := range p.IndivRefs {
+		mutn := dna.Mutation{Id: markerId + uint64(i), Type: dna.NEUTRAL}
+		indRef.Indiv.ChromosomesFromDad[0].AddMutation(0, mutn)
+		indRef.Indiv.ChromosomesFromMom[0].AddMutation(0, mutn)
+	}
+	newP := PopulationFactory(p, 1, 1, 1)
+	p.Mate(newP, rand.New(rand.NewSource(1)))
+	if newP.GetCurrentSize() == 0 { t.Fatalf("No children were created") }
+	var numSelfed int
+	for _, indRef := range newP.IndivRefs {
+		var parentMarkers []uint64
+		for _, chr := range []*dna.Chromosome{&indRef.Indiv.ChromosomesFromDad[0], &indRef.Indiv.ChromosomesFromMom[0]} {
+			for _, m := range chr.LinkageBlocks[0].GetMutations() {
+				if m.Id >= markerId { parentMarkers = append(parentMarkers, m.Id) }
+			}
+		}
+		if len(parentMarkers) != 2 { t.Fatalf("A child has %d parent markers, expected 2", len(parentMarkers)) }
+		if parentMarkers[0] == parentMarkers[1] { numSelfed++ }
+	}
+	if frac := float64(numSelfed) / float64(newP.GetCurrentSize()); math.Abs(frac - 0.3) > 0.08 { t.Errorf("%v of the children are from self-fertilization, expected about 0.3", frac) }
+}
+
+// The synergistic epistasis penalty must be made up of its linked and nonlinked terms, using the running totals that are kept even for
+// the mutations that are not tracked
+func TestSynergisticEpistasis(t *testing.T) {
+	for _, trackingThreshold := range []float32{0.0, 9.0} {
+		setUpPopTest(t, func(c *config.Config) {
+			c.Basic.Pop_size = 20
+			c.Computation.Tracking_threshold = trackingThreshold
+			c.Mutations.Frac_fav_mutn = 0.0
+			c.Mutations.Synergistic_epistasis = true
+			c.Mutations.Se_linked_scaling = 1.0
+			c.Mutations.Se_nonlinked_scaling = 1.0
+		})
+		p := matePopulation(10, rand.New(rand.NewSource(1)))
+		for _, indRef := range p.IndivRefs {
+			ind := indRef.Indiv
+			// With only deleterious mutations, the total deleterious effect is the additive fitness loss, and with equal scaling the penalty is (D^2)/2
+			delFitness := SumIndivFitness(ind) - 1.0
+			if penalty, expected := SynergisticEpistasis(ind), delFitness * delFitness / 2.0; math.Abs(penalty-expected) > 1.e-6 {
+				t.Errorf("With tracking_threshold %v the epistasis penalty is %v, expected %v", trackingThreshold, penalty, expected)
+			}
+			if fitness, expected := ind.GenoFitness, SumIndivFitness(ind) - SynergisticEpistasis(ind); fitness != expected {
+				t.Errorf("With tracking_threshold %v the individual fitness is %v, expected %v", trackingThreshold, fitness, expected)
+			}
+
+			// The linked part alone can not be more than the whole
+			config.Cfg.Mutations.Se_nonlinked_scaling = 0.0
+			if linked := SynergisticEpistasis(ind); linked < 0.0 || linked > delFitness * delFitness / 2.0 + 1.e-6 {
+				t.Errorf("With tracking_threshold %v the linked epistasis penalty %v is not between 0 and %v", trackingThreshold, linked, delFitness * delFitness / 2.0)
+			}
+			config.Cfg.Mutations.Se_nonlinked_scaling = 1.0
+		}
+	}
+}
+
+// Back mutations must keep the individual's mutation counts and fitness consistent with the contents of its LBs
+func TestBackMutations(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Mutations.Allow_back_mutn = true
+		c.Mutations.Genome_size = 2.e4		// small, so there are lots of back mutations
+		c.Computation.Tracking_threshold = 0.0
+		c.Computation.Track_neutrals = true
+	})
+	p := matePopulation(20, rand.New(rand.NewSource(1)))
+	if p.NumBackMutations == 0 { t.Errorf("No back mutations occurred in the last generation") }
+	for _, indRef := range p.IndivRefs {
+		ind := indRef.Indiv
+		var delet, neut, fav uint32
+		var fitness float64 = 1.0
+		for _, chrs := range [][]dna.Chromosome{ind.ChromosomesFromDad, ind.ChromosomesFromMom} {
+			for c := range chrs {
+				for lb := range chrs[c].LinkageBlocks {
+					d, n, f, _, _ := chrs[c].LinkageBlocks[lb].GetMutationStats()
+					delet += d
+					neut += n
+					fav += f
+					fitness += float64(chrs[c].LinkageBlocks[lb].SumFitness())
+				}
+			}
+		}
+		if delet != ind.NumDeleterious || neut != ind.NumNeutral || fav != ind.NumFavorable || delet+neut+fav != ind.NumMutations {
+			t.Errorf("Individual counts are %d deleterious, %d neutral, %d favorable, %d total, but its LBs have %d, %d, %d", ind.NumDeleterious, ind.NumNeutral, ind.NumFavorable, ind.NumMutations, delet, neut, fav)
+		}
+		if indFitness := SumIndivFitness(ind); math.Abs(indFitness-fitness) > 1.e-4 {
+			t.Errorf("Individual fitness is %v, but the sum of its LBs is %v", indFitness, fitness)
+		}
+	}
+}
+
+// A mutation that hits the polygenic string must change exactly 1 nucleotide to a different one, and the target must be written to mendel.plg
+func TestMutatePolygenic(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 2
+		c.Mutations.Polygenic_beneficials = true
+		c.Mutations.Polygenic_init = "AAAAAA"
+		c.Mutations.Polygenic_target = "AAAAAC"
+		c.Mutations.Genome_size = 6.0		// so every mutation hits the polygenic string
+	})
+	config.FileMgrFactory(config.CmdArgs.DataPath, config.POLYGENIC_FILENAME)
+	p := PopulationFactory(nil, 0, 1, 1)
+	ind := p.IndivRefs[0].Indiv
+	if seq := decodePolygenic(ind.PolygenicSeq); seq != "AAAAAA" { t.Fatalf("The polygenic string starts as %s, expected AAAAAA", seq) }
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		before := decodePolygenic(ind.PolygenicSeq)
+		if !ind.mutatePolygenic(rnd) { t.Fatalf("Mutation did not hit the polygenic string") }
+		after := decodePolygenic(ind.PolygenicSeq)
+		var numDiff int
+		for j := range before {
+			if before[j] != after[j] { numDiff++ }
+		}
+		if numDiff != 1 || strings.Trim(after, POLYGENIC_NUCLEOTIDES) != "" { t.Errorf("Polygenic string mutated from %s to %s", before, after) }
+		if expected := after == "AAAAAC"; (PolygenicFitness(ind) == config.Cfg.Mutations.Polygenic_effect) != expected {
+			t.Errorf("Polygenic string %s gets fitness %v", after, PolygenicFitness(ind))
+		}
+	}
+
+	ind.PolygenicSeq = encodePolygenic("AAAAAC")
+	p.CheckPolygenicTarget(5, false)
+	config.FMgr.CloseAllFiles()
+	contents, err := os.ReadFile(filepath.Join(config.CmdArgs.DataPath, config.POLYGENIC_FILENAME))
+	if err != nil { t.Fatalf("Error reading %v: %v", config.POLYGENIC_FILENAME, err) }
+	if expected := "5  1  first_appeared\n"; string(contents) != expected { t.Errorf("%v contains %q, expected %q", config.POLYGENIC_FILENAME, contents, expected) }
+}
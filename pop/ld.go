@@ -0,0 +1,230 @@
+package pop
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"github.com/genetic-algorithms/mendel-go/random"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"log"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+// Linkage disequilibrium (ld_sample_size>0) samples individuals of each tribe in each gen the allele outputs are written, and calculates r^2 and |D'|
+// between every pair of tracked mutations on the same chromosome whose minor allele frequency in the sample is at least ld_min_freq. Each of the 2
+// copies of a chromosome in an individual is a haplotype. The pairs are summarized within an LB, across LBs, and by LB distance (the decay of LD),
+// and the haplotypes of each LB are summarized by the number of distinct haplotypes and the haplotype diversity (1 - sum of the squared haplotype
+// frequencies). These are written to linkage-disequilibrium/<gen>.json.
+
+// MAX_LD_MUTATIONS_PER_CHROMOSOME limits the number of mutations of a chromosome that are paired, because the number of pairs grows as the square of it
+const MAX_LD_MUTATIONS_PER_CHROMOSOME = 500
+
+// LdSummary is the mean LD of a group of mutation pairs
+type LdSummary struct {
+	NumPairs uint64 `json:"numPairs"`
+	MeanR2 float64 `json:"meanR2"`
+	MeanDPrime float64 `json:"meanDPrime"`
+}
+
+// LdDecayBin is the mean LD of the mutation pairs that are Distance LBs apart (0 is within the same LB)
+type LdDecayBin struct {
+	Distance int `json:"distance"`
+	LdSummary
+}
+
+type LinkageDisequilibrium struct {
+	Generation uint32 `json:"generation"`
+	SampleSize uint32 `json:"sampleSize"`
+	NumMutations uint32 `json:"numMutations"`
+	WithinLb LdSummary `json:"withinLb"`
+	AcrossLb LdSummary `json:"acrossLb"`
+	Decay []LdDecayBin `json:"decay"`
+	MeanHaplotypesPerLb float64 `json:"meanHaplotypesPerLb"`
+	MeanHaplotypeDiversity float64 `json:"meanHaplotypeDiversity"`
+}
+
+// ldMutation is a mutation in the sample, with the haplotypes it is in as a bit set
+type ldMutation struct {
+	id uint64
+	lb int
+	haps []uint64
+	count int
+}
+
+// ldSums accumulates the r^2 and |D'| of mutation pairs
+type ldSums struct {
+	numPairs uint64
+	r2, dPrime float64
+}
+
+func (s *ldSums) add(r2, dPrime float64) {
+	s.numPairs++
+	s.r2 += r2
+	s.dPrime += dPrime
+}
+
+func (s *ldSums) summary() LdSummary {
+	if s.numPairs == 0 { return LdSummary{} }
+	return LdSummary{NumPairs: s.numPairs, MeanR2: s.r2 / float64(s.numPairs), MeanDPrime: s.dPrime / float64(s.numPairs)}
+}
+
+
+// IsLdEnabled returns true if the linkage disequilibrium of a sample of each tribe should be written to linkage-disequilibrium/
+func IsLdEnabled() bool { return config.FMgr.IsDir(config.LD_DIRECTORY) }
+
+
+// LdPairStats returns r^2 and |D'| of 2 loci, given the frequency of each of them and the frequency of the haplotypes that have both
+func LdPairStats(pA, pB, pAB float64) (r2, dPrime float64) {
+	d := pAB - pA * pB
+	denom := pA * (1.0 - pA) * pB * (1.0 - pB)
+	if denom <= 0.0 { return }
+	r2 = d * d / denom
+	var dMax float64
+	if d > 0.0 {
+		dMax = math.Min(pA * (1.0 - pB), (1.0 - pA) * pB)
+	} else {
+		dMax = math.Min(pA * pB, (1.0 - pA) * (1.0 - pB))
+	}
+	if dMax > 0.0 { dPrime = math.Abs(d) / dMax }
+	return
+}
+
+
+// CalcLinkageDisequilibrium writes the LD and haplotype stats of a sample of each tribe, if this is a gen the allele outputs are written in.
+// This must be called before ReportEachGen(), which frees the individuals in the last gen.
+func (s *Species) CalcLinkageDisequilibrium(genNum uint32, lastGen bool) {
+	if !IsLdEnabled() || !IsPlotAlleleGen(genNum, lastGen) { return }
+	defer utils.Measure.Start("LinkageDisequilibrium").Stop("LinkageDisequilibrium")
+	// Use a separate random number generator, so calculating LD does not change the rest of the run. It is seeded by the gen so a restart gets the same sample.
+	seed := config.Cfg.Computation.Random_number_seed
+	if seed == 0 { seed = random.GetSeed() }
+	uniformRandom := rand.New(rand.NewSource(seed + int64(genNum)))
+	for _, p := range s.Populations {
+		if p.GetCurrentSize() == 0 { continue }
+		ld := p.calcLinkageDisequilibrium(genNum, config.Cfg.Computation.Ld_sample_size, config.Cfg.Computation.Ld_min_freq, uniformRandom)
+		config.Verbose(1, "Tribe %d LD of %d mutations: within LB r^2: %v, |D'|: %v, across LBs r^2: %v, |D'|: %v, mean haplotypes per LB: %v", p.TribeNum, ld.NumMutations, ld.WithinLb.MeanR2, ld.WithinLb.MeanDPrime, ld.AcrossLb.MeanR2, ld.AcrossLb.MeanDPrime, ld.MeanHaplotypesPerLb)
+
+		newJson, err := json.Marshal(ld)
+		if err != nil { log.Fatalf("error marshaling linkage disequilibrium to json: %v", err) }
+		fileName := fmt.Sprintf("%08d.json", genNum)
+		if ldWriter := config.FMgr.GetDirFile(config.LD_DIRECTORY, fileName, p.TribeNum); ldWriter != nil {
+			if _, err := ldWriter.Write(newJson); err != nil { log.Fatalf("error writing linkage disequilibrium to %v: %v", fileName, err) }
+			config.FMgr.CloseDirFile(config.LD_DIRECTORY, fileName, p.TribeNum)
+		}
+	}
+}
+
+
+// calcLinkageDisequilibrium samples sampleSize individuals of this tribe and calculates the LD between their mutations and the haplotype stats of their LBs
+func (p *Population) calcLinkageDisequilibrium(genNum, sampleSize uint32, minFreq float64, uniformRandom *rand.Rand) *LinkageDisequilibrium {
+	indices := uniformRandom.Perm(int(p.GetCurrentSize()))
+	if int(sampleSize) < len(indices) { indices = indices[:sampleSize] }
+	ld := &LinkageDisequilibrium{Generation: genNum, SampleSize: uint32(len(indices))}
+	numHaps := 2 * len(indices)
+	numWords := (numHaps + 63) / 64
+	var within, across ldSums
+	var decay []ldSums
+	var sumHaplotypes, sumDiversity float64
+	var numLbs int
+
+	numChromosomes := len(p.IndivRefs[indices[0]].Indiv.ChromosomesFromDad)
+	for c := 0; c < numChromosomes; c++ {
+		// Gather the haplotypes of this chromosome: the dad and mom copies of each sampled individual
+		haplotypes := make([]*dna.Chromosome, 0, numHaps)
+		for _, i := range indices {
+			ind := p.IndivRefs[i].Indiv
+			haplotypes = append(haplotypes, &ind.ChromosomesFromDad[c], &ind.ChromosomesFromMom[c])
+		}
+		numLbsInChr := len(haplotypes[0].LinkageBlocks)
+		if len(decay) < numLbsInChr { decay = append(decay, make([]ldSums, numLbsInChr - len(decay))...) }
+
+		// Find the haplotypes each mutation is in, and the haplotype stats of each LB
+		mutns := make(map[uint64]*ldMutation)
+		for lb := 0; lb < numLbsInChr; lb++ {
+			lbHaplotypes := make(map[uint64]int)		// key is a hash of the mutations in the LB, value is the number of haplotypes with it
+			for h, chromo := range haplotypes {
+				var hash uint64
+				for _, m := range chromo.LinkageBlocks[lb].GetMutations() {
+					hash += mixMutationId(m.Id)		// order independent
+					mutn := mutns[m.Id]
+					if mutn == nil {
+						mutn = &ldMutation{id: m.Id, lb: lb, haps: make([]uint64, numWords)}
+						mutns[m.Id] = mutn
+					}
+					if mutn.haps[h/64] & (1 << uint(h%64)) == 0 {
+						mutn.haps[h/64] |= 1 << uint(h%64)
+						mutn.count++
+					}
+				}
+				lbHaplotypes[hash]++
+			}
+			diversity := 1.0
+			for _, count := range lbHaplotypes {
+				freq := float64(count) / float64(numHaps)
+				diversity -= freq * freq
+			}
+			sumHaplotypes += float64(len(lbHaplotypes))
+			sumDiversity += diversity
+			numLbs++
+		}
+
+		// Only pair the mutations that are common enough in the sample, in id order so the result is reproducible
+		var common []*ldMutation
+		for _, mutn := range mutns {
+			freq := float64(mutn.count) / float64(numHaps)
+			if math.Min(freq, 1.0 - freq) >= minFreq { common = append(common, mutn) }
+		}
+		sort.Slice(common, func(i, j int) bool { return common[i].id < common[j].id })
+		if len(common) > MAX_LD_MUTATIONS_PER_CHROMOSOME {
+			chosen := make([]*ldMutation, 0, MAX_LD_MUTATIONS_PER_CHROMOSOME)
+			for _, i := range uniformRandom.Perm(len(common))[:MAX_LD_MUTATIONS_PER_CHROMOSOME] { chosen = append(chosen, common[i]) }
+			common = chosen
+		}
+		ld.NumMutations += uint32(len(common))
+
+		for i := 0; i < len(common); i++ {
+			a := common[i]
+			pA := float64(a.count) / float64(numHaps)
+			for j := i + 1; j < len(common); j++ {
+				b := common[j]
+				var both int
+				for w := range a.haps { both += bits.OnesCount64(a.haps[w] & b.haps[w]) }
+				r2, dPrime := LdPairStats(pA, float64(b.count) / float64(numHaps), float64(both) / float64(numHaps))
+				distance := a.lb - b.lb
+				if distance < 0 { distance = -distance }
+				if distance == 0 {
+					within.add(r2, dPrime)
+				} else {
+					across.add(r2, dPrime)
+				}
+				decay[distance].add(r2, dPrime)
+			}
+		}
+	}
+
+	ld.WithinLb = within.summary()
+	ld.AcrossLb = across.summary()
+	ld.Decay = make([]LdDecayBin, 0, len(decay))
+	for distance := range decay {
+		if decay[distance].numPairs == 0 { continue }
+		ld.Decay = append(ld.Decay, LdDecayBin{Distance: distance, LdSummary: decay[distance].summary()})
+	}
+	if numLbs > 0 {
+		ld.MeanHaplotypesPerLb = sumHaplotypes / float64(numLbs)
+		ld.MeanHaplotypeDiversity = sumDiversity / float64(numLbs)
+	}
+	return ld
+}
+
+
+// mixMutationId scrambles the bits of a mutation id (splitmix64), so the sum of them identifies the set of mutations in an LB
+func mixMutationId(id uint64) uint64 {
+	z := id + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
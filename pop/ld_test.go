@@ -0,0 +1,32 @@
+package pop
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Loci that are always inherited together must have r^2 and |D'| of 1, and independent loci must have 0
+func TestLdPairStats(t *testing.T) {
+	if r2, dPrime := LdPairStats(0.5, 0.5, 0.5); math.Abs(r2 - 1.0) > 1.e-9 || math.Abs(dPrime - 1.0) > 1.e-9 { t.Errorf("Complete LD gave r^2=%v, |D'|=%v, expected 1 and 1", r2, dPrime) }
+	if r2, dPrime := LdPairStats(0.5, 0.5, 0.0); math.Abs(r2 - 1.0) > 1.e-9 || math.Abs(dPrime - 1.0) > 1.e-9 { t.Errorf("Complete repulsion gave r^2=%v, |D'|=%v, expected 1 and 1", r2, dPrime) }
+	if r2, dPrime := LdPairStats(0.2, 0.4, 0.08); math.Abs(r2) > 1.e-9 || math.Abs(dPrime) > 1.e-9 { t.Errorf("Independent loci gave r^2=%v, |D'|=%v, expected 0 and 0", r2, dPrime) }
+	// A rare allele that is only on haplotypes with the common one has |D'| of 1, but a small r^2
+	if r2, dPrime := LdPairStats(0.1, 0.5, 0.1); math.Abs(dPrime - 1.0) > 1.e-9 || math.Abs(r2 - 1.0/9.0) > 1.e-9 { t.Errorf("Nested alleles gave r^2=%v, |D'|=%v, expected %v and 1", r2, dPrime, 1.0/9.0) }
+}
+
+// The LD of a sample of a real tribe must be within the possible ranges, and the decay bins must add up to the within and across LB totals
+func TestCalcLinkageDisequilibrium(t *testing.T) {
+	p := allelesTestPopulation(t)
+	ld := p.calcLinkageDisequilibrium(2, 10, 0.05, rand.New(rand.NewSource(1)))
+	if ld.SampleSize != 10 { t.Errorf("Sample size is %d, expected 10", ld.SampleSize) }
+	if ld.NumMutations == 0 { t.Fatalf("No mutations were common enough to pair") }
+	var numPairs uint64
+	for _, bin := range ld.Decay {
+		numPairs += bin.NumPairs
+		if bin.MeanR2 < 0.0 || bin.MeanR2 > 1.0+1.e-9 || bin.MeanDPrime < 0.0 || bin.MeanDPrime > 1.0+1.e-9 { t.Errorf("LB distance %d has r^2=%v, |D'|=%v, expected them to be between 0 and 1", bin.Distance, bin.MeanR2, bin.MeanDPrime) }
+	}
+	if numPairs != ld.WithinLb.NumPairs + ld.AcrossLb.NumPairs { t.Errorf("The decay bins have %d pairs, but within and across LBs have %d and %d", numPairs, ld.WithinLb.NumPairs, ld.AcrossLb.NumPairs) }
+	if ld.MeanHaplotypesPerLb < 1.0 || ld.MeanHaplotypesPerLb > 20.0 { t.Errorf("Mean haplotypes per LB is %v, expected 1 - 20", ld.MeanHaplotypesPerLb) }
+	if ld.MeanHaplotypeDiversity < 0.0 || ld.MeanHaplotypeDiversity >= 1.0 { t.Errorf("Mean haplotype diversity is %v, expected 0 - 1", ld.MeanHaplotypeDiversity) }
+}
@@ -0,0 +1,98 @@
+package pop
+
+import (
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"math/rand"
+)
+
+// The migration models correspond to the integer values of the migration_model config param
+type MigrationModelType int
+const (
+	NO_MIGRATION MigrationModelType = 0
+	ISLAND_MIGRATION MigrationModelType = 1		// emigrants go to any of the other tribes
+	STEPPING_STONE_MIGRATION MigrationModelType = 2		// the tribes are arranged in a ring and emigrants go to 1 of the 2 adjacent tribes
+	SOURCE_SINK_MIGRATION MigrationModelType = 3		// tribe 1 is the source and sends emigrants to the other tribes, which never send any back
+)
+
+// Algorithms for choosing which tribe an emigrant from tribe srcIndex goes to. Returns the index in Species.Populations, or -1 if the indiv should stay where it is.
+type ChooseMigrationDestType func(s *Species, srcIndex int, uniformRandom *rand.Rand) int
+
+
+// NoMigration is used when migration_model==0 or there is only 1 tribe
+func NoMigration(_ *Species, _ int, _ *rand.Rand) int { return -1 }
+
+
+// IslandMigration sends the emigrant to a random tribe other than the one it is in
+func IslandMigration(s *Species, srcIndex int, uniformRandom *rand.Rand) int {
+	// Pick from the n-1 other tribes by skipping over the source tribe
+	dest := uniformRandom.Intn(len(s.Populations) - 1)
+	if dest >= srcIndex { dest++ }
+	return dest
+}
+
+
+// SteppingStoneMigration sends the emigrant to 1 of the 2 tribes adjacent to it in the ring of tribes
+func SteppingStoneMigration(s *Species, srcIndex int, uniformRandom *rand.Rand) int {
+	numPops := len(s.Populations)
+	if uniformRandom.Intn(2) == 0 {
+		return (srcIndex + numPops - 1) % numPops
+	} else {
+		return (srcIndex + 1) % numPops
+	}
+}
+
+
+// SourceSinkMigration sends emigrants only from tribe 1 to a random one of the other tribes
+func SourceSinkMigration(s *Species, srcIndex int, uniformRandom *rand.Rand) int {
+	if srcIndex != 0 { return -1 }
+	return 1 + uniformRandom.Intn(len(s.Populations) - 1)
+}
+
+
+// IsMigrationEnabled returns true if individuals move between tribes in this run
+func IsMigrationEnabled() bool {
+	return (config.Cfg.Tribes.Num_tribes > 1 || config.Cfg.Tribes.Tribal_fission) && MigrationModelType(config.Cfg.Tribes.Migration_model) != NO_MIGRATION
+}
+
+
+// Migrate moves num_indiv_exchanged randomly chosen individuals out of each tribe to other tribes (chosen by migration_model), every migration_generations.
+// This is called after selection, so the individuals that migrate will mate in their new tribe in the next gen.
+func (s *Species) Migrate(genNum uint32, uniformRandom *rand.Rand) {
+	if !IsMigrationEnabled() || len(s.Populations) < 2 || genNum % config.Cfg.Tribes.Migration_generations != 0 { return }
+	defer utils.Measure.Start("Migrate").Stop("Migrate")
+
+	// Choose all of the emigrants before moving any of them, so an immigrant is never immediately moved on to another tribe
+	emigrants := make([][]IndivRef, len(s.Populations))		// indexed by the destination tribe
+	for i, p := range s.Populations {
+		if p.Done { continue }
+		numToMove := utils.MinUint32(config.Cfg.Tribes.Num_indiv_exchanged, p.GetCurrentSize())
+		// Do a partial shuffle of IndivRefs so the individuals that will leave are randomly chosen and at the front
+		numMoved := 0
+		for j := 0; j < int(numToMove); j++ {
+			dest := Mdl.ChooseMigrationDest(s, i, uniformRandom)
+			if dest < 0 || s.Populations[dest].Done { continue }
+			k := numMoved + uniformRandom.Intn(len(p.IndivRefs) - numMoved)
+			p.IndivRefs[numMoved], p.IndivRefs[k] = p.IndivRefs[k], p.IndivRefs[numMoved]
+			emigrants[dest] = append(emigrants[dest], p.IndivRefs[numMoved])
+			numMoved++
+		}
+		if numMoved == 0 { continue }
+		p.IndivRefs = p.IndivRefs[numMoved:]
+		p.NumEmigrants += uint32(numMoved)
+		p.invalidateStats()
+	}
+
+	for dest, refs := range emigrants {
+		if len(refs) == 0 { continue }
+		p := s.Populations[dest]
+		for _, indRef := range refs {
+			indRef.Indiv.popPart = p.Parts[0]		// so the indiv gets the attributes of its new tribe when it mates
+			p.Parts[0].Indivs = append(p.Parts[0].Indivs, indRef.Indiv)		// so the part it now belongs to has it, like the part it was created in
+			p.IndivRefs = append(p.IndivRefs, indRef)
+		}
+		p.NumImmigrants += uint32(len(refs))
+		p.invalidateStats()
+		config.Verbose(2, "Gen %d: %d individuals migrated into tribe %d", genNum, len(refs), p.TribeNum)
+	}
+}
@@ -0,0 +1,67 @@
+package pop
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+)
+
+// Migration must move individuals between tribes without losing or duplicating any, and count them in both tribes
+func TestMigrate(t *testing.T) {
+	for _, model := range []MigrationModelType{ISLAND_MIGRATION, STEPPING_STONE_MIGRATION, SOURCE_SINK_MIGRATION} {
+		setUpPopTest(t, func(c *config.Config) {
+			c.Basic.Pop_size = 20
+			c.Tribes.Num_tribes = 4
+			c.Tribes.Num_indiv_exchanged = 3
+			c.Tribes.Migration_generations = 2
+			c.Tribes.Migration_model = int(model)
+		})
+		uniformRandom := rand.New(rand.NewSource(1))
+		s := SpeciesFactory()
+		for i := range s.Populations { s.Populations[i] = PopulationFactory(nil, 0, uint32(i+1), s.PartsPerPop) }
+		origTribe := make(map[*Individual]int)
+		for i, p := range s.Populations {
+			for _, indRef := range p.IndivRefs { origTribe[indRef.Indiv] = i }
+		}
+
+		s.Migrate(1, uniformRandom)		// not a migration gen
+		if s.Populations[0].NumEmigrants != 0 { t.Errorf("Model %d: individuals migrated in a gen that is not a multiple of migration_generations", model) }
+
+		s.Migrate(2, uniformRandom)
+		var totalImmigrants, totalEmigrants uint32
+		seen := make(map[*Individual]bool)
+		for i, p := range s.Populations {
+			var numFromElsewhere uint32
+			for _, indRef := range p.IndivRefs {
+				ind := indRef.Indiv
+				if seen[ind] { t.Errorf("Model %d: individual is in more than 1 tribe after migration", model) }
+				seen[ind] = true
+				if ind.popPart.Pop != p { t.Errorf("Model %d: migrated individual does not point to its new tribe", model) }
+				if origTribe[ind] != i {
+					numFromElsewhere++
+					if !partHasIndiv(ind.popPart, ind) { t.Errorf("Model %d: migrated individual is not in the part of its new tribe", model) }
+					if model == STEPPING_STONE_MIGRATION && (origTribe[ind]+1)%4 != i && (i+1)%4 != origTribe[ind] { t.Errorf("Model %d: individual migrated from tribe %d to non-adjacent tribe %d", model, origTribe[ind]+1, i+1) }
+					if model == SOURCE_SINK_MIGRATION && origTribe[ind] != 0 { t.Errorf("Model %d: individual migrated from tribe %d, which is not the source", model, origTribe[ind]+1) }
+				}
+			}
+			if numFromElsewhere != p.NumImmigrants { t.Errorf("Model %d: tribe %d has %d immigrants, but recorded %d", model, i+1, numFromElsewhere, p.NumImmigrants) }
+			totalImmigrants += p.NumImmigrants
+			totalEmigrants += p.NumEmigrants
+		}
+		if len(seen) != len(origTribe) { t.Errorf("Model %d: there are %d individuals after migration, expected %d", model, len(seen), len(origTribe)) }
+		if totalImmigrants != totalEmigrants { t.Errorf("Model %d: total immigrants %d does not equal total emigrants %d", model, totalImmigrants, totalEmigrants) }
+		expectedEmigrants := uint32(3 * 4)
+		if model == SOURCE_SINK_MIGRATION { expectedEmigrants = 3 }
+		if totalEmigrants != expectedEmigrants { t.Errorf("Model %d: %d individuals migrated, expected %d", model, totalEmigrants, expectedEmigrants) }
+	}
+}
+
+
+// partHasIndiv returns true if ind is in the Indivs of part
+func partHasIndiv(part *PopulationPart, ind *Individual) bool {
+	for _, partInd := range part.Indivs {
+		if partInd == ind { return true }
+	}
+	return false
+}
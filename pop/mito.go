@@ -0,0 +1,82 @@
+package pop
+
+import (
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"log"
+	"math/rand"
+)
+
+// When mito_linkage_subunits > 0 each individual has a haploid mitochondrial genome (Individual.Mitochondrion) in addition to its nuclear chromosomes.
+// It is copied whole from the mother (with no crossover), gets its own new mutations (see Chromosome.AppendMitoMutation()), and its fitness effect is
+// added to the individual's fitness. Its mutations are not counted in the individual's NumMutations, NumDeleterious, etc., so the nuclear stats
+// and outputs are unchanged, and ReportMito() and writeMito() report them separately.
+
+const MITO_HEADER = "# Generation  Avg-deleterious  Avg-neutral  Avg-fitness-effect\n"
+
+
+// addMitoMutations adds new mutations to the mitochondrial genome of this child right after mating
+func (child *Individual) addMitoMutations(uniformRandom *rand.Rand) {
+	popPart := child.popPart
+	numMutations := popPart.Pop.Mdl.CalcNumMutations(popPart.Pop.Cfg.Mutations.Mito_mutn_rate, uniformRandom)
+	numLbs := len(child.Mitochondrion.LinkageBlocks)
+	for m:=uint32(1); m<=numMutations; m++ {
+		child.Mitochondrion.AppendMitoMutation(uniformRandom.Intn(numLbs), popPart.MyUniqueInt.NextInt(), uniformRandom)
+	}
+}
+
+
+// GetMitoStats returns the mean number of deleterious and neutral mutations in, and the mean fitness effect of, the mitochondrial genomes of this population
+func (p *Population) GetMitoStats() (deleterious, neutral, fitness float64) {
+	popSize := p.GetCurrentSize()
+	if popSize == 0 { return }
+	var numDel, numNeut uint32
+	for _, indRef := range p.IndivRefs {
+		mito := &indRef.Indiv.Mitochondrion
+		for i := range mito.LinkageBlocks {
+			d, n, _, _, _ := mito.LinkageBlocks[i].GetMutationStats()
+			numDel += d
+			numNeut += n
+		}
+		fitness += mito.SumFitness()
+	}
+	return float64(numDel) / float64(popSize), float64(numNeut) / float64(popSize), fitness / float64(popSize)
+}
+
+
+// GetMitoStats returns the mitochondrial stats of the whole species (see Population.GetMitoStats())
+func (s *Species) GetMitoStats() (deleterious, neutral, fitness float64) {
+	var speciesSize uint32
+	for _, p := range s.Populations {
+		popSize := p.GetCurrentSize()
+		if popSize == 0 { continue }
+		d, n, f := p.GetMitoStats()
+		deleterious += d * float64(popSize)		// weight each tribe by its size, so these are the means of all of the individuals
+		neutral += n * float64(popSize)
+		fitness += f * float64(popSize)
+		speciesSize += popSize
+	}
+	if speciesSize == 0 { return }
+	return deleterious / float64(speciesSize), neutral / float64(speciesSize), fitness / float64(speciesSize)
+}
+
+
+// ReportMito logs the mitochondrial stats of this population
+func (p *Population) ReportMito() {
+	if p.GetCurrentSize() == 0 { return }
+	deleterious, neutral, fitness := p.GetMitoStats()
+	log.Printf(" Mitochondria means: deleterious: %v, neutral: %v, fitness effect: %v", deleterious, neutral, fitness)
+}
+
+
+// writeMito writes a line of mitochondrial stats to mendel.mit for tribeNum (0 is the whole species), if it is being output
+func writeMito(genNum, tribeNum uint32, deleterious, neutral, fitness float64) {
+	mitoWriter := config.FMgr.GetFile(config.MITO_FILENAME, tribeNum)
+	if mitoWriter == nil { return }
+	config.Verbose(5, "Writing to file %v", config.MITO_FILENAME)
+	if _, err := fmt.Fprintf(mitoWriter, "%d  %v  %v  %v\n", genNum, deleterious, neutral, fitness); err != nil { log.Fatalf("Error writing %v: %v", config.MITO_FILENAME, err) }
+}
+
+
+// hasMito returns true if individuals have a mitochondrial genome
+func hasMito() bool { return config.Cfg.Population.Mito_linkage_subunits > 0 }
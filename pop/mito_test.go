@@ -0,0 +1,63 @@
+package pop
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+)
+
+// The mitochondrial genome must only be inherited from the mother, and its new mutations must have their full effect on the fitness
+func TestMitochondria(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 40
+		c.Mutations.Mutn_rate = 0.0
+		c.Mutations.Mito_mutn_rate = 5.0
+		c.Mutations.Mito_fitness_effect_model = "fixed"
+		c.Mutations.Mito_dfe_scale = 0.01
+		c.Mutations.Mito_fraction_neutral = 0.0
+		c.Population.Separate_sexes = true
+		c.Population.Mito_linkage_subunits = 4
+		c.Computation.Tracking_threshold = 0.0
+	})
+
+	// Mark the mitochondria of the females, so the children show which parent theirs came from
+	uniformRandom := rand.New(rand.NewSource(1))
+	parents := SpeciesFactory().Initialize(1, uniformRandom)
+	const markerId, markerEffect = uint64(1) << 62, -0.001		// the id must not be one a new mutation can get
+	for _, indRef := range parents.Populations[0].IndivRefs {
+		if len(indRef.Indiv.Mitochondrion.LinkageBlocks) != 4 { t.Fatalf("The mitochondrion has %d LBs, expected 4", len(indRef.Indiv.Mitochondrion.LinkageBlocks)) }
+		if !indRef.Indiv.Male { indRef.Indiv.Mitochondrion.AddMutation(0, dna.Mutation{Id: markerId, Type: dna.DELETERIOUS_DOMINANT, FitnessEffect: markerEffect}) }
+	}
+	children := parents.GetNextGeneration(1)
+	parents.Mate(children, uniformRandom)
+	p := children.Populations[0]
+	if p.GetCurrentSize() == 0 { t.Fatalf("No children were created") }
+
+	var numNew int
+	for _, indRef := range p.IndivRefs {
+		ind := indRef.Indiv
+		var hasMarker bool
+		var indNew int
+		for _, lb := range ind.Mitochondrion.LinkageBlocks {
+			for _, m := range lb.GetMutations() {
+				if m.Id == markerId {
+					hasMarker = true
+				} else {
+					indNew++
+				}
+			}
+		}
+		if !hasMarker { t.Fatalf("A child does not have its mother's mitochondrion") }
+		expected := 1.0 + markerEffect - 0.01 * float64(indNew)
+		if math.Abs(ind.GenoFitness - expected) > 1.e-5 { t.Errorf("A child with %d new mitochondrial mutations has fitness %v, expected %v", indNew, ind.GenoFitness, expected) }
+		if ind.NumMutations != 0 { t.Errorf("A child has %d nuclear mutations, expected the mitochondrial mutations not to be counted", ind.NumMutations) }
+		numNew += indNew
+	}
+	if mean := float64(numNew) / float64(p.GetCurrentSize()); math.Abs(mean - 5.0) > 1.0 { t.Errorf("The mean number of new mitochondrial mutations is %v, expected about 5", mean) }
+	if deleterious, neutral, _ := p.GetMitoStats(); math.Abs(deleterious - 1.0 - float64(numNew) / float64(p.GetCurrentSize())) > 1.e-9 || neutral != 0.0 {
+		t.Errorf("The mitochondrial stats are deleterious: %v, neutral: %v, expected %v and 0", deleterious, neutral, 1.0 + float64(numNew) / float64(p.GetCurrentSize()))
+	}
+}
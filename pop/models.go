@@ -42,6 +42,13 @@ type InitialAlleleModelType string
 const (
 	ALLUNIQUE_INITIAL_ALLELES     InitialAlleleModelType = "allunique"
 	VARIABLE_FREQ_INITIAL_ALLELES InitialAlleleModelType = "variablefreq"
+	UPLOAD_INITIAL_ALLELES        InitialAlleleModelType = "upload"
+)
+
+type SexChromosomeSystemType string
+const (
+	XY_SEX_CHROMOSOMES SexChromosomeSystemType = "xy"
+	ZW_SEX_CHROMOSOMES SexChromosomeSystemType = "zw"
 )
 
 
@@ -49,15 +56,22 @@ const (
 type Models struct {
 	CalcNumOffspring CalcNumOffspringType
 	CalcIndivFitness CalcIndivFitnessType
+	CalcEpistasis CalcEpistasisType
 	CalcNumMutations CalcNumMutationsType
 	ApplySelectionNoise ApplySelectionNoiseType
 	PopulationGrowth PopulationGrowthType
 	GenerateInitialAlleles GenerateInitialAllelesType
+	ChooseMigrationDest ChooseMigrationDestType
+	SplitTribe SplitTribeType
+	SampleTrajectories SampleTrajectoriesType
 }
 
 // Mdl is the singleton instance of Models that can be accessed throughout the dna package. It gets set in SetModels().
 var Mdl *Models
 
+// TribeMdls holds the models of each tribe that has its own values in the input file, keyed by tribe number. The other tribes use Mdl.
+var TribeMdls map[uint32]*Models
+
 
 // SetModels is called by main.initialize() to set the function ptrs for the various algorithms chosen by the input file.
 func SetModels(c *config.Config) {
@@ -82,6 +96,19 @@ func SetModels(c *config.Config) {
 		log.Fatalf("Error: unrecognized value for mum_offspring_model: %v", c.Population.Num_offspring_model)
 	}
 
+	switch RecombinationType(c.Population.Recombination_model) {
+	case CLONAL:
+		mdlNames = append(mdlNames, "ClonalReproduction")
+	case SUPPRESSED:
+		// dna.SetModels() uses NoCrossover for this
+		mdlNames = append(mdlNames, "SuppressedRecombination")
+	case FULL_SEXUAL:
+		mdlNames = append(mdlNames, "FullSexualReproduction")
+	default:
+		log.Fatalf("Error: unrecognized value for recombination_model: %v", c.Population.Recombination_model)
+	}
+	if c.Population.Fraction_self_fertilization < 0.0 || c.Population.Fraction_self_fertilization > 1.0 { log.Fatalln("fraction_self_fertilization must be >= 0.0 and <= 1.0") }
+
 	if c.Mutations.Multiplicative_weighting > 0.0 {
 		Mdl.CalcIndivFitness = MultIndivFitness
 		mdlNames = append(mdlNames, "MultIndivFitness")
@@ -90,6 +117,15 @@ func SetModels(c *config.Config) {
 		mdlNames = append(mdlNames, "SumIndivFitness")
 	}
 
+	if c.Mutations.Synergistic_epistasis {
+		if c.Mutations.Se_linked_scaling < 0.0 || c.Mutations.Se_nonlinked_scaling < 0.0 { log.Fatalln("se_linked_scaling and se_nonlinked_scaling can not be < 0.0") }
+		Mdl.CalcEpistasis = SynergisticEpistasis
+		mdlNames = append(mdlNames, "SynergisticEpistasis")
+	} else {
+		Mdl.CalcEpistasis = NoEpistasis
+	}
+	if c.Mutations.Polygenic_beneficials { ValidatePolygenic(c) }
+
 	switch MutationRateModelType(strings.ToLower(c.Mutations.Mutn_rate_model)) {
 	case FIXED_MUTN_RATE:
 		Mdl.CalcNumMutations = CalcSemiFixedNumMutations
@@ -101,19 +137,109 @@ func SetModels(c *config.Config) {
 		log.Fatalf("Error: unrecognized value for mutn_rate_model: %v", c.Mutations.Mutn_rate_model)
 	}
 
+	mdlNames = append(mdlNames, Mdl.setTribeModels(c)...)
+
+	switch InitialAlleleModelType(strings.ToLower(c.Population.Initial_allele_fitness_model)) {
+	case ALLUNIQUE_INITIAL_ALLELES:
+		if c.Population.Num_contrasting_alleles > 0 && (c.Population.Initial_alleles_pop_frac <= 0.0 || c.Population.Initial_alleles_pop_frac > 1.0) { log.Fatalf("if num_contrasting_alleles is > 0 and initial_allele_fitness_model==%s, then initial_alleles_pop_frac must be > 0 and <= 1.0", string(ALLUNIQUE_INITIAL_ALLELES)) }
+		if c.Population.Num_contrasting_alleles > 0 && c.Population.Max_total_fitness_increase <= 0.0 { log.Fatalf("Error: if initial_allele_fitness_model==%s, then max_total_fitness_increase must be > 0.", string(ALLUNIQUE_INITIAL_ALLELES)) }
+		Mdl.GenerateInitialAlleles = GenerateAllUniqueInitialAlleles
+		mdlNames = append(mdlNames, "GenerateAllUniqueInitialAlleles")
+		dna.Mdl.CalcAlleleFitness = dna.CalcUniformAlleleFitness
+		mdlNames = append(mdlNames, "CalcUniformAlleleFitness")
+	case VARIABLE_FREQ_INITIAL_ALLELES:
+		if c.Population.Num_contrasting_alleles > 0 && c.Population.Initial_alleles_frequencies == "" { log.Fatalf("if num_contrasting_alleles is > 0 and initial_allele_fitness_model==%s, then initial_alleles_frequencies must be like: alfrac1:freq1, alfrac2:freq2, ...", string(VARIABLE_FREQ_INITIAL_ALLELES)) }
+		if c.Population.Num_contrasting_alleles > 0 && c.Population.Max_total_fitness_increase <= 0.0 { log.Fatalf("Error: if initial_allele_fitness_model==%s, then max_total_fitness_increase must be > 0.", string(VARIABLE_FREQ_INITIAL_ALLELES)) }
+		Mdl.GenerateInitialAlleles = GenerateVariableFreqInitialAlleles
+		mdlNames = append(mdlNames, "GenerateVariableFreqInitialAlleles")
+		dna.Mdl.CalcAlleleFitness = dna.CalcUniformAlleleFitness
+		mdlNames = append(mdlNames, "CalcUniformAlleleFitness")
+	case UPLOAD_INITIAL_ALLELES:
+		if c.Population.Upload_mutations_file == "" { log.Fatalf("Error: if initial_allele_fitness_model==%s, then upload_mutations_file must be specified", string(UPLOAD_INITIAL_ALLELES)) }
+		Mdl.GenerateInitialAlleles = GenerateUploadedInitialAlleles
+		mdlNames = append(mdlNames, "GenerateUploadedInitialAlleles")
+		dna.Mdl.CalcAlleleFitness = dna.CalcUniformAlleleFitness		// not used for uploaded mutations, but num_contrasting_alleles may still be set
+		mdlNames = append(mdlNames, "CalcUniformAlleleFitness")
+	default:
+		log.Fatalf("Error: unrecognized value for initial_allele_fitness_model: %v", c.Population.Initial_allele_fitness_model)
+	}
+
+	if c.Tribes.Num_tribes <= 1 && !c.Tribes.Tribal_fission {
+		Mdl.ChooseMigrationDest = NoMigration		// migration_model is ignored when there are no other tribes to migrate to
+	} else {
+		switch MigrationModelType(c.Tribes.Migration_model) {
+		case NO_MIGRATION:
+			Mdl.ChooseMigrationDest = NoMigration
+		case ISLAND_MIGRATION:
+			Mdl.ChooseMigrationDest = IslandMigration
+			mdlNames = append(mdlNames, "IslandMigration")
+		case STEPPING_STONE_MIGRATION:
+			Mdl.ChooseMigrationDest = SteppingStoneMigration
+			mdlNames = append(mdlNames, "SteppingStoneMigration")
+		case SOURCE_SINK_MIGRATION:
+			Mdl.ChooseMigrationDest = SourceSinkMigration
+			mdlNames = append(mdlNames, "SourceSinkMigration")
+		default:
+			log.Fatalf("Error: unrecognized value for migration_model: %v", c.Tribes.Migration_model)
+		}
+		if MigrationModelType(c.Tribes.Migration_model) != NO_MIGRATION && c.Tribes.Migration_generations == 0 { log.Fatalln("If migration_model is not 0, migration_generations must be > 0") }
+		if c.Tribes.Tribal_competition { ValidateTribalCompetition(c) }
+	}
+
+	if c.Tribes.Tribal_fission {
+		if c.Tribes.Fission_pop_size < 2 { log.Fatalln("If tribal_fission is true, fission_pop_size must be >= 2") }
+		switch FissionModelType(strings.ToLower(c.Tribes.Fission_model)) {
+		case RANDOM_FISSION:
+			Mdl.SplitTribe = RandomSplitTribe
+			mdlNames = append(mdlNames, "RandomSplitTribe")
+		case KINSHIP_FISSION:
+			Mdl.SplitTribe = KinshipSplitTribe
+			mdlNames = append(mdlNames, "KinshipSplitTribe")
+		default:
+			log.Fatalf("Error: unrecognized value for fission_model: %v", c.Tribes.Fission_model)
+		}
+	}
+
+	if c.Computation.Num_trajectories > 0 {
+		switch TrajectoryModelType(strings.ToLower(c.Computation.Trajectory_model)) {
+		case RANDOM_TRAJECTORIES:
+			Mdl.SampleTrajectories = RandomSampleTrajectories
+			mdlNames = append(mdlNames, "RandomSampleTrajectories")
+		case CLASS_TRAJECTORIES:
+			Mdl.SampleTrajectories = ClassSampleTrajectories
+			mdlNames = append(mdlNames, "ClassSampleTrajectories")
+		default:
+			log.Fatalf("Error: unrecognized value for trajectory_model: %v", c.Computation.Trajectory_model)
+		}
+	}
+
+	config.Verbose(1, "Running with these pop models: %v", strings.Join(mdlNames, ", "))
+
+	// The tribes that have their own values in the input file get their own copy of the models
+	TribeMdls = make(map[uint32]*Models)
+	for tribeNum, tc := range config.TribeCfgs {
+		tribeMdl := *Mdl
+		config.Verbose(1, "Running tribe %d with these pop models: %v", tribeNum, strings.Join(tribeMdl.setTribeModels(tc), ", "))
+		TribeMdls[tribeNum] = &tribeMdl
+	}
+}
+
+
+// setTribeModels sets the models that can be different for each tribe (because they are based on values that can be in a [tribes.N] table)
+func (m *Models) setTribeModels(c *config.Config) (mdlNames []string) {
 	switch SelectionNoiseModelType(strings.ToLower(c.Selection.Selection_model)) {
 	case FULL_TRUNC_SELECTION:
-		Mdl.ApplySelectionNoise = ApplyFullTruncationNoise
+		m.ApplySelectionNoise = ApplyFullTruncationNoise
 		mdlNames = append(mdlNames, "ApplyFullTruncationNoise")
 	case UNRESTRICT_PROB_SELECTION:
-		Mdl.ApplySelectionNoise = ApplyUnrestrictProbNoise
+		m.ApplySelectionNoise = ApplyUnrestrictProbNoise
 		mdlNames = append(mdlNames, "ApplyUnrestrictProbNoise")
 	case PROPORT_PROB_SELECTION:
-		Mdl.ApplySelectionNoise = ApplyProportProbNoise
+		m.ApplySelectionNoise = ApplyProportProbNoise
 		mdlNames = append(mdlNames, "ApplyProportProbNoise")
 	case PARTIAL_TRUNC_SELECTION:
 		if c.Selection.Partial_truncation_value <= 0.0 { log.Fatalln("partial_truncation_value must be > 0") }	// we end up dividing by it
-		Mdl.ApplySelectionNoise = ApplyPartialTruncationNoise
+		m.ApplySelectionNoise = ApplyPartialTruncationNoise
 		mdlNames = append(mdlNames, "ApplyPartialTruncationNoise")
 	default:
 		log.Fatalf("Error: unrecognized value for selection_model: %v", c.Selection.Selection_model)
@@ -121,28 +247,28 @@ func SetModels(c *config.Config) {
 
 	switch PopulationGrowthModelType(strings.ToLower(c.Population.Pop_growth_model)) {
 	case NO_POPULATON_GROWTH:
-		Mdl.PopulationGrowth = NoPopulationGrowth
+		m.PopulationGrowth = NoPopulationGrowth
 		mdlNames = append(mdlNames, "NoPopulationGrowth")
 		if c.Population.Multiple_Bottlenecks != "" { log.Fatalln("multiple_Bottlenecks can only be specified for pop_growth_model==multi-bottlenecks") }
 	case EXPONENTIAL_POPULATON_GROWTH:
-		Mdl.PopulationGrowth = ExponentialPopulationGrowth
+		m.PopulationGrowth = ExponentialPopulationGrowth
 		mdlNames = append(mdlNames, "ExponentialPopulationGrowth")
 		if c.Population.Pop_growth_rate <= 0.0 { log.Fatalln("For pop_growth_model==exponential pop_growth_rate must be > 0.0") }
 		if c.Basic.Num_generations == 0 && c.Population.Max_pop_size == 0 { log.Fatalln("For pop_growth_model==exponential at least 1 of num_generations and max_pop_size must be non-zero") }
 		if c.Population.Multiple_Bottlenecks != "" { log.Fatalln("multiple_Bottlenecks can only be specified for pop_growth_model==multi-bottlenecks") }
 	case CAPACITY_POPULATON_GROWTH:
-		Mdl.PopulationGrowth = CapacityPopulationGrowth
+		m.PopulationGrowth = CapacityPopulationGrowth
 		mdlNames = append(mdlNames, "CapacityPopulationGrowth")
 		if c.Population.Pop_growth_rate <= 0.0 { log.Fatalln("For pop_growth_model==capacity pop_growth_rate must be > 0.0") }
 		if c.Population.Multiple_Bottlenecks != "" { log.Fatalln("multiple_Bottlenecks can only be specified for pop_growth_model==multi-bottlenecks") }
 	case FOUNDERS_POPULATON_GROWTH:
-		Mdl.PopulationGrowth = FoundersPopulationGrowth
+		m.PopulationGrowth = FoundersPopulationGrowth
 		mdlNames = append(mdlNames, "FoundersPopulationGrowth")
 		if c.Population.Pop_growth_rate <= 0.0 || c.Population.Pop_growth_rate2 <= 0.0 { log.Fatalln("For pop_growth_model==founders pop_growth_rate and pop_growth_rate2 must be > 0.0") }
 		if c.Population.Bottleneck_generation > 0 && (c.Population.Bottleneck_pop_size == 0 || c.Population.Num_bottleneck_generations == 0) { log.Fatalln("For pop_growth_model==founders and bottleneck_generation > 0 then bottleneck_pop_size and num_bottleneck_generations must be > 0.0") }
 		if c.Population.Multiple_Bottlenecks != "" { log.Fatalln("multiple_Bottlenecks can only be specified for pop_growth_model==multi-bottlenecks") }
 	case MULTI_BOTTLENECK_POPULATON_GROWTH:
-		Mdl.PopulationGrowth = MultiBottleneckPopulationGrowth
+		m.PopulationGrowth = MultiBottleneckPopulationGrowth
 		mdlNames = append(mdlNames, "MultiBottleneckPopulationGrowth")
 		if c.Population.Multiple_Bottlenecks == "" { log.Fatalln("For pop_growth_model==multi-bottlenecks multiple_Bottlenecks must be specified") }
 		// these older config values should not be used with this growth model
@@ -150,25 +276,12 @@ func SetModels(c *config.Config) {
 	default:
 		log.Fatalf("Error: unrecognized value for pop_growth_model: %v", c.Population.Pop_growth_model)
 	}
+	return
+}
 
-	switch InitialAlleleModelType(strings.ToLower(c.Population.Initial_allele_fitness_model)) {
-	case ALLUNIQUE_INITIAL_ALLELES:
-		if c.Population.Num_contrasting_alleles > 0 && (c.Population.Initial_alleles_pop_frac <= 0.0 || c.Population.Initial_alleles_pop_frac > 1.0) { log.Fatalf("if num_contrasting_alleles is > 0 and initial_allele_fitness_model==%s, then initial_alleles_pop_frac must be > 0 and <= 1.0", string(ALLUNIQUE_INITIAL_ALLELES)) }
-		if c.Population.Num_contrasting_alleles > 0 && c.Population.Max_total_fitness_increase <= 0.0 { log.Fatalf("Error: if initial_allele_fitness_model==%s, then max_total_fitness_increase must be > 0.", string(ALLUNIQUE_INITIAL_ALLELES)) }
-		Mdl.GenerateInitialAlleles = GenerateAllUniqueInitialAlleles
-		mdlNames = append(mdlNames, "GenerateAllUniqueInitialAlleles")
-		dna.Mdl.CalcAlleleFitness = dna.CalcUniformAlleleFitness
-		mdlNames = append(mdlNames, "CalcUniformAlleleFitness")
-	case VARIABLE_FREQ_INITIAL_ALLELES:
-		if c.Population.Num_contrasting_alleles > 0 && c.Population.Initial_alleles_frequencies == "" { log.Fatalf("if num_contrasting_alleles is > 0 and initial_allele_fitness_model==%s, then initial_alleles_frequencies must be like: alfrac1:freq1, alfrac2:freq2, ...", string(VARIABLE_FREQ_INITIAL_ALLELES)) }
-		if c.Population.Num_contrasting_alleles > 0 && c.Population.Max_total_fitness_increase <= 0.0 { log.Fatalf("Error: if initial_allele_fitness_model==%s, then max_total_fitness_increase must be > 0.", string(VARIABLE_FREQ_INITIAL_ALLELES)) }
-		Mdl.GenerateInitialAlleles = GenerateVariableFreqInitialAlleles
-		mdlNames = append(mdlNames, "GenerateVariableFreqInitialAlleles")
-		dna.Mdl.CalcAlleleFitness = dna.CalcUniformAlleleFitness
-		mdlNames = append(mdlNames, "CalcUniformAlleleFitness")
-	default:
-		log.Fatalf("Error: unrecognized value for initial_allele_fitness_model: %v", c.Population.Initial_allele_fitness_model)
-	}
 
-	config.Verbose(1, "Running with these pop models: %v", strings.Join(mdlNames, ", "))
+// TribeModels returns the models that the specified tribe should use
+func TribeModels(tribeNum uint32) *Models {
+	if m, ok := TribeMdls[tribeNum]; ok { return m }
+	return Mdl
 }
@@ -0,0 +1,186 @@
+package pop
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Pedigree tracking (track_pedigree=true) records the parents of every individual that survives selection, so the genealogy of the
+// population can be analyzed after the run (e.g. realized inbreeding coefficients and coalescence times). The individuals that are
+// eliminated by selection have no offspring, so leaving them out keeps the pedigree compact without losing any lineages.
+// Each line of mendel.ped is:  id dad_id mom_id generation tribe
+
+const PEDIGREE_HEADER = "# id dad_id mom_id generation tribe\n"
+
+// lastIndivId is the last id given to an individual in the pedigree. It is saved in the checkpoint.
+var lastIndivId uint64
+
+// pedigreeIds is the id of each individual recorded by the last RecordPedigree(), so its offspring can note it when it mates. This is
+// kept here instead of in each Individual so runs without track_pedigree do not pay for it. It is cleared after mating, so it does not
+// keep the parents from being freed.
+var pedigreeIds map[*Individual]uint64
+
+
+// IsPedigreeEnabled returns true if the parents of each individual should be recorded and written to mendel.ped
+func IsPedigreeEnabled() bool { return config.FMgr.IsFile(config.PEDIGREE_FILENAME) }
+
+
+// recordPedigreeParents notes the pedigree ids of the parents of offspr (which were recorded the previous generation), so RecordPedigree
+// can write them. For a clone or a self-fertilized offspring dad and mom are the same individual.
+func (p *PopulationPart) recordPedigreeParents(offspr, dad, mom *Individual) {
+	if p.pedigreeParents == nil { return }		// track_pedigree is not enabled
+	p.pedigreeParents[offspr] = [2]uint64{pedigreeIds[dad], pedigreeIds[mom]}
+}
+
+
+// RecordPedigree gives each individual of the species an id and writes it and its parents' ids to mendel.ped. This is called for the
+// genesis population and then after selection (and migration/fission) each generation, so the tribe is the one the individual will mate in.
+func (s *Species) RecordPedigree(genNum uint32) {
+	if !IsPedigreeEnabled() { return }
+	defer utils.Measure.Start("RecordPedigree").Stop("RecordPedigree")
+	pedFile := config.FMgr.GetFile(config.PEDIGREE_FILENAME, 0)
+	writer := bufio.NewWriter(pedFile)
+	if genNum == 0 {
+		if _, err := writer.WriteString(PEDIGREE_HEADER); err != nil { log.Fatalf("Error writing %v: %v", config.PEDIGREE_FILENAME, err) }
+	}
+
+	// Migration and fission can move an individual out of the tribe (and part) it was born in, so gather the parents from all of the parts
+	parents := make(map[*Individual][2]uint64)
+	for _, p := range s.Populations {
+		for _, part := range p.Parts {
+			for ind, ids := range part.pedigreeParents { parents[ind] = ids }
+			part.pedigreeParents = nil
+		}
+	}
+
+	ids := make(map[*Individual]uint64, len(parents))
+	for _, p := range s.Populations {
+		if p.Done { continue }		// the individuals of a tribe that is done do not mate, so they were already recorded
+		for _, indRef := range p.IndivRefs {
+			ind := indRef.Indiv
+			lastIndivId++
+			ids[ind] = lastIndivId
+			if _, err := fmt.Fprintf(writer, "%d %d %d %d %d\n", lastIndivId, parents[ind][0], parents[ind][1], genNum, p.TribeNum); err != nil { log.Fatalf("Error writing %v: %v", config.PEDIGREE_FILENAME, err) }
+		}
+	}
+	pedigreeIds = ids
+	if err := writer.Flush(); err != nil { log.Fatalf("Error writing %v: %v", config.PEDIGREE_FILENAME, err) }		// flush every gen to support restart
+}
+
+
+// PrunePedigree rewrites mendel.ped with only the individuals of the last generation recorded and their ancestors, when prune_pedigree=true.
+// The pedigree can be much bigger than memory, so it is streamed: 1 pass to find where each generation starts, 1 backward pass (a generation
+// at a time) to find the ids of the ancestors, and 1 forward pass to write the kept lines back into the same file.
+func PrunePedigree() {
+	if !IsPedigreeEnabled() || !config.Cfg.Computation.Prune_pedigree { return }
+	defer utils.Measure.Start("PrunePedigree").Stop("PrunePedigree")
+	// The output file is only open for writing, so read it separately
+	filePath := config.FMgr.DataFilePath + "/" + config.PEDIGREE_FILENAME
+	inFile, err := os.Open(filePath)
+	if err != nil { log.Fatalf("Error opening %v: %v", filePath, err) }
+	defer inFile.Close()
+	keep, err := pedigreeIdsToKeep(inFile)
+	if err != nil { log.Fatalf("Error reading %v: %v", filePath, err) }
+	if _, err := inFile.Seek(0, io.SeekStart); err != nil { log.Fatalf("Error seeking in %v: %v", filePath, err) }
+
+	pedFile := config.FMgr.GetFile(config.PEDIGREE_FILENAME, 0)
+	if _, err := pedFile.Seek(0, io.SeekStart); err != nil { log.Fatalf("Error seeking in %v: %v", filePath, err) }
+	writer := bufio.NewWriter(pedFile)
+	numKept, err := writeKeptPedigreeLines(inFile, writer, keep)
+	if err == nil { err = writer.Flush() }
+	if err != nil { log.Fatalf("Error pruning %v: %v", filePath, err) }
+	size, err := pedFile.Seek(0, io.SeekCurrent)
+	if err != nil { log.Fatalf("Error seeking in %v: %v", filePath, err) }
+	if err := pedFile.Truncate(size); err != nil { log.Fatalf("Error truncating %v: %v", filePath, err) }
+	config.Verbose(1, "Pruned %v to the %d individuals in the lineages of the last generation", config.PEDIGREE_FILENAME, numKept)
+}
+
+
+// pedigreeLine is the data of 1 line of mendel.ped
+type pedigreeLine struct {
+	id, dadId, momId uint64
+	gen uint64
+}
+
+
+// parsePedigreeLine parses 1 line (in the format RecordPedigree writes). isData is false for the header and blank lines.
+func parsePedigreeLine(text string) (l pedigreeLine, isData bool, err error) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.HasPrefix(text, "#") { return }
+	fields := strings.Fields(text)
+	if len(fields) != 5 { err = fmt.Errorf("invalid pedigree line: %v", text); return }
+	if l.id, err = strconv.ParseUint(fields[0], 10, 64); err != nil { return }
+	if l.dadId, err = strconv.ParseUint(fields[1], 10, 64); err != nil { return }
+	if l.momId, err = strconv.ParseUint(fields[2], 10, 64); err != nil { return }
+	if l.gen, err = strconv.ParseUint(fields[3], 10, 64); err != nil { return }
+	isData = true
+	return
+}
+
+
+// pedigreeIdsToKeep returns the ids of the individuals of the last generation in the pedigree read from r and of all of their ancestors.
+// Only the ids of the ancestors still being looked for and the ids found are held in memory, not the lines.
+func pedigreeIdsToKeep(r io.ReadSeeker) (keep map[uint64]bool, err error) {
+	// Find the offset where each generation starts. RecordPedigree writes the generations in order.
+	type genStart struct {
+		gen uint64
+		offset int64
+	}
+	var starts []genStart
+	var offset int64
+	reader := bufio.NewReader(r)
+	for {
+		text, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF { return nil, readErr }
+		l, isData, parseErr := parsePedigreeLine(text)
+		if parseErr != nil { return nil, parseErr }
+		if isData && (len(starts) == 0 || l.gen != starts[len(starts)-1].gen) { starts = append(starts, genStart{gen: l.gen, offset: offset}) }
+		offset += int64(len(text))
+		if readErr == io.EOF { break }
+	}
+
+	// Parents are always in an earlier generation than their children, so 1 pass backward thru the generations finds all of the ancestors
+	keep = make(map[uint64]bool)
+	needed := make(map[uint64]bool)
+	end := offset
+	for i := len(starts) - 1; i >= 0; i-- {
+		if _, err = r.Seek(starts[i].offset, io.SeekStart); err != nil { return }
+		scanner := bufio.NewScanner(io.LimitReader(r, end - starts[i].offset))
+		for scanner.Scan() {
+			l, isData, _ := parsePedigreeLine(scanner.Text())		// the 1st pass already checked the lines
+			if !isData || (i != len(starts) - 1 && !needed[l.id]) { continue }
+			keep[l.id] = true
+			delete(needed, l.id)
+			if l.dadId != 0 { needed[l.dadId] = true }
+			if l.momId != 0 { needed[l.momId] = true }
+		}
+		if err = scanner.Err(); err != nil { return }
+		end = starts[i].offset
+	}
+	return
+}
+
+
+// writeKeptPedigreeLines copies the header and the lines of the individuals in keep from r to w, in their original order, and returns the
+// number of individuals written. Each line written is no longer than the line read, so w can write to the same file r reads from.
+func writeKeptPedigreeLines(r io.Reader, w io.Writer, keep map[uint64]bool) (numKept int, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		l, isData, parseErr := parsePedigreeLine(text)
+		if parseErr != nil { return numKept, parseErr }
+		if isData && !keep[l.id] { continue }
+		if !isData && text == "" { continue }
+		if isData { numKept++ }
+		if _, err = io.WriteString(w, text + "\n"); err != nil { return }
+	}
+	err = scanner.Err()
+	return
+}
@@ -0,0 +1,72 @@
+package pop
+
+import (
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrunePedigreeLines(t *testing.T) {
+	// Genesis individuals 1-4, gen 1 individuals 5-7, gen 2 individuals 8-9. Only 1, 2, 3, 5, 6 are ancestors of the last gen.
+	ped := PEDIGREE_HEADER +
+		"1 0 0 0 1\n2 0 0 0 1\n3 0 0 0 1\n4 0 0 0 1\n" +
+		"5 1 2 1 1\n6 3 2 1 1\n7 4 4 1 1\n" +
+		"8 5 6 2 1\n9 6 5 2 1\n"
+	r := strings.NewReader(ped)
+	keep, err := pedigreeIdsToKeep(r)
+	if err != nil { t.Fatalf("Error pruning pedigree: %v", err) }
+	if _, err := r.Seek(0, 0); err != nil { t.Fatalf("Error seeking in pedigree: %v", err) }
+	var pruned strings.Builder
+	numKept, err := writeKeptPedigreeLines(r, &pruned, keep)
+	if err != nil { t.Fatalf("Error writing pruned pedigree: %v", err) }
+	expected := PEDIGREE_HEADER + "1 0 0 0 1\n2 0 0 0 1\n3 0 0 0 1\n5 1 2 1 1\n6 3 2 1 1\n8 5 6 2 1\n9 6 5 2 1\n"
+	if pruned.String() != expected || numKept != 7 { t.Errorf("Pruned pedigree (%d individuals) is:\n%vexpected:\n%v", numKept, pruned.String(), expected) }
+
+	if _, err := pedigreeIdsToKeep(strings.NewReader("1 0 0\n")); err == nil { t.Errorf("Expected an error for an invalid pedigree line") }
+}
+
+// RecordPedigree must write the ids of the parents each individual was mated from, and for a clone both parent ids are its 1 parent
+func TestRecordPedigree(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 10
+		c.Computation.Track_pedigree = true
+	})
+	config.FileMgrFactory(config.CmdArgs.DataPath, config.PEDIGREE_FILENAME)
+	s := SpeciesFactory()
+	s.Populations[0] = PopulationFactory(nil, 0, 1, s.PartsPerPop)
+	s.RecordPedigree(0)
+	parents := s.Populations[0].IndivRefs
+	parentIds := make(map[*Individual]uint64)
+	for i, indRef := range parents { parentIds[indRef.Indiv] = uint64(i + 1) }		// the genesis individuals get ids 1-10 in order
+
+	childrenS := SpeciesFactory()
+	childrenS.Populations[0] = PopulationFactory(s.Populations[0], 1, 1, childrenS.PartsPerPop)
+	part := childrenS.Populations[0].Parts[0]
+	uniformRandom := rand.New(rand.NewSource(1))
+	var expected []string
+	for i := 0; i < len(parents); i += 2 {
+		dad, mom := parents[i].Indiv, parents[i+1].Indiv
+		dad.OneOffspring(mom, part, uniformRandom)
+		expected = append(expected, fmt.Sprintf("%d %d", parentIds[dad], parentIds[mom]))
+		dad.OneClone(part)
+		expected = append(expected, fmt.Sprintf("%d %d", parentIds[dad], parentIds[dad]))
+	}
+	childrenS.Populations[0].makeAndFillIndivRefs()
+	childrenS.RecordPedigree(1)
+	config.FMgr.CloseAllFiles()
+
+	contents, err := os.ReadFile(filepath.Join(config.CmdArgs.DataPath, config.PEDIGREE_FILENAME))
+	if err != nil { t.Fatalf("Error reading %v: %v", config.PEDIGREE_FILENAME, err) }
+	lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+	if len(lines) != 1 + len(parents) + len(expected) { t.Fatalf("%v has %d lines, expected %d", config.PEDIGREE_FILENAME, len(lines), 1 + len(parents) + len(expected)) }
+	for i, exp := range expected {
+		fields := strings.Fields(lines[1 + len(parents) + i])
+		if got := fields[1] + " " + fields[2]; got != exp || fields[0] != fmt.Sprint(len(parents) + i + 1) || fields[3] != "1" {
+			t.Errorf("Pedigree line for offspring %d is %v, expected parents %v", i, lines[1 + len(parents) + i], exp)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package pop
+
+import (
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"log"
+	"math/rand"
+	"strings"
+)
+
+// The polygenic beneficials model (polygenic_beneficials=true) is the mendel-f90 "waiting time" experiment: each individual has a short
+// string of nucleotides (starting as polygenic_init) that new mutations can hit, and an individual whose string matches polygenic_target gets
+// a fitness benefit of polygenic_effect. We write the generation at which the target first appears in each tribe and when it becomes fixed to mendel.plg.
+// The string is kept in Individual.PolygenicSeq with 2 bits per nucleotide (its index in POLYGENIC_NUCLEOTIDES), so it fits in what would otherwise be padding.
+
+const POLYGENIC_NUCLEOTIDES = "ACGT"
+const MAX_POLYGENIC_LEN = 16		// the number of 2-bit nucleotides that fit in Individual.PolygenicSeq
+
+const POLYGENIC_HEADER = "# Generation  Tribe  Event\n"
+
+var polygenicLen int		// the number of nucleotides in polygenic_init and polygenic_target
+var polygenicInit, polygenicTarget uint32		// polygenic_init and polygenic_target encoded like Individual.PolygenicSeq
+
+
+// ValidatePolygenic checks the polygenic config values and encodes polygenic_init and polygenic_target
+func ValidatePolygenic(c *config.Config) {
+	init := c.Mutations.Polygenic_init
+	target := c.Mutations.Polygenic_target
+	if len(init) == 0 || len(init) != len(target) { log.Fatalln("polygenic_init and polygenic_target must be non-empty and the same length") }
+	if len(init) > MAX_POLYGENIC_LEN { log.Fatalf("polygenic_init and polygenic_target can not be longer than %d nucleotides", MAX_POLYGENIC_LEN) }
+	if strings.Trim(init, POLYGENIC_NUCLEOTIDES) != "" || strings.Trim(target, POLYGENIC_NUCLEOTIDES) != "" { log.Fatalf("polygenic_init and polygenic_target can only contain the nucleotides %s", POLYGENIC_NUCLEOTIDES) }
+	if float64(len(init)) > c.Mutations.Genome_size { log.Fatalln("polygenic_init can not be longer than genome_size") }
+	polygenicLen = len(init)
+	polygenicInit = encodePolygenic(init)
+	polygenicTarget = encodePolygenic(target)
+}
+
+
+// encodePolygenic returns the nucleotide string packed 2 bits per nucleotide, the 1st nucleotide in the lowest bits
+func encodePolygenic(nucleotides string) (seq uint32) {
+	for i := 0; i < len(nucleotides); i++ { seq |= uint32(strings.IndexByte(POLYGENIC_NUCLEOTIDES, nucleotides[i])) << uint(2*i) }
+	return
+}
+
+
+// decodePolygenic returns the nucleotide string of a sequence packed by encodePolygenic()
+func decodePolygenic(seq uint32) string {
+	nucleotides := make([]byte, polygenicLen)
+	for i := range nucleotides { nucleotides[i] = POLYGENIC_NUCLEOTIDES[(seq >> uint(2*i)) & 3] }
+	return string(nucleotides)
+}
+
+
+// inheritPolygenic gives the child the polygenic nucleotide string of 1 of its parents, chosen randomly
+func (child *Individual) inheritPolygenic(dad, mom *Individual, uniformRandom *rand.Rand) {
+	if dad == mom || uniformRandom.Intn(2) == 0 {
+		child.PolygenicSeq = dad.PolygenicSeq
+	} else {
+		child.PolygenicSeq = mom.PolygenicSeq
+	}
+}
+
+
+// mutatePolygenic determines if a new mutation hits the polygenic nucleotide string (which is polygenicLen sites of the genome_size sites).
+// If so it changes a random nucleotide of the string to a different nucleotide and returns true.
+func (child *Individual) mutatePolygenic(uniformRandom *rand.Rand) bool {
+	if uniformRandom.Float64() >= float64(polygenicLen) / config.Cfg.Mutations.Genome_size { return false }
+	shift := uint(2 * uniformRandom.Intn(polygenicLen))
+	oldNucleotide := (child.PolygenicSeq >> shift) & 3
+	newNucleotide := (oldNucleotide + 1 + uint32(uniformRandom.Intn(len(POLYGENIC_NUCLEOTIDES) - 1))) % uint32(len(POLYGENIC_NUCLEOTIDES))
+	child.PolygenicSeq = child.PolygenicSeq &^ (3 << shift) | newNucleotide << shift
+	return true
+}
+
+
+// PolygenicFitness returns the fitness benefit this individual gets from its polygenic nucleotide string matching the target
+func PolygenicFitness(ind *Individual) float64 {
+	if ind.PolygenicSeq == polygenicTarget { return config.Cfg.Mutations.Polygenic_effect }
+	return 0.0
+}
+
+
+// CheckPolygenicTarget counts the individuals that have the polygenic target and records/reports the generation at which the target first
+// appears in this pop, and the generation at which it becomes fixed. These are also written to mendel.plg.
+func (p *Population) CheckPolygenicTarget(genNum uint32, lastGen bool) {
+	var numWithTarget uint32
+	for _, indRef := range p.IndivRefs {
+		if indRef.Indiv.PolygenicSeq == polygenicTarget { numWithTarget++ }
+	}
+	if numWithTarget > 0 && p.PolygenicFirstGen == 0 {
+		p.PolygenicFirstGen = genNum
+		log.Printf("Tribe %d: the polygenic target %s first appeared in generation %d", p.TribeNum, config.Cfg.Mutations.Polygenic_target, genNum)
+		writePolygenicEvent(genNum, p.TribeNum, "first_appeared")
+	}
+	if numWithTarget > 0 && numWithTarget == p.GetCurrentSize() && p.PolygenicFixedGen == 0 {
+		p.PolygenicFixedGen = genNum
+		log.Printf("Tribe %d: the polygenic target %s became fixed in generation %d", p.TribeNum, config.Cfg.Mutations.Polygenic_target, genNum)
+		writePolygenicEvent(genNum, p.TribeNum, "fixed")
+	}
+	config.Verbose(2, "Tribe %d: %d of %d individuals have the polygenic target", p.TribeNum, numWithTarget, p.GetCurrentSize())
+	if lastGen && p.PolygenicFixedGen == 0 {
+		log.Printf("Tribe %d: the polygenic target %s was not fixed by the end of the run (%d of %d individuals have it)", p.TribeNum, config.Cfg.Mutations.Polygenic_target, numWithTarget, p.GetCurrentSize())
+		writePolygenicEvent(genNum, p.TribeNum, "not_fixed")
+	}
+}
+
+
+// writePolygenicEvent writes a line to mendel.plg, if it is being output
+func writePolygenicEvent(genNum, tribeNum uint32, event string) {
+	plgFile := config.FMgr.GetFile(config.POLYGENIC_FILENAME, 0)
+	if plgFile == nil { return }
+	if _, err := fmt.Fprintf(plgFile, "%d  %d  %s\n", genNum, tribeNum, event); err != nil { log.Fatalf("Error writing %v: %v", config.POLYGENIC_FILENAME, err) }
+}
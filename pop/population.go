@@ -10,6 +10,7 @@ import (
 	"github.com/genetic-algorithms/mendel-go/utils"
 	"github.com/genetic-algorithms/mendel-go/dna"
 	"sync"
+	"sync/atomic"
 	"encoding/json"
 	"runtime/debug"
 	"github.com/genetic-algorithms/mendel-go/random"
@@ -19,8 +20,8 @@ import (
 
 type RecombinationType uint8
 const (
-	//CLONAL RecombinationType = 1   <-- have not needed these yet, uncomment when we do
-	//SUPPRESSED RecombinationType = 2
+	CLONAL RecombinationType = 1		// each individual reproduces on its own by copying its chromosomes
+	SUPPRESSED RecombinationType = 2		// sexual reproduction, but each chromosome is inherited whole (no crossover)
 	FULL_SEXUAL RecombinationType = 3
 )
 
@@ -38,14 +39,18 @@ func (a ByFitness) Less(i, j int) bool { return a[i].Indiv.PhenoFitness < a[j].I
 // Population tracks the tribes and global info about the population. It also handles population-wide actions like mating and selection.
 type Population struct {
 	TribeNum uint32	// the tribe number
+	ParamsTribeNum uint32	// the tribe whose parameters this pop uses. This is TribeNum, except for tribes created by fission, which use their original tribe's.
+	Cfg *config.Config	// the config values for this tribe. This is config.Cfg unless the input file has a [tribes.N] table for this tribe.
+	Mdl *Models	// the models for this tribe. This is pop.Mdl unless the input file has a [tribes.N] table for this tribe.
 	Parts []*PopulationPart		// Subsets of the pop that are mated in parallel. This contains the backing array for IndexRefs.
 	IndivRefs []IndivRef	// References to individuals in the indivs array. This level of indirection allows us to sort this list, truncate it after selection, and refer to indivs in PopulationParts, all w/o copying Individual objects.
 
 	TargetSize uint32        // the target size of this population after selection
 	Done bool				 // true if went extinct or hit its pop max
+	Split bool				 // true if tribal fission split this tribe into 2 new tribes. It is removed from the species at the start of the next gen.
 	BottleNecks *Bottlenecks // the bottlenecks this pop should go thru
 	Num_offspring float64    // Average number of offspring each individual should have (so need to multiple by 2 to get it for the mating pair). Calculated from config values Fraction_random_death and Reproductive_rate.
-	LBsPerChromosome uint32  // How many linkage blocks in each chromosome. For now the total number of LBs must be an exact multiple of the number of chromosomes
+	LBsPerChromosome []uint32  // How many linkage blocks in each chromosome (from chromosome_lbs, or num_linkage_subunits split evenly)
 
 	// Stats
 	ActualAvgOffspring float64       // The average number of offspring each individual from last generation actually had in this generation
@@ -53,10 +58,14 @@ type Population struct {
 	PreSelGenoFitnessVariance float64                                   //
 	PreSelGenoFitnessStDev    float64                                   // The standard deviation from the GenoFitnessMean
 	EnvironNoise              float64                                   // randomness applied to geno fitness calculated from PreSelGenoFitnessVariance, heritability, and non_scaling_noise
+	NumImmigrants, NumEmigrants uint32                                  // the number of individuals that migrated into and out of this tribe this generation
+	NumBackMutations uint32                                             // the number of mutations reverted by back mutations in the offspring of this generation
+	PolygenicFirstGen, PolygenicFixedGen uint32                         // the generations at which the polygenic target 1st appeared in and became fixed in this pop (0 if not yet)
 
 	MeanFitness, MinFitness, MaxFitness float64                         // cache summary info about the individuals
 	TotalNumMutations uint64
 	MeanNumMutations float64
+	NumMales, NumFemales uint32		// only used when separate_sexes==true
 
 	MeanNumDeleterious, MeanNumNeutral, MeanNumFavorable  float64       // cache some of the stats we usually gather
 
@@ -67,35 +76,51 @@ type Population struct {
 // PopulationFactory creates a new population. If genNum==0 it creates the special genesis population.
 func PopulationFactory(prevPop *Population, genNum, tribeNum, partsPerPop uint32) *Population {
 	var targetSize uint32
+	paramsTribeNum := tribeNum
 	if prevPop != nil {
 		if prevPop.Done { return prevPop }
-		targetSize = Mdl.PopulationGrowth(prevPop, genNum)
+		targetSize = prevPop.Mdl.PopulationGrowth(prevPop, genNum)
+		paramsTribeNum = prevPop.ParamsTribeNum
 	} else {
 		// This is the 1st generation, so set the size from the config param
-		targetSize = config.Cfg.Basic.Pop_size
+		targetSize = config.TribeConfig(tribeNum).Basic.Pop_size
 	}
 	p := &Population{
 		TribeNum: tribeNum,
+		ParamsTribeNum: paramsTribeNum,
+		Cfg: config.TribeConfig(paramsTribeNum),
+		Mdl: TribeModels(paramsTribeNum),
 		Parts: make([]*PopulationPart, 0, partsPerPop), 	// allocate the array for the ptrs to the parts. The actual part objects will be appended below
 		TargetSize: targetSize,
 	}
-	if PopulationGrowthModelType(strings.ToLower(config.Cfg.Population.Pop_growth_model)) == MULTI_BOTTLENECK_POPULATON_GROWTH {
+	if PopulationGrowthModelType(strings.ToLower(p.Cfg.Population.Pop_growth_model)) == MULTI_BOTTLENECK_POPULATON_GROWTH {
 		if prevPop != nil {
 			p.BottleNecks = prevPop.BottleNecks // pass the bottleneck list down from the prev pop
 		} else {
-			p.BottleNecks = ParseMultipleBottlenecks(config.Cfg.Population.Multiple_Bottlenecks)
+			p.BottleNecks = ParseMultipleBottlenecks(p.Cfg.Population.Multiple_Bottlenecks)
 		}
 	}
+	if prevPop != nil {
+		p.PolygenicFirstGen = prevPop.PolygenicFirstGen
+		p.PolygenicFixedGen = prevPop.PolygenicFixedGen
+	}
 
 	fertility_factor := 1. - config.Cfg.Selection.Fraction_random_death
+	if config.Cfg.Selection.Fitness_dependent_fertility && prevPop != nil {
+		// As the fitness of the pop declines so does its reproductive capacity. The genesis pop does not have its fitness calculated, so it is considered fully fit.
+		if meanFitness, _, _, _, _ := prevPop.GetFitnessStats(); meanFitness > 0.0 {
+			fertility_factor *= math.Min(1.0, meanFitness)
+		}
+	}
 	p.Num_offspring = config.Cfg.Population.Reproductive_rate * fertility_factor 	// the default for Num_offspring is 2
 
-	p.LBsPerChromosome = uint32(config.Cfg.Population.Num_linkage_subunits / config.Cfg.Population.Haploid_chromosome_number)	// main.initialize() already confirmed it was a clean multiple
+	p.LBsPerChromosome = config.Computed.Chromosome_lbs
 
 	if genNum == 0 {
 		// Create individuals (with no mutations) for the genesis generation. (For subsequent generations, individuals are added to the Population object via Mate().
 		p.Parts = append(p.Parts, PopulationPartFactory(targetSize, p))    // for gen 0 we only need 1 part because that doesn't have offspring added to it during Mate()
 		p.makeAndFillIndivRefs()
+		for i, indRef := range p.IndivRefs { indRef.Indiv.FamilyId = uint64(tribeNum) << 32 | uint64(i+1) }		// each genesis individual founds its own family
 	} else {
 		for i:=1; i<= cap(p.Parts); i++ { p.Parts = append(p.Parts, PopulationPartFactory(0, p)) }
 		// Mate() will populate PopulationPart with Individuals and run makeAndFillIndivRefs()
@@ -109,7 +134,7 @@ func PopulationFactory(prevPop *Population, genNum, tribeNum, partsPerPop uint32
 func (p *Population) Reinitialize(prevPop *Population, genNum uint32) *Population {
 	if p.Done { return p }
 	// Reinitialize is never called on the genesis population
-	p.TargetSize = Mdl.PopulationGrowth(prevPop, genNum)
+	p.TargetSize = p.Mdl.PopulationGrowth(prevPop, genNum)
 
 	// Truncate the IndivRefs slice. makeAndFillIndivRefs() will make it again if not big enough.
 	p.IndivRefs = p.IndivRefs[:0]
@@ -145,6 +170,19 @@ func (p *Population) Reinitialize(prevPop *Population, genNum uint32) *Populatio
 }
 
 
+// invalidateStats clears the cached summary stats, so they will be recalculated after the set of individuals in this pop has changed
+func (p *Population) invalidateStats() {
+	p.MeanFitness = 0.0
+	p.MinFitness = 0.0
+	p.MaxFitness = 0.0
+	p.TotalNumMutations = 0
+	p.MeanNumMutations = 0.0
+	p.MeanNumDeleterious = 0.0
+	p.MeanNumNeutral = 0.0
+	p.MeanNumFavorable  = 0.0
+}
+
+
 // Size returns the current number of individuals in this population
 func (p *Population) GetCurrentSize() uint32 {
 	return uint32(len(p.IndivRefs))
@@ -233,8 +271,7 @@ func GenerateVariableFreqInitialAlleles(p *Population, uniformRandom *rand.Rand)
 
 			// Randomly choose a chromosome and LB position for this allele pair to go on
 			lbIndex := uniformRandom.Intn(int(config.Cfg.Population.Num_linkage_subunits - 1))   // 0 to numLBs-1
-			chromoIndex := lbIndex / int(p.LBsPerChromosome) 	// 0 to numChr-1
-			lbIndexOnChr := lbIndex % int(p.LBsPerChromosome) 	// 0 to LBsPerChromosome-1
+			chromoIndex, lbIndexOnChr := dna.LocateLb(lbIndex) 	// 0 to numChr-1, and 0 to LBsPerChromosome[chromoIndex]-1
 
 			// To avoid always adding alleles to the same indivs, create a shuffled slice of indices into the population
 			var indivIndices []int
@@ -269,6 +306,10 @@ func (p *Population) Mate(newP *Population, uniformRandom *rand.Rand) {
 
 	// To prepare for mating, create a shuffled slice of indices into the parent population
 	parentIndices := uniformRandom.Perm(int(p.GetCurrentSize()))
+	if config.Cfg.Population.Separate_sexes { parentIndices = p.pairSexes(parentIndices) }
+
+	// Some of the num offspring models use the mean fitness of the pop. GetFitnessStats() caches it, so calculate it now before the go routines read it.
+	p.GetFitnessStats()
 
 	// Divide parentIndices into segments (whose size is an even number) and schedule a go routine to mate each segment
 	// Note: runtime.GOMAXPROCS(runtime.NumCPU()) is the default, but this statement can be modified to set a different number of CPUs to use
@@ -301,7 +342,7 @@ func (p *Population) Mate(newP *Population, uniformRandom *rand.Rand) {
 			}
 
 			// Choose a range of the mutation id's for this part - have to make sure it won't exceed this
-			numMuts := uint64(float64(endIndex - beginIndex + 1) * p.Num_offspring * config.Cfg.Mutations.Mutn_rate * 1.5)
+			numMuts := uint64(float64(endIndex - beginIndex + 1) * p.Num_offspring * (p.Cfg.Mutations.Mutn_rate + config.Cfg.Mutations.Mito_mutn_rate) * 1.5)
 			if numMuts <= 100 { numMuts = numMuts * 2}		// with small number the randomness of Poisson distribution can vary more
 			//log.Printf("DEBUG: donating %d mutation ids for %d individuals", numMuts, endIndex - beginIndex + 1)
 
@@ -319,6 +360,7 @@ func (p *Population) Mate(newP *Population, uniformRandom *rand.Rand) {
 	waitGroup.Wait()
 
 	newP.makeAndFillIndivRefs()	// now that we are done creating new individuals, fill in the array of references to them
+	for _, part := range newP.Parts { newP.NumBackMutations += part.NumBackMutations }
 
 	// Save off the average num offspring for stats, before we select out individuals
 	newP.ActualAvgOffspring = float64(newP.GetCurrentSize()) / float64(p.GetCurrentSize())
@@ -333,9 +375,9 @@ func (p *Population) Select(uniformRandom *rand.Rand) {
 	config.Verbose(4, "Select: eliminating %d individuals to try to maintain a population of %d...\n", p.GetCurrentSize()-p.TargetSize, p.TargetSize)
 
 	// Calculate noise factor to get pheno fitness of each individual
-	herit := config.Cfg.Selection.Heritability
-	p.EnvironNoise = math.Sqrt(p.PreSelGenoFitnessVariance * (1.0-herit) / herit + math.Pow(config.Cfg.Selection.Non_scaling_noise,2))
-	Mdl.ApplySelectionNoise(p, p.EnvironNoise, uniformRandom) 		// this sets PhenoFitness in each of the individuals
+	herit := p.Cfg.Selection.Heritability
+	p.EnvironNoise = math.Sqrt(p.PreSelGenoFitnessVariance * (1.0-herit) / herit + math.Pow(p.Cfg.Selection.Non_scaling_noise,2))
+	p.Mdl.ApplySelectionNoise(p, p.EnvironNoise, uniformRandom) 		// this sets PhenoFitness in each of the individuals
 
 	// Sort the indexes of the Indivs array by fitness, and mark the least fit individuals as dead
 	p.sortIndexByPhenoFitness()		// this sorts p.IndivRefs
@@ -371,15 +413,19 @@ func (p *Population) Select(uniformRandom *rand.Rand) {
 
 // Returns true if this pop has gone extinct or reached its pop max
 func (p *Population) IsDone(doLog bool) bool {
-	popMaxIsSet := PopulationGrowthModelType(strings.ToLower(config.Cfg.Population.Pop_growth_model))==EXPONENTIAL_POPULATON_GROWTH && config.Cfg.Population.Max_pop_size>0
-	popMax := config.Cfg.Population.Max_pop_size
+	popMaxIsSet := PopulationGrowthModelType(strings.ToLower(p.Cfg.Population.Pop_growth_model))==EXPONENTIAL_POPULATON_GROWTH && p.Cfg.Population.Max_pop_size>0
+	popMax := p.Cfg.Population.Max_pop_size
 	if popMaxIsSet && p.GetCurrentSize() >= popMax {
 		if doLog { log.Printf("Tribe %d has reached the max specified value of %d. Stopping this tribe.", p.TribeNum, popMax) }
 		return true
-	} else if (RecombinationType(config.Cfg.Population.Recombination_model) == FULL_SEXUAL && p.GetCurrentSize() < 2) || p.GetCurrentSize() == 0 {
+	} else if (RecombinationType(config.Cfg.Population.Recombination_model) != CLONAL && p.GetCurrentSize() < 2) || p.GetCurrentSize() == 0 {
 		// Above checks if we don't have enough individuals to mate
 		if doLog { log.Printf("Tribe %d is extinct. Stopping this tribe.", p.TribeNum) }
 		return true
+	} else if config.Cfg.Population.Separate_sexes && !p.hasBothSexes() {
+		// Above checks if we don't have a male and a female to mate
+		if doLog { log.Printf("Tribe %d has only 1 sex left, so it is extinct. Stopping this tribe.", p.TribeNum) }
+		return true
 	} else if aveFit, _, _, _, _ := p.GetFitnessStats(); aveFit < config.Cfg.Computation.Extinction_threshold {
 		// Above checks if the the tribe's fitness is below the threshold
 		if doLog { log.Printf("Tribe %d fitness is below the extinction threshold of %.3f. Stopping this tribe.", p.TribeNum, config.Cfg.Computation.Extinction_threshold) }
@@ -532,30 +578,30 @@ func NoPopulationGrowth(prevPop *Population, _ uint32) uint32 {
 
 // ExponentialPopulationGrowth returns the previous pop size times the growth rate
 func ExponentialPopulationGrowth(prevPop *Population, _ uint32) uint32 {
-	return uint32(math.Ceil(config.Cfg.Population.Pop_growth_rate * float64(prevPop.TargetSize)))
+	return uint32(math.Ceil(prevPop.Cfg.Population.Pop_growth_rate * float64(prevPop.TargetSize)))
 }
 
 // CapacityPopulationGrowth uses an equation in which the pop size approaches the carrying capacity
 func CapacityPopulationGrowth(prevPop *Population, _ uint32) uint32 {
 	// mendel-f90 calculates the new pop target size as ceiling(pop_size * (1. + pop_growth_rate * (1. - pop_size/carrying_capacity) ) )
-	newTargetSize := uint32(math.Ceil( float64(prevPop.TargetSize) * (1.0 + config.Cfg.Population.Pop_growth_rate * (1.0 - float64(prevPop.TargetSize)/float64(config.Cfg.Population.Carrying_capacity)) ) ))
+	newTargetSize := uint32(math.Ceil( float64(prevPop.TargetSize) * (1.0 + prevPop.Cfg.Population.Pop_growth_rate * (1.0 - float64(prevPop.TargetSize)/float64(prevPop.Cfg.Population.Carrying_capacity)) ) ))
 	return newTargetSize
 }
 
 // FoundersPopulationGrowth increases the pop size exponentially until it reaches the carrying capacity, and supports bottlenecks
 func FoundersPopulationGrowth(prevPop *Population, genNum uint32) uint32 {
 	var newTargetSize uint32
-	if config.Cfg.Population.Bottleneck_generation == 0 || genNum < config.Cfg.Population.Bottleneck_generation {
+	if prevPop.Cfg.Population.Bottleneck_generation == 0 || genNum < prevPop.Cfg.Population.Bottleneck_generation {
 		// We are before the bottleneck so use 1st growth rate
-		newTargetSize = uint32(math.Ceil(config.Cfg.Population.Pop_growth_rate * float64(prevPop.TargetSize)))
-	} else if genNum >= config.Cfg.Population.Bottleneck_generation && genNum < config.Cfg.Population.Bottleneck_generation + config.Cfg.Population.Num_bottleneck_generations {
+		newTargetSize = uint32(math.Ceil(prevPop.Cfg.Population.Pop_growth_rate * float64(prevPop.TargetSize)))
+	} else if genNum >= prevPop.Cfg.Population.Bottleneck_generation && genNum < prevPop.Cfg.Population.Bottleneck_generation + prevPop.Cfg.Population.Num_bottleneck_generations {
 		// We are in the bottleneck range
-		newTargetSize = config.Cfg.Population.Bottleneck_pop_size
+		newTargetSize = prevPop.Cfg.Population.Bottleneck_pop_size
 	} else {
 		// We are after the bottleneck so use 2nd growth rate
-		newTargetSize = uint32(math.Ceil(config.Cfg.Population.Pop_growth_rate2 * float64(prevPop.TargetSize)))
+		newTargetSize = uint32(math.Ceil(prevPop.Cfg.Population.Pop_growth_rate2 * float64(prevPop.TargetSize)))
 	}
-	newTargetSize = utils.MinUint32(newTargetSize, config.Cfg.Population.Carrying_capacity) 	// do not want it exceeding the carrying capacity
+	newTargetSize = utils.MinUint32(newTargetSize, prevPop.Cfg.Population.Carrying_capacity) 	// do not want it exceeding the carrying capacity
 	return newTargetSize
 }
 
@@ -752,12 +798,18 @@ func (p *Population) GetFitnessStats() (float64, float64, float64, uint64, float
 	p.MeanFitness = 0.0
 	p.TotalNumMutations = 0
 	p.MeanNumMutations = 0.0
+	p.NumMales, p.NumFemales = 0, 0
 	for _, indRef := range p.IndivRefs {
 		ind := indRef.Indiv
 		p.MeanFitness += ind.GenoFitness
 		if ind.GenoFitness > p.MaxFitness { p.MaxFitness = ind.GenoFitness }
 		if ind.GenoFitness < p.MinFitness { p.MinFitness = ind.GenoFitness }
 		p.TotalNumMutations += uint64(ind.NumMutations)
+		if ind.Male {
+			p.NumMales++
+		} else {
+			p.NumFemales++
+		}
 	}
 	p.MeanFitness = p.MeanFitness / float64(popSize)
 	p.MeanNumMutations = float64(p.TotalNumMutations) / float64(popSize)
@@ -816,19 +868,31 @@ func (p *Population) GetInitialAlleleStats() (float64, /*float64,*/ float64) {
 func (p *Population) ReportInitial() {
 	// Report initial alleles if there are any
 	initialVerboseLevel := uint32(1)            // level at which we will print population summary info at the end of the run
-	if config.Cfg.Population.Num_contrasting_alleles > 0 && config.IsVerbose(initialVerboseLevel) {
+	if (config.Cfg.Population.Num_contrasting_alleles > 0 || InitialAlleleModelType(strings.ToLower(config.Cfg.Population.Initial_allele_fitness_model)) == UPLOAD_INITIAL_ALLELES) && config.IsVerbose(initialVerboseLevel) {
 		ad, af := p.GetInitialAlleleStats()
 		log.Printf(" Indiv initial allele detail means: deleterious: %v, favorable: %v", ad, af)
 	}
 
 	if histWriter := config.FMgr.GetFile(config.HISTORY_FILENAME, p.TribeNum); histWriter != nil {
 		// Write header for this file
-		fmt.Fprintln(histWriter, "# Generation  Avg-deleterious Avg-neutral  Avg-favorable")
+		if config.Cfg.Mutations.Allow_back_mutn {
+			fmt.Fprintln(histWriter, "# Generation  Avg-deleterious Avg-neutral  Avg-favorable  Back-mutations")
+		} else {
+			fmt.Fprintln(histWriter, "# Generation  Avg-deleterious Avg-neutral  Avg-favorable")
+		}
 	}
 
 	if fitWriter := config.FMgr.GetFile(config.FITNESS_FILENAME, p.TribeNum); fitWriter != nil {
 		// Write header for this file
-		fmt.Fprintln(fitWriter, "# Generation  Pop-size  Avg Offspring  Avg-fitness  Min-fitness  Max-fitness  Total Mutns  Mean Mutns  Noise")
+		if IsMigrationEnabled() {
+			fmt.Fprintln(fitWriter, "# Generation  Pop-size  Avg Offspring  Avg-fitness  Min-fitness  Max-fitness  Total Mutns  Mean Mutns  Noise  Immigrants  Emigrants")
+		} else {
+			fmt.Fprintln(fitWriter, "# Generation  Pop-size  Avg Offspring  Avg-fitness  Min-fitness  Max-fitness  Total Mutns  Mean Mutns  Noise")
+		}
+	}
+
+	if mitoWriter := config.FMgr.GetFile(config.MITO_FILENAME, p.TribeNum); mitoWriter != nil {
+		if _, err := mitoWriter.WriteString(MITO_HEADER); err != nil { log.Fatalf("Error writing %v: %v", config.MITO_FILENAME, err) }
 	}
 }
 
@@ -852,10 +916,13 @@ func (p *Population) ReportEachGen(genNum uint32, lastGen bool, totalInterimTime
 			d, n, f := p.GetMutationStats()
 			log.Printf(" Indiv mutation detail means: deleterious: %v, neutral: %v, favorable: %v, preselect fitness: %v, preselect fitness SD: %v", d, n, f, p.PreSelGenoFitnessMean, p.PreSelGenoFitnessStDev)
 		}
+		if config.Cfg.Population.Separate_sexes { p.ReportSexChromosomes() }
+		if hasMito() { p.ReportMito() }
 	} else if config.IsVerbose(perGenMinimalVerboseLevel) {
 		aveFit, minFit, maxFit, totalMutns, meanMutns := p.GetFitnessStats()		// this is much faster than p.GetMutationStats()
 		log.Printf("Tribe: %d, Gen: %d, Time: %.4f, Gen time: %.4f, Mem: %.3f MB, Pop size: %v, Indiv mean fitness: %v, min fitness: %v, max fitness: %v, total num mutations: %v, mean num mutations: %v, Mean num offspring %v", p.TribeNum, genNum, totalInterimTime, genTime, memUsed, popSize, aveFit, minFit, maxFit, totalMutns, meanMutns, p.ActualAvgOffspring)
 	}
+	if config.Cfg.Mutations.Polygenic_beneficials { p.CheckPolygenicTarget(genNum, lastGen) }
 	if config.IsVerbose(perGenIndDetailVerboseLevel) || (lastGen && config.IsVerbose(finalIndDetailVerboseLevel)) {
 		log.Println(" Individual Detail:")
 		for _, indRef := range p.IndivRefs {
@@ -868,7 +935,11 @@ func (p *Population) ReportEachGen(genNum uint32, lastGen bool, totalInterimTime
 		config.Verbose(5, "Writing to file %v", config.HISTORY_FILENAME)
 		d, n, f := p.GetMutationStats()		// GetMutationStats() caches its values so it's ok to call it multiple times
 		// If you change this line, you must also change the header in ReportInitial()
-		fmt.Fprintf(histWriter, "%d  %v  %v  %v\n", genNum, d, n, f)
+		if config.Cfg.Mutations.Allow_back_mutn {
+			fmt.Fprintf(histWriter, "%d  %v  %v  %v  %d\n", genNum, d, n, f, p.NumBackMutations)
+		} else {
+			fmt.Fprintf(histWriter, "%d  %v  %v  %v\n", genNum, d, n, f)
+		}
 		//histWriter.Flush()  // <-- don't need this because we don't use a buffer for the file
 		if lastGen {
 			//todo: put summary stats in comments at the end of the file?
@@ -879,25 +950,52 @@ func (p *Population) ReportEachGen(genNum uint32, lastGen bool, totalInterimTime
 		config.Verbose(5, "Writing to file %v", config.FITNESS_FILENAME)
 		aveFit, minFit, maxFit, totalMutns, meanMutns := p.GetFitnessStats()		// GetFitnessStats() caches its values so it's ok to call it multiple times
 		// If you change this line, you must also change the header in ReportInitial()
-		fmt.Fprintf(fitWriter, "%d  %d  %v  %v  %v  %v  %v  %v  %v\n", genNum, popSize, p.ActualAvgOffspring, aveFit, minFit, maxFit, totalMutns, meanMutns, p.EnvironNoise)
+		if IsMigrationEnabled() {
+			fmt.Fprintf(fitWriter, "%d  %d  %v  %v  %v  %v  %v  %v  %v  %d  %d\n", genNum, popSize, p.ActualAvgOffspring, aveFit, minFit, maxFit, totalMutns, meanMutns, p.EnvironNoise, p.NumImmigrants, p.NumEmigrants)
+		} else {
+			fmt.Fprintf(fitWriter, "%d  %d  %v  %v  %v  %v  %v  %v  %v\n", genNum, popSize, p.ActualAvgOffspring, aveFit, minFit, maxFit, totalMutns, meanMutns, p.EnvironNoise)
+		}
 		//histWriter.Flush()  // <-- don't need this because we don't use a buffer for the file
 		if lastGen {
 			//todo: put summary stats in comments at the end of the file?
 		}
 	}
 
+	if config.FMgr.GetFile(config.MITO_FILENAME, p.TribeNum) != nil && popSize > 0 {
+		deleterious, neutral, fitness := p.GetMitoStats()
+		writeMito(genNum, p.TribeNum, deleterious, neutral, fitness)
+	}
+
 	// Note: Species.ReportEachGen() runs Population.CountAlleles()
 }
 
-func (p *Population) CountAlleles(genNum uint32, lastGen bool) {
+// CountAlleles counts the alleles of this tribe and writes the allele outputs, if this is a gen they should be written in. It returns the counts
+// (so Species.ReportEachGen() can combine them for the whole species), or nil if the alleles were not counted.
+func (p *Population) CountAlleles(genNum uint32, lastGen bool) *dna.AlleleCount {
 	//if p.Done { return }  // even if a tribe went extinct, we might still be interested in its allele plots, as long as its pop > 0
-	if (config.FMgr.IsDir(config.ALLELE_BINS_DIRECTORY) || config.FMgr.IsDir(config.NORMALIZED_ALLELE_BINS_DIRECTORY) || config.FMgr.IsDir(config.DISTRIBUTION_DEL_DIRECTORY) || config.FMgr.IsDir(config.DISTRIBUTION_FAV_DIRECTORY)) && (lastGen || (config.Cfg.Computation.Plot_allele_gens > 0 && (genNum % config.Cfg.Computation.Plot_allele_gens) == 0)) {
+	if IsAlleleCountGen(genNum, lastGen) {
 		popSize := p.GetCurrentSize()
-		if popSize == 0 { return }
+		if popSize == 0 { return nil }
+		males, females := p.GetSexCounts()		// get these before getAlleles(), which frees the individuals in the last gen
 		alleles := p.getAlleles(genNum, popSize, lastGen)
 		p.outputAlleleBins(genNum, popSize, lastGen, alleles)
 		p.outputAlleleDistribution(genNum, popSize, lastGen, alleles)
+		outputSfs(genNum, popSize, males, females, p.TribeNum, alleles)
+		return alleles
 	}
+	return nil
+}
+
+
+// IsAlleleCountGen returns true if any of the outputs that need the alleles counted were requested, and this is a gen they should be written in
+func IsAlleleCountGen(genNum uint32, lastGen bool) bool {
+	return (config.FMgr.IsDir(config.ALLELE_BINS_DIRECTORY) || config.FMgr.IsDir(config.NORMALIZED_ALLELE_BINS_DIRECTORY) || config.FMgr.IsDir(config.DISTRIBUTION_DEL_DIRECTORY) || config.FMgr.IsDir(config.DISTRIBUTION_FAV_DIRECTORY) || config.FMgr.IsDir(config.ALLELE_SFS_DIRECTORY)) && IsPlotAlleleGen(genNum, lastGen)
+}
+
+
+// IsPlotAlleleGen returns true if this is a gen the allele outputs should be written in (every plot_allele_gens gens and the last gen)
+func IsPlotAlleleGen(genNum uint32, lastGen bool) bool {
+	return lastGen || (config.Cfg.Computation.Plot_allele_gens > 0 && (genNum % config.Cfg.Computation.Plot_allele_gens) == 0)
 }
 
 type Buckets struct {
@@ -937,29 +1035,78 @@ func (p *Population) getAlleles(genNum, popSize uint32, lastGen bool) (alleles *
 		debug.SetGCPercent(-1) 		// if force_gc=false we didn't do this earlier
 	}
 
-	// Count the alleles from all individuals. We end up with maps of mutation ids and the number of times each occurred
-	alleles = dna.AlleleCountFactory() 		// as we count, the totals are gathered in this struct
 	gcInterval := config.Cfg.Computation.Allele_count_gc_interval
 	if gcInterval > 0 && gcInterval < 100 {
 		// Interpret this as a %, with a min and max bound
 		gcInterval = uint32(float32(p.GetCurrentSize() * gcInterval) / 100.0)
 		gcInterval = utils.MaxUint32( utils.MinUint32(gcInterval, 500), 100 )
 	}
-	for i := range p.IndivRefs {
-		p.IndivRefs[i].Indiv.CountAlleles(alleles)
+	return p.countAlleles(lastGen, gcInterval)
+}
+
+
+// countAlleles counts the alleles of all of the individuals. The individuals are divided into 1 segment per part, and each segment is counted
+// concurrently into its own AlleleCount. Then those are merged, which gives the same counts as counting all of the individuals sequentially.
+// If lastGen is true, the individuals are freed as they are counted, and if gcInterval > 0 GC is run after every gcInterval individuals.
+func (p *Population) countAlleles(lastGen bool, gcInterval uint32) *dna.AlleleCount {
+	numSegments := utils.MaxInt(len(p.Parts), 1)
+	segmentSize := utils.MaxInt(utils.RoundUpInt(float64(len(p.IndivRefs)) / float64(numSegments)), 1)
+	partAlleles := make([]*dna.AlleleCount, 0, numSegments)
+	var numCounted uint32		// the number of individuals counted by all of the go routines, so the gc interval applies to the whole tribe
+	var waitGroup sync.WaitGroup
+	for begin := 0; begin < len(p.IndivRefs); begin += segmentSize {
+		end := utils.MinInt(begin + segmentSize, len(p.IndivRefs))
+		segmentAlleles := dna.AlleleCountFactory()
+		partAlleles = append(partAlleles, segmentAlleles)
+		waitGroup.Add(1)
+		go p.countSegmentAlleles(p.IndivRefs[begin:end], segmentAlleles, lastGen, gcInterval, &numCounted, &waitGroup)
+	}
+	waitGroup.Wait()
+	return mergeAlleleCounts(partAlleles)
+}
+
+
+// countSegmentAlleles counts the alleles of the individuals in indivRefs into alleles. This function is called in a go routine, and indivRefs
+// and alleles are only accessed by this go routine.
+func (p *Population) countSegmentAlleles(indivRefs []IndivRef, alleles *dna.AlleleCount, lastGen bool, gcInterval uint32, numCounted *uint32, waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+	for i := range indivRefs {
+		indivRefs[i].Indiv.CountAlleles(alleles)
+		n := atomic.AddUint32(numCounted, 1)
 
 		// Counting the alleles takes a lot of memory when there are a lot of mutations. We are concerned that after doing the whole
 		// run, we could blow the memory limit counting the alleles and lose all of the run results. So if this is the last gen
 		// we don't need the individuals after we have counted them, so nil the reference to them so GC can reclaim.
-		//utils.Measure.CheckAmountMemoryUsed()
 		if lastGen {
-			p.IndivRefs[i].Indiv = nil
-			if gcInterval > 0 && (i % int(gcInterval)) == 0 { utils.CollectGarbage() }
+			indivRefs[i].Indiv = nil
+			if gcInterval > 0 && (n % gcInterval) == 0 { utils.CollectGarbage() }
 		}
 
-		if i != 0 && gcInterval > 0 && (i % int(gcInterval)) == 0 { config.Verbose(1, "Counted alleles in %d individuals", i) }
+		if gcInterval > 0 && (n % gcInterval) == 0 { config.Verbose(1, "Counted alleles in %d individuals", n) }
 	}
-	return
+	alleles.Compact()
+}
+
+
+// mergeAlleleCounts merges the counts of the parts in pairs, concurrently, until there is 1 left, and returns it
+func mergeAlleleCounts(partAlleles []*dna.AlleleCount) *dna.AlleleCount {
+	if len(partAlleles) == 0 { return dna.AlleleCountFactory() }
+	for len(partAlleles) > 1 {
+		var waitGroup sync.WaitGroup
+		for i := 0; i+1 < len(partAlleles); i += 2 {
+			waitGroup.Add(1)
+			go func(a, b *dna.AlleleCount) {
+				defer waitGroup.Done()
+				a.Merge(b)
+			}(partAlleles[i], partAlleles[i+1])
+		}
+		waitGroup.Wait()
+		// The merged counts are in the even elements
+		merged := partAlleles[:0]
+		for i := 0; i < len(partAlleles); i += 2 { merged = append(merged, partAlleles[i]) }
+		partAlleles = merged
+	}
+	return partAlleles[0]
 }
 
 
@@ -1123,7 +1270,7 @@ func (p *Population) outputNormalizedAlleleBins(bucketJson *Buckets, bucketCount
 
 
 // fillBuckets takes the number of occurrences of each mutation id, determines which bucket it belongs in, and adds 1 to that bucket
-func fillBuckets(counts map[uint64]dna.Allele, popSize uint32, bucketCount uint32, buckets []uint32) (totalMutns uint64, totalFitness float64) {
+func fillBuckets(counts []dna.Allele, popSize uint32, bucketCount uint32, buckets []uint32) (totalMutns uint64, totalFitness float64) {
 	poolSize := float64(2 * popSize)
 	if !config.Cfg.Computation.Count_duplicate_alleles { poolSize = float64(popSize)}	// in this case, each allele count is a measure of how many individuals it occurred in
 
@@ -1229,7 +1376,7 @@ func (p *Population) outputAlleleDistribution(genNum, popSize uint32, lastGen bo
 	//abs := math.Abs
 	//current_pop_size := int(p.GetCurrentSize())
 	//mutn_sum := float64(p.TotalNumMutations)   // a comment in diagnostices.f90 says this should be the expected number of mutns w/o selection
-	mutn_sum := float64(p.GetCurrentSize() * genNum) * config.Cfg.Mutations.Mutn_rate
+	mutn_sum := float64(p.GetCurrentSize() * genNum) * p.Cfg.Mutations.Mutn_rate
 	frac_fav_mutn := config.Cfg.Mutations.Frac_fav_mutn
 	tracking_threshold := utils.MaxFloat64(1.0/config.Cfg.Mutations.Genome_size, float64(config.Cfg.Computation.Tracking_threshold))
 	max_fav_fitness_gain := config.Cfg.Mutations.Max_fav_fitness_gain
@@ -1301,7 +1448,7 @@ func (p *Population) outputAlleleDistribution(genNum, popSize uint32, lastGen bo
 	}
 
 	// Normalize the binned mutations by the reciprocal of the expected number of mutations per bin in the absence of selection
-	x := 1. - config.Cfg.Mutations.Fraction_neutral
+	x := 1. - config.Computed.Fraction_neutral
 	if x == 0 { x = 1. }	// don't scale data if fraction_neutral = 1
 	fraction_recessive := config.Cfg.Mutations.Fraction_recessive
 	for k := 1; k <= 50; k++ {
@@ -1428,7 +1575,7 @@ func (p *Population) outputAlleleDistribution(genNum, popSize uint32, lastGen bo
 
 }
 
-func fillInFitnessBins(alleles map[uint64]dna.Allele, max_fav_fitness_gain, bin_width float64, fitness_bins []float64) {
+func fillInFitnessBins(alleles []dna.Allele, max_fav_fitness_gain, bin_width float64, fitness_bins []float64) {
 	abs := math.Abs
 	logn := math.Log
 	//debugI := 1
@@ -0,0 +1,133 @@
+package pop
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+)
+
+// allelesTestPopulation returns a tribe with several parts after 2 generations of mating and selection
+func allelesTestPopulation(t *testing.T) *Population {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 40
+		c.Mutations.Mutn_rate = 20.0
+		c.Computation.Num_threads = 4
+		c.Computation.Tracking_threshold = 0.0
+		c.Computation.Files_to_output = config.ALLELE_BINS_DIRECTORY
+	})
+	config.FileMgrFactory(config.CmdArgs.DataPath, config.ALLELE_BINS_DIRECTORY)
+
+	uniformRandom := rand.New(rand.NewSource(1))
+	s := SpeciesFactory().Initialize(2, uniformRandom)
+	for gen := uint32(1); gen <= 2; gen++ {
+		childrenS := s.GetNextGeneration(gen)
+		s.Mate(childrenS, uniformRandom)
+		childrenS.Select(uniformRandom)
+		s = childrenS
+	}
+	if len(s.Populations[0].Parts) < 2 { t.Fatalf("Expected the population to have several parts, but it has %d", len(s.Populations[0].Parts)) }
+	return s.Populations[0]
+}
+
+
+// Counting the alleles concurrently in several parts and merging the sorted counts must give the same counts as a simple map of every mutation
+func TestGetAlleles(t *testing.T) {
+	p := allelesTestPopulation(t)
+	for _, countDuplicates := range []bool{true, false} {
+		config.Cfg.Computation.Count_duplicate_alleles = countDuplicates
+		expected := make(map[uint64]uint32)
+		for _, indRef := range p.IndivRefs {
+			indivIds := make(map[uint64]uint32)
+			for _, chromosomes := range [][]dna.Chromosome{indRef.Indiv.ChromosomesFromDad, indRef.Indiv.ChromosomesFromMom} {
+				for c := range chromosomes {
+					for lb := range chromosomes[c].LinkageBlocks {
+						for _, m := range chromosomes[c].LinkageBlocks[lb].GetMutations() { indivIds[m.Id]++ }
+					}
+				}
+			}
+			for id, count := range indivIds {
+				if !countDuplicates { count = 1 }
+				expected[id] += count
+			}
+		}
+		if len(expected) == 0 { t.Fatalf("The population has no tracked mutations to count") }
+
+		alleles := p.getAlleles(2, p.GetCurrentSize(), false)
+		var numAlleles int
+		for _, counts := range alleleSlices(alleles) {
+			for i, al := range counts {
+				if i > 0 && al.Id <= counts[i-1].Id { t.Errorf("Alleles are not in increasing id order at %d: %d, %d", i, counts[i-1].Id, al.Id) }
+				if al.Count != expected[al.Id] { t.Errorf("Allele %d has count %d, expected %d (count_duplicate_alleles=%v)", al.Id, al.Count, expected[al.Id], countDuplicates) }
+			}
+			numAlleles += len(counts)
+		}
+		if numAlleles != len(expected) { t.Errorf("Counted %d alleles, expected %d (count_duplicate_alleles=%v)", numAlleles, len(expected), countDuplicates) }
+	}
+}
+
+
+// Counting the parts concurrently and merging them must give exactly the same counts as counting all of the individuals sequentially, also
+// when the individuals are freed as they are counted in the last gen
+func TestCountAllelesMatchesSequential(t *testing.T) {
+	p := allelesTestPopulation(t)
+	for _, countDuplicates := range []bool{true, false} {
+		config.Cfg.Computation.Count_duplicate_alleles = countDuplicates
+		sequential := dna.AlleleCountFactory()
+		for _, indRef := range p.IndivRefs { indRef.Indiv.CountAlleles(sequential) }
+		sequential.Compact()
+
+		concurrent := p.countAlleles(!countDuplicates, 10)		// free the individuals on the last pass
+		if !reflect.DeepEqual(alleleSlices(concurrent), alleleSlices(sequential)) { t.Errorf("Concurrent allele counts differ from the sequential counts (count_duplicate_alleles=%v)", countDuplicates) }
+	}
+	for i := range p.IndivRefs {
+		if p.IndivRefs[i].Indiv != nil { t.Fatalf("Individual %d was not freed after counting its alleles in the last gen", i) }
+	}
+}
+
+func alleleSlices(alleles *dna.AlleleCount) [][]dna.Allele {
+	return [][]dna.Allele{alleles.DeleteriousDom, alleles.DeleteriousRec, alleles.Neutral, alleles.FavorableDom, alleles.FavorableRec, alleles.DelInitialAlleles, alleles.FavInitialAlleles}
+}
+
+
+// With chromosome_lbs, each chromosome must have its own number of LBs, and new mutations must be spread over the LBs of all of the chromosomes
+func TestUnequalChromosomes(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Mutations.Mutn_rate = 50.0
+		c.Population.Haploid_chromosome_number = 3
+		c.Population.Num_linkage_subunits = 20
+		c.Population.Chromosome_lbs = "2, 5, 13"
+		c.Computation.Tracking_threshold = 0.0
+	})
+	for _, expected := range [][3]int{{0, 0, 0}, {1, 0, 1}, {2, 1, 0}, {6, 1, 4}, {7, 2, 0}, {19, 2, 12}} {		// genome LB, chromosome, LB in chromosome
+		if chr, lbInChr := dna.LocateLb(expected[0]); chr != expected[1] || lbInChr != expected[2] { t.Errorf("LB %d of the genome is LB %d of chromosome %d, expected LB %d of chromosome %d", expected[0], lbInChr, chr, expected[2], expected[1]) }
+	}
+
+	uniformRandom := rand.New(rand.NewSource(1))
+	s := SpeciesFactory().Initialize(1, uniformRandom)
+	for gen := uint32(1); gen <= 2; gen++ {
+		childrenS := s.GetNextGeneration(gen)
+		s.Mate(childrenS, uniformRandom)
+		s = childrenS
+	}
+	numMutations := make([]int, 3)
+	var total int
+	for _, indRef := range s.Populations[0].IndivRefs {
+		ind := indRef.Indiv
+		for c, expected := range []int{2, 5, 13} {
+			for _, chromo := range []*dna.Chromosome{&ind.ChromosomesFromDad[c], &ind.ChromosomesFromMom[c]} {
+				if len(chromo.LinkageBlocks) != expected { t.Fatalf("Chromosome %d has %d LBs, expected %d", c, len(chromo.LinkageBlocks), expected) }
+				for _, lb := range chromo.LinkageBlocks {
+					numMutations[c] += len(lb.GetMutations())
+					total += len(lb.GetMutations())
+				}
+			}
+		}
+	}
+	for c, expected := range []float64{0.1, 0.25, 0.65} {
+		if frac := float64(numMutations[c]) / float64(total); frac < expected - 0.05 || frac > expected + 0.05 { t.Errorf("Chromosome %d has %v of the mutations, expected about %v", c, frac, expected) }
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"math/rand"
 	"sync"
 	"github.com/genetic-algorithms/mendel-go/utils"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
 	"log"
 )
 
@@ -16,6 +18,9 @@ type PopulationPart struct {
 	NextIndivIndex int              // supports reusing the Individual objects in a repurposed part
 	Pop            *Population      // a reference back to the whole population, but that object should only be read
 	MyUniqueInt    *utils.UniqueInt // this part gets its own range for mutation id's that can be manipulated concurrently with the gloabl one. This is set in Mate().
+	NumBackMutations uint32         // the number of mutations reverted by back mutations in the offspring of this part. Population.Mate() sums these.
+	newMutns []dna.Mutation          // the tracked mutations that arose in the offspring of this part, only gathered when track_fixation==true
+	pedigreeParents map[*Individual][2]uint64		// the pedigree ids of the dad and mom of each offspring of this part, only when track_pedigree==true
 
 									// Note: fitness stats are saved at the Population level, not at the part level...
 }
@@ -24,10 +29,14 @@ type PopulationPart struct {
 // PopulationPartFactory returns an instance of PopulationPart
 func PopulationPartFactory(numIndivs uint32, pop *Population) *PopulationPart {
 	p := &PopulationPart{Pop: pop}
+	if IsPedigreeEnabled() { p.pedigreeParents = make(map[*Individual][2]uint64) }
 
 	if numIndivs > 0 {
 		p.Indivs = make([]*Individual, 0, numIndivs)
 		for i:=uint32(1); i<= numIndivs; i++ { p.Indivs = append(p.Indivs, IndividualFactory(p, true)) }
+		if config.Cfg.Population.Separate_sexes {
+			for i, ind := range p.Indivs { ind.Male = i % 2 == 0 }		// the genesis pop is half male and half female
+		}
 	}
 
 	return p
@@ -60,6 +69,21 @@ func (p *PopulationPart) Mate(parentPop *Population, parentIndices []int, unique
 	// Note: the caller already shuffled the parents
 
 	p.SetEstimatedNumIndivs(uint32(float64(len(parentIndices)) * p.Pop.Num_offspring))
+	fractionSelfing := config.Cfg.Population.Fraction_self_fertilization
+
+	if RecombinationType(config.Cfg.Population.Recombination_model) == CLONAL {
+		// Each individual reproduces on its own, either by cloning or (for fraction_self_fertilization of them) by fertilizing itself
+		for _, indI := range parentIndices {
+			ind := parentPop.IndivRefs[indI].Indiv
+			if fractionSelfing > 0.0 && uniformRandom.Float64() < fractionSelfing {
+				ind.Mate(ind, p, uniformRandom)
+			} else {
+				ind.Clone(p, uniformRandom)
+			}
+			parentPop.FreeParentRefs(indI, indI)
+		}
+		return
+	}
 
 	// Mate pairs and create the offspring. Now that we have shuffled the parent indices, we can just go 2 at a time thru the indices.
 	for i := 0; i < len(parentIndices) - 1; i += 2 {
@@ -67,7 +91,16 @@ func (p *PopulationPart) Mate(parentPop *Population, parentIndices []int, unique
 		momI := parentIndices[i+1]
 		// dadI and momI are just indices into the combined Indivs array in the Population object, so we index into that.
 		// Each PopulationPart has a distinct subset of indices, so this is thread-safe.
-		parentPop.IndivRefs[dadI].Indiv.Mate(parentPop.IndivRefs[momI].Indiv, p, uniformRandom)
+		dad := parentPop.IndivRefs[dadI].Indiv
+		mom := parentPop.IndivRefs[momI].Indiv
+		// Note: only draw the random number when self-fertilization is requested, so the random number sequence is unchanged when it is not
+		if fractionSelfing > 0.0 && uniformRandom.Float64() < fractionSelfing {
+			// Instead of mating with each other, each of this pair fertilizes itself
+			dad.Mate(dad, p, uniformRandom)
+			mom.Mate(mom, p, uniformRandom)
+		} else {
+			dad.Mate(mom, p, uniformRandom)
+		}
 		//p.Append(newChildren...) 		// <- Mate() already adds the Individuals to this part
 
 		parentPop.FreeParentRefs(dadI, momI)
@@ -0,0 +1,78 @@
+package pop
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+)
+
+// With a mutation map that only has weight on 1 chromosome, all new mutations must be on that chromosome. With a crossover map that only has
+// weight at 1 point of each chromosome, the crossover must only switch parents at that point.
+func TestRateMaps(t *testing.T) {
+	dir := t.TempDir()
+	mutnMap := filepath.Join(dir, "mutn.map")
+	if err := os.WriteFile(mutnMap, []byte("# 1 weight per chromosome\n0\n1\n0\n"), 0644); err != nil { t.Fatalf("Error writing %v: %v", mutnMap, err) }
+	crossoverMap := filepath.Join(dir, "crossover.map")
+	var crossoverWeights string
+	for chr := 0; chr < 3; chr++ { crossoverWeights += "0\n0\n0\n0\n1\n0\n0\n0\n0\n0\n" }		// only the point between LBs 3 and 4
+	if err := os.WriteFile(crossoverMap, []byte(crossoverWeights), 0644); err != nil { t.Fatalf("Error writing %v: %v", crossoverMap, err) }
+
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Mutations.Mutn_rate = 10.0
+		c.Mutations.Mutn_rate_map_file = mutnMap
+		c.Population.Haploid_chromosome_number = 3
+		c.Population.Num_linkage_subunits = 30
+		c.Population.Mean_num_crossovers = 4
+		c.Population.Crossover_map_file = crossoverMap
+		c.Computation.Tracking_threshold = 0.0
+	})
+
+	uniformRandom := rand.New(rand.NewSource(1))
+	parents := SpeciesFactory().Initialize(1, uniformRandom)
+	children := parents.GetNextGeneration(1)
+	parents.Mate(children, uniformRandom)
+	p := children.Populations[0]
+	if p.GetCurrentSize() == 0 { t.Fatalf("No children were created") }
+
+	// The initial pop has no mutations, so all of the children's mutations are new
+	var numMutations int
+	for _, indRef := range p.IndivRefs {
+		ind := indRef.Indiv
+		for c := range ind.ChromosomesFromDad {
+			for _, chromo := range []*dna.Chromosome{&ind.ChromosomesFromDad[c], &ind.ChromosomesFromMom[c]} {
+				for _, lb := range chromo.LinkageBlocks {
+					n := len(lb.GetMutations())
+					if c != 1 && n > 0 { t.Fatalf("Chromosome %d has %d mutations, but its mutation rate is 0", c, n) }
+					numMutations += n
+				}
+			}
+		}
+	}
+	if numMutations == 0 { t.Errorf("No mutations were added to chromosome 1") }
+
+	// Give every LB of a dad chromosome mutation 1 and every LB of a mom chromosome mutation 2, so each LB of the crossover shows where it came from
+	var dad, mom dna.Chromosome
+	dad.ChromosomeFactory(10)
+	mom.ChromosomeFactory(10)
+	for lb := 0; lb < 10; lb++ {
+		dad.AddMutation(lb, dna.Mutation{Id: 1, Type: dna.DELETERIOUS_DOMINANT, FitnessEffect: -0.001})
+		mom.AddMutation(lb, dna.Mutation{Id: 2, Type: dna.DELETERIOUS_DOMINANT, FitnessEffect: -0.001})
+	}
+	var numSwitched int
+	for i := 0; i < 1000; i++ {
+		var offspr dna.Chromosome
+		offspr.ChromosomeFactory(10)
+		dna.PartialCrossover(&dad, &mom, &offspr, 1, 10, uniformRandom)
+		for lb := 1; lb < 10; lb++ {
+			if offspr.LinkageBlocks[lb].GetMutations()[0].Id == offspr.LinkageBlocks[lb-1].GetMutations()[0].Id { continue }
+			if lb != 4 { t.Fatalf("The crossover switched parents before LB %d, but the crossover map only allows it before LB 4", lb) }
+			numSwitched++
+		}
+	}
+	if numSwitched == 0 { t.Errorf("The crossover never switched parents before LB 4") }
+}
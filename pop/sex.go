@@ -0,0 +1,175 @@
+package pop
+
+import (
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"log"
+	"math/rand"
+	"strings"
+)
+
+// When separate_sexes==true each individual is male or female and the last chromosome is the sex chromosome. With sex_chromosome_system==xy males are
+// XY and females XX, and with zw females are ZW and males ZZ. The heterogametic sex (XY males or ZW females) keeps its Y (or W) in the chromosome it
+// inherited from the parent of its own sex: ChromosomesFromDad for XY males and ChromosomesFromMom for ZW females. It passes on either its X/Z or its
+// Y/W whole (no crossover), which determines the sex of the offspring. Each of its sex chromosomes is the only copy of its LBs, so their mutations
+// are hemizygous (see dna.HemizygousEffect()).
+
+
+// sexChromosome returns the index of the sex chromosome
+func sexChromosome() int { return int(config.Cfg.Population.Haploid_chromosome_number) - 1 }
+
+
+// isZwSystem returns true if females are the heterogametic sex
+func isZwSystem() bool {
+	return SexChromosomeSystemType(strings.ToLower(config.Cfg.Population.Sex_chromosome_system)) == ZW_SEX_CHROMOSOMES
+}
+
+
+// IsHeterogametic returns true if this individual has 2 different sex chromosomes (an XY male or a ZW female)
+func (ind *Individual) IsHeterogametic() bool {
+	return config.Cfg.Population.Separate_sexes && ind.Male != isZwSystem()
+}
+
+
+// onHeteroChromosome returns true if chromosome c of this individual (ChromosomesFromDad[c] if fromDad, otherwise ChromosomesFromMom[c]) is a Y (or W).
+// The heterogametic individual's Y (or W) is its sex chromosome from the parent of the same sex.
+func (ind *Individual) onHeteroChromosome(c int, fromDad bool) bool {
+	return c == sexChromosome() && ind.IsHeterogametic() && fromDad == ind.Male
+}
+
+
+// sexChromosomeCopies returns the number of Y (or W) chromosomes if onHetero is true, otherwise the number of X (or Z) chromosomes, among this
+// many males and females. Each heterogametic individual has 1 of each, and each homogametic individual has 2 X (or Z).
+func sexChromosomeCopies(onHetero bool, males, females uint32) uint32 {
+	homo, hetero := females, males
+	if isZwSystem() { homo, hetero = males, females }
+	if onHetero { return hetero }
+	return 2 * homo + hetero
+}
+
+
+// inheritSexChromosome fills in the sex chromosome of this offspring from dad and mom, and sets its sex. The homogametic parent crosses over as usual.
+func (offspr *Individual) inheritSexChromosome(dad, mom *Individual, uniformRandom *rand.Rand) {
+	s := sexChromosome()
+	lBsPerChromosome := dad.popPart.Pop.LBsPerChromosome[s]
+	if isZwSystem() {
+		// Mom passes her W (making a daughter) or her Z (making a son)
+		offspr.addInheritedMutations(dna.Mdl.Crossover(&dad.ChromosomesFromDad[s], &dad.ChromosomesFromMom[s], &offspr.ChromosomesFromDad[s], uint32(s), lBsPerChromosome, uniformRandom))
+		passW := uniformRandom.Intn(2) == 0
+		if passW {
+			offspr.addInheritedMutations(mom.ChromosomesFromMom[s].Copy(&offspr.ChromosomesFromMom[s]))
+		} else {
+			offspr.addInheritedMutations(mom.ChromosomesFromDad[s].Copy(&offspr.ChromosomesFromMom[s]))
+		}
+		offspr.Male = !passW
+	} else {
+		// Dad passes his Y (making a son) or his X (making a daughter)
+		passY := uniformRandom.Intn(2) == 0
+		if passY {
+			offspr.addInheritedMutations(dad.ChromosomesFromDad[s].Copy(&offspr.ChromosomesFromDad[s]))
+		} else {
+			offspr.addInheritedMutations(dad.ChromosomesFromMom[s].Copy(&offspr.ChromosomesFromDad[s]))
+		}
+		offspr.addInheritedMutations(dna.Mdl.Crossover(&mom.ChromosomesFromDad[s], &mom.ChromosomesFromMom[s], &offspr.ChromosomesFromMom[s], uint32(s), lBsPerChromosome, uniformRandom))
+		offspr.Male = passY
+	}
+}
+
+
+// hemizygousFitness returns the change to the additive fitness of this individual because its sex chromosomes are hemizygous. This is 0 unless it is the heterogametic sex.
+func (ind *Individual) hemizygousFitness() float64 {
+	if !ind.IsHeterogametic() { return 0.0 }
+	s := sexChromosome()
+	return ind.ChromosomesFromDad[s].HemiSumFitness() - ind.ChromosomesFromDad[s].SumFitness() + ind.ChromosomesFromMom[s].HemiSumFitness() - ind.ChromosomesFromMom[s].SumFitness()
+}
+
+
+// markSexChromosomeAlleles records in alleles which of this individual's alleles are on the X (or Z) and which are on the Y (or W), for their SFS (see outputSfs())
+func (ind *Individual) markSexChromosomeAlleles(alleles *dna.AlleleCount) {
+	s := sexChromosome()
+	for i, chr := range []*dna.Chromosome{&ind.ChromosomesFromDad[s], &ind.ChromosomesFromMom[s]} {
+		onHetero := ind.onHeteroChromosome(s, i == 0)
+		for lb := range chr.LinkageBlocks {
+			for _, m := range chr.LinkageBlocks[lb].GetMutations() { alleles.MarkSexChromosome(m.Id, onHetero) }
+		}
+	}
+}
+
+
+// pairSexes reorders the shuffled parent indices so that each pair is a male (the dad) followed by a female (the mom). The individuals of the more
+// numerous sex that have no mate are left out.
+func (p *Population) pairSexes(parentIndices []int) []int {
+	var males, females []int
+	for _, i := range parentIndices {
+		if p.IndivRefs[i].Indiv.Male {
+			males = append(males, i)
+		} else {
+			females = append(females, i)
+		}
+	}
+	numPairs := len(males)
+	if len(females) < numPairs { numPairs = len(females) }
+	if numPairs < len(males) || numPairs < len(females) { config.Verbose(3, "Tribe %d has %d males and %d females, so %d individuals have no mate", p.TribeNum, len(males), len(females), len(males) + len(females) - 2*numPairs) }
+
+	pairs := make([]int, 0, 2*numPairs)
+	for i := 0; i < numPairs; i++ { pairs = append(pairs, males[i], females[i]) }
+	return pairs
+}
+
+
+// GetSexCounts returns the number of males and females in this population. GetFitnessStats() counts and caches them, so this can be called after the
+// individuals have been freed.
+func (p *Population) GetSexCounts() (males, females uint32) {
+	p.GetFitnessStats()
+	return p.NumMales, p.NumFemales
+}
+
+
+// GetSexCounts returns the number of males and females in the species
+func (s *Species) GetSexCounts() (males, females uint32) {
+	for _, p := range s.Populations {
+		m, f := p.GetSexCounts()
+		males += m
+		females += f
+	}
+	return
+}
+
+
+// hasBothSexes returns true if this population has at least 1 male and 1 female
+func (p *Population) hasBothSexes() bool {
+	males, females := p.GetSexCounts()
+	return males > 0 && females > 0
+}
+
+
+// ReportSexChromosomes logs the number of each sex and the mean number of deleterious mutations on each kind of sex chromosome
+func (p *Population) ReportSexChromosomes() {
+	s := sexChromosome()
+	var numHomo, numHetero uint32		// the number of X (or Z) and Y (or W) chromosomes
+	var delHomo, delHetero uint32
+	for _, indRef := range p.IndivRefs {
+		ind := indRef.Indiv
+		for i, chr := range []*dna.Chromosome{&ind.ChromosomesFromDad[s], &ind.ChromosomesFromMom[s]} {
+			var deleterious uint32
+			for lb := range chr.LinkageBlocks {
+				d, _, _, _, _ := chr.LinkageBlocks[lb].GetMutationStats()
+				deleterious += d
+			}
+			if ind.onHeteroChromosome(s, i == 0) {
+				numHetero++
+				delHetero += deleterious
+			} else {
+				numHomo++
+				delHomo += deleterious
+			}
+		}
+	}
+	homoName, heteroName := "X", "Y"
+	if isZwSystem() { homoName, heteroName = "Z", "W" }
+	var meanHomo, meanHetero float64
+	if numHomo > 0 { meanHomo = float64(delHomo) / float64(numHomo) }
+	if numHetero > 0 { meanHetero = float64(delHetero) / float64(numHetero) }
+	males, females := p.GetSexCounts()
+	log.Printf(" Sexes: males: %d, females: %d, mean deleterious mutations per %s: %v, per %s: %v", males, females, homoName, meanHomo, heteroName, meanHetero)
+}
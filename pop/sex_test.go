@@ -0,0 +1,66 @@
+package pop
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+)
+
+// The Y must only be passed from fathers to sons without crossing over, and a male's X mutations must have their hemizygous effect
+func TestSeparateSexes(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 40
+		c.Mutations.Mutn_rate = 0.0
+		c.Population.Haploid_chromosome_number = 3
+		c.Population.Num_linkage_subunits = 30
+		c.Population.Separate_sexes = true
+		c.Population.Sex_chromosome_system = "xy"
+		c.Computation.Tracking_threshold = 0.0
+	})
+
+	uniformRandom := rand.New(rand.NewSource(1))
+	parents := SpeciesFactory().Initialize(1, uniformRandom)
+	p := parents.Populations[0]
+	if males, females := p.GetSexCounts(); males != 20 || females != 20 { t.Fatalf("The genesis pop has %d males and %d females, expected 20 and 20", males, females) }
+
+	// A deleterious mutation on the X of a male is fully expressed, but on the X of a female it is reduced by dominant_hetero_expression
+	male, female := IndividualFactory(p.Parts[0], true), IndividualFactory(p.Parts[0], true)
+	male.Male = true
+	mutn := dna.Mutation{Id: 1, Type: dna.DELETERIOUS_DOMINANT, FitnessEffect: -0.005}
+	male.ChromosomesFromMom[2].AddMutation(0, mutn)
+	female.ChromosomesFromMom[2].AddMutation(0, mutn)
+	expected := 1.0 + float64(mutn.FitnessEffect) / config.Cfg.Mutations.Dominant_hetero_expression
+	if fitness := SumIndivFitness(male); math.Abs(fitness - expected) > 1.e-6 { t.Errorf("The male's fitness is %v, expected %v", fitness, expected) }
+	if fitness := SumIndivFitness(female); math.Abs(fitness - (1.0 + float64(mutn.FitnessEffect))) > 1.e-6 { t.Errorf("The female's fitness is %v, expected %v", fitness, 1.0 + mutn.FitnessEffect) }
+
+	// Mark every LB of every Y, so the children show where each of their sex chromosomes came from
+	for _, indRef := range p.IndivRefs {
+		if !indRef.Indiv.Male { continue }
+		for lb := 0; lb < 10; lb++ { indRef.Indiv.ChromosomesFromDad[2].AddMutation(lb, dna.Mutation{Id: 2, Type: dna.DELETERIOUS_DOMINANT, FitnessEffect: -0.001}) }
+	}
+	children := parents.GetNextGeneration(1)
+	parents.Mate(children, uniformRandom)
+	childPop := children.Populations[0]
+	if males, females := childPop.GetSexCounts(); males == 0 || females == 0 { t.Fatalf("The children are %d males and %d females, expected both sexes", males, females) }
+	for _, indRef := range childPop.IndivRefs {
+		ind := indRef.Indiv
+		for lb := 0; lb < 10; lb++ {
+			if hasY := len(ind.ChromosomesFromDad[2].LinkageBlocks[lb].GetMutations()) > 0; hasY != ind.Male { t.Fatalf("LB %d of the sex chromosome from dad has the Y marker: %v, but the child is male: %v", lb, hasY, ind.Male) }
+			if len(ind.ChromosomesFromMom[2].LinkageBlocks[lb].GetMutations()) > 0 { t.Fatalf("LB %d of the sex chromosome from mom has the Y marker", lb) }
+		}
+	}
+}
+
+// The number of copies of each kind of sex chromosome, used as the sample size for the frequencies of the alleles on them
+func TestSexChromosomeCopies(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Population.Separate_sexes = true
+		c.Population.Sex_chromosome_system = "xy"
+	})
+	if x, y := sexChromosomeCopies(false, 3, 5), sexChromosomeCopies(true, 3, 5); x != 13 || y != 3 { t.Errorf("3 males and 5 females have %d X and %d Y, expected 13 and 3", x, y) }
+	config.Cfg.Population.Sex_chromosome_system = "zw"
+	if z, w := sexChromosomeCopies(false, 3, 5), sexChromosomeCopies(true, 3, 5); z != 11 || w != 5 { t.Errorf("3 males and 5 females have %d Z and %d W, expected 11 and 5", z, w) }
+}
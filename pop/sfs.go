@@ -0,0 +1,120 @@
+package pop
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"log"
+	"math"
+)
+
+// The site frequency spectrum output (allele-sfs/) is written each gen that alleles are counted, for each tribe and (when there are tribes) for the
+// whole species. Each tracked allele is treated as its own site. The SFS is unfolded and in exact allele-count classes: element i-1 of each
+// slice is the number of alleles that occur in exactly i of the n sampled genomes, where n is 2 * pop size (this is why allele-sfs/ requires
+// count_duplicate_alleles=true, otherwise each count would be the number of individuals the allele occurs in). The last element is the alleles that
+// are fixed. The summary statistics are computed from the segregating alleles (classes 1 to n-1) of all types, and are for the whole genome (not per site).
+// When separate_sexes==true there are fewer copies of the sex chromosomes, so the main SFS is of the autosomes only, and the alleles on the X (or Z)
+// and on the Y (or W) each have their own SFS, with n = 2 * homogametic individuals + heterogametic individuals, and n = heterogametic individuals.
+
+type SiteFrequencySpectrum struct {
+	Generation uint32 `json:"generation"`
+	SampleSize uint32 `json:"sampleSize"`
+	Deleterious []uint32 `json:"deleterious"`
+	Neutral []uint32 `json:"neutral"`
+	Favorable []uint32 `json:"favorable"`
+	SegregatingSites uint64 `json:"segregatingSites"`
+	FixedSites uint64 `json:"fixedSites"`
+	Pi float64 `json:"pi"`
+	ThetaW float64 `json:"thetaW"`
+	TajimasD float64 `json:"tajimasD"`
+	HomogameticChromosome *SiteFrequencySpectrum `json:"homogameticChromosome,omitempty"`		// the X (or Z), only when separate_sexes==true
+	HeterogameticChromosome *SiteFrequencySpectrum `json:"heterogameticChromosome,omitempty"`		// the Y (or W), only when separate_sexes==true
+}
+
+
+// outputSfs calculates the SFS and summary statistics from alleles and writes them to allele-sfs/ for tribeNum (0 is the whole species).
+// males and females are only used when separate_sexes==true.
+func outputSfs(genNum, popSize, males, females, tribeNum uint32, alleles *dna.AlleleCount) {
+	if !config.FMgr.IsDir(config.ALLELE_SFS_DIRECTORY) || popSize == 0 { return }
+	sfs := calcSfs(genNum, 2 * popSize, alleles, func(id uint64) bool { _, ok := alleles.SexChromosome[id]; return !ok })
+	if config.Cfg.Population.Separate_sexes {
+		sfs.HomogameticChromosome = calcSfs(genNum, sexChromosomeCopies(false, males, females), alleles, func(id uint64) bool { onHetero, ok := alleles.SexChromosome[id]; return ok && !onHetero })
+		sfs.HeterogameticChromosome = calcSfs(genNum, sexChromosomeCopies(true, males, females), alleles, func(id uint64) bool { onHetero, ok := alleles.SexChromosome[id]; return ok && onHetero })
+	}
+	if tribeNum == 0 {
+		config.Verbose(1, "Species SFS stats: segregating sites: %d, fixed sites: %d, pi: %v, theta_W: %v, Tajima's D: %v", sfs.SegregatingSites, sfs.FixedSites, sfs.Pi, sfs.ThetaW, sfs.TajimasD)
+	} else {
+		config.Verbose(1, "Tribe %d SFS stats: segregating sites: %d, fixed sites: %d, pi: %v, theta_W: %v, Tajima's D: %v", tribeNum, sfs.SegregatingSites, sfs.FixedSites, sfs.Pi, sfs.ThetaW, sfs.TajimasD)
+	}
+
+	newJson, err := json.Marshal(sfs)
+	if err != nil { log.Fatalf("error marshaling the site frequency spectrum to json: %v", err) }
+	fileName := fmt.Sprintf("%08d.json", genNum)
+	if sfsWriter := config.FMgr.GetDirFile(config.ALLELE_SFS_DIRECTORY, fileName, tribeNum); sfsWriter != nil {
+		if _, err := sfsWriter.Write(newJson); err != nil { log.Fatalf("error writing the site frequency spectrum to %v: %v", fileName, err) }
+		config.FMgr.CloseDirFile(config.ALLELE_SFS_DIRECTORY, fileName, tribeNum)
+	}
+}
+
+
+// calcSfs returns the SFS and summary statistics of the alleles that include returns true for, in a sample of sampleSize genomes (or nil if it is 0)
+func calcSfs(genNum, sampleSize uint32, alleles *dna.AlleleCount, include func(id uint64) bool) *SiteFrequencySpectrum {
+	if sampleSize == 0 { return nil }
+	sfs := &SiteFrequencySpectrum{Generation: genNum, SampleSize: sampleSize}
+	sfs.Deleterious = make([]uint32, sampleSize)
+	fillSfs(sfs.Deleterious, include, alleles.DeleteriousDom, alleles.DeleteriousRec, alleles.DelInitialAlleles)
+	sfs.Neutral = make([]uint32, sampleSize)
+	fillSfs(sfs.Neutral, include, alleles.Neutral)
+	sfs.Favorable = make([]uint32, sampleSize)
+	fillSfs(sfs.Favorable, include, alleles.FavorableDom, alleles.FavorableRec, alleles.FavInitialAlleles)
+
+	total := make([]uint32, sampleSize)
+	for i := range total { total[i] = sfs.Deleterious[i] + sfs.Neutral[i] + sfs.Favorable[i] }
+	sfs.FixedSites = uint64(total[sampleSize-1])
+	sfs.SegregatingSites, sfs.Pi, sfs.ThetaW, sfs.TajimasD = SfsStats(total)
+	return sfs
+}
+
+
+// fillSfs adds 1 to the class of the count of each allele in the given slices that include returns true for
+func fillSfs(sfs []uint32, include func(id uint64) bool, alleleSlices ...[]dna.Allele) {
+	for _, alleles := range alleleSlices {
+		for _, al := range alleles {
+			if al.Count == 0 || !include(al.Id) { continue }
+			i := int(al.Count) - 1
+			if i >= len(sfs) { i = len(sfs) - 1 }		// can not happen, but just a safeguard
+			sfs[i]++
+		}
+	}
+}
+
+
+// SfsStats returns the number of segregating sites S, the nucleotide diversity pi (the mean number of differences between 2 sampled genomes),
+// Watterson's theta (S / a1), and Tajima's D, from an unfolded SFS whose element i-1 is the number of sites with i copies in a sample of len(sfs) genomes.
+// Tajima's D is 0 when there are no segregating sites.
+func SfsStats(sfs []uint32) (segregating uint64, pi, thetaW, tajimasD float64) {
+	n := len(sfs)
+	if n < 2 { return }
+	nf := float64(n)
+	var a1, a2 float64
+	for i := 1; i < n; i++ {
+		a1 += 1.0 / float64(i)
+		a2 += 1.0 / float64(i * i)
+		segregating += uint64(sfs[i-1])
+		pi += float64(sfs[i-1]) * 2.0 * float64(i) * float64(n-i) / (nf * (nf - 1.0))
+	}
+	if segregating == 0 { return }
+	S := float64(segregating)
+	thetaW = S / a1
+
+	// Tajima (1989)
+	b1 := (nf + 1.0) / (3.0 * (nf - 1.0))
+	b2 := 2.0 * (nf*nf + nf + 3.0) / (9.0 * nf * (nf - 1.0))
+	c1 := b1 - 1.0/a1
+	c2 := b2 - (nf + 2.0)/(a1 * nf) + a2/(a1 * a1)
+	e1 := c1 / a1
+	e2 := c2 / (a1*a1 + a2)
+	if variance := e1*S + e2*S*(S - 1.0); variance > 0.0 { tajimasD = (pi - thetaW) / math.Sqrt(variance) }
+	return
+}
@@ -0,0 +1,26 @@
+package pop
+
+import (
+	"math"
+	"testing"
+)
+
+// For the neutral expectation xi_i = theta/i, pi and Watterson's theta must both equal theta and Tajima's D must be 0. An excess of rare alleles must
+// give a negative D, and an excess of intermediate alleles a positive D.
+func TestSfsStats(t *testing.T) {
+	neutral := []uint32{60, 30, 20, 15, 12, 0}		// n = 6, theta = 60
+	segregating, pi, thetaW, tajimasD := SfsStats(neutral)
+	if segregating != 137 { t.Errorf("Segregating sites is %d, expected 137", segregating) }
+	if math.Abs(pi - 60.0) > 1.e-9 { t.Errorf("Pi is %v, expected 60", pi) }
+	if math.Abs(thetaW - 60.0) > 1.e-9 { t.Errorf("Watterson's theta is %v, expected 60", thetaW) }
+	if math.Abs(tajimasD) > 1.e-9 { t.Errorf("Tajima's D is %v, expected 0", tajimasD) }
+
+	// 1 singleton and 1 doubleton in a sample of 4: pi = (1*3 + 2*2) / C(4,2), theta_W = 2 / (1 + 1/2 + 1/3)
+	_, pi, thetaW, _ = SfsStats([]uint32{1, 1, 0, 0})
+	if math.Abs(pi - 7.0/6.0) > 1.e-9 { t.Errorf("Pi is %v, expected %v", pi, 7.0/6.0) }
+	if math.Abs(thetaW - 12.0/11.0) > 1.e-9 { t.Errorf("Watterson's theta is %v, expected %v", thetaW, 12.0/11.0) }
+
+	if _, _, _, d := SfsStats([]uint32{100, 5, 5, 5, 5, 0}); d >= 0.0 { t.Errorf("Tajima's D is %v for an excess of rare alleles, expected < 0", d) }
+	if _, _, _, d := SfsStats([]uint32{5, 5, 100, 5, 5, 0}); d <= 0.0 { t.Errorf("Tajima's D is %v for an excess of intermediate alleles, expected > 0", d) }
+	if s, p, w, d := SfsStats([]uint32{0, 0, 0, 7}); s != 0 || p != 0.0 || w != 0.0 || d != 0.0 { t.Errorf("Fixed alleles must not count as segregating, got S=%d, pi=%v, theta_W=%v, D=%v", s, p, w, d) }
+}
@@ -2,6 +2,7 @@ package pop
 
 import (
 	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
 	"github.com/genetic-algorithms/mendel-go/random"
 	"math/rand"
 	"github.com/genetic-algorithms/mendel-go/utils"
@@ -38,6 +39,7 @@ func (s *Species) Initialize(maxGenNum uint32, uniformRandom *rand.Rand) *Specie
 		s.Populations[i] = PopulationFactory(nil, 0, uint32(i+1), s.PartsPerPop) 		// genesis population
 		Mdl.GenerateInitialAlleles(s.Populations[i], newRandom)
 	}
+	if IsFixationEnabled() { fixation = fixationTrackerFactory() }		// the initial alleles are not followed, only the mutations that arise during the run
 	s.ReportInitial()
 	return s 		// so we can chain calls
 }
@@ -59,8 +61,10 @@ func (s *Species) GetCurrentSize() (size uint32) {
 func (parentS *Species) GetNextGeneration(gen uint32) (childrenS *Species) {
 	random.NextSeed = config.Cfg.Computation.Random_number_seed + 1		// reset the seed to 1 above our initial seed, so when we call RandFactory() in Mate() for additional threads it will work like it did before
 	childrenS = SpeciesFactory()
-	for i := range parentS.Populations {
-		childrenS.Populations[i] = PopulationFactory(parentS.Populations[i], gen, uint32(i+1), parentS.PartsPerPop)	// this creates the PopulationParts too
+	parentS.removeSplitTribes()
+	childrenS.Populations = make([]*Population, len(parentS.Populations))		// tribal fission can add and remove tribes during the run
+	for i, parentP := range parentS.Populations {
+		childrenS.Populations[i] = PopulationFactory(parentP, gen, parentP.TribeNum, parentS.PartsPerPop)	// this creates the PopulationParts too
 	}
 	return
 }
@@ -78,6 +82,7 @@ func (parentS *Species) Mate(childrenS *Species, uniformRandom *rand.Rand) {
 		}
 		parentS.Populations[i].Mate(childrenS.Populations[i], newRandom)
 	}
+	pedigreeIds = nil		// the offspring have noted their parents' pedigree ids, so let the parents be freed
 }
 
 // Select does selection on all of the populations
@@ -116,16 +121,32 @@ func (s *Species) ReportInitial() {
 		p.ReportInitial()
 	}
 
-	if config.Cfg.Tribes.Num_tribes > 1 {
+	if plgWriter := config.FMgr.GetFile(config.POLYGENIC_FILENAME, 0); plgWriter != nil {
+		if _, err := plgWriter.WriteString(POLYGENIC_HEADER); err != nil { log.Fatalf("Error writing %v: %v", config.POLYGENIC_FILENAME, err) }
+	}
+
+	if config.HasTribeFiles() {
 		// Also initialize the summary/average files for the whole species
 		if histWriter0 := config.FMgr.GetFile(config.HISTORY_FILENAME, 0); histWriter0 != nil {
 			// Write header for this file
-			fmt.Fprintln(histWriter0, "# Generation  Avg-deleterious Avg-neutral  Avg-favorable")
+			if config.Cfg.Mutations.Allow_back_mutn {
+				fmt.Fprintln(histWriter0, "# Generation  Avg-deleterious Avg-neutral  Avg-favorable  Back-mutations")
+			} else {
+				fmt.Fprintln(histWriter0, "# Generation  Avg-deleterious Avg-neutral  Avg-favorable")
+			}
 		}
 
 		if fitWriter0 := config.FMgr.GetFile(config.FITNESS_FILENAME, 0); fitWriter0 != nil {
 			// Write header for this file
-			fmt.Fprintln(fitWriter0, "# Generation  Pop-size  Avg Offspring  Avg-fitness  Min-fitness  Max-fitness  Total Mutns  Mean Mutns  Noise")
+			if IsTribalCompetitionEnabled() {
+				fmt.Fprintln(fitWriter0, "# Generation  Pop-size  Avg Offspring  Avg-fitness  Min-fitness  Max-fitness  Total Mutns  Mean Mutns  Noise  Tribes")
+			} else {
+				fmt.Fprintln(fitWriter0, "# Generation  Pop-size  Avg Offspring  Avg-fitness  Min-fitness  Max-fitness  Total Mutns  Mean Mutns  Noise")
+			}
+		}
+
+		if mitoWriter0 := config.FMgr.GetFile(config.MITO_FILENAME, 0); mitoWriter0 != nil {
+			if _, err := mitoWriter0.WriteString(MITO_HEADER); err != nil { log.Fatalf("Error writing %v: %v", config.MITO_FILENAME, err) }
 		}
 	}
 }
@@ -140,6 +161,7 @@ func (s *Species) GetFitnessStats() (meanFitness float64, minFitness float64, ma
 	speciesSize = uint64(0)
 	for _, p := range s.Populations {
 		popSize := p.GetCurrentSize()
+		if popSize == 0 { continue }		// an empty tribe would make minFitness 0
 		speciesSize += uint64(popSize)
 		meanFit, minFit, maxFit, totalNumMuts, meanNumMuts := p.GetFitnessStats()
 		meanFitness += meanFit * float64(popSize)	// we want meanFitness to be the mean of all indivs in all pops
@@ -182,7 +204,7 @@ func (s *Species) ReportEachGen(genNum uint32, lastGen bool, totalInterimTime, g
 	}
 
 	// Report the overall species stats
-	if config.Cfg.Tribes.Num_tribes > 1 {
+	if config.HasTribeFiles() {
 		perGenMinimalVerboseLevel := uint32(1) // level at which we will print only the info that is very quick to gather
 		finalVerboseLevel := uint32(1)         // level at which we will print species summary info at the end of the run
 		if config.IsVerbose(perGenMinimalVerboseLevel) || (lastGen && config.IsVerbose(finalVerboseLevel)) {
@@ -195,7 +217,11 @@ func (s *Species) ReportEachGen(genNum uint32, lastGen bool, totalInterimTime, g
 			config.Verbose(5, "Writing to file %v", config.FITNESS_FILENAME)
 			aveFit, minFit, maxFit, totalMutns, meanMutns, speciesSize := s.GetFitnessStats() // GetFitnessStats() caches its values so it's ok to call it multiple times
 			// If you change this line, you must also change the header in ReportInitial()
-			fmt.Fprintf(fitWriter, "%d  %d  %v  %v  %v  %v  %v  %v  %v\n", genNum, speciesSize, 0, aveFit, minFit, maxFit, totalMutns, meanMutns, 0)
+			if IsTribalCompetitionEnabled() {
+				fmt.Fprintf(fitWriter, "%d  %d  %v  %v  %v  %v  %v  %v  %v  %d\n", genNum, speciesSize, 0, aveFit, minFit, maxFit, totalMutns, meanMutns, 0, s.GetNumActiveTribes())
+			} else {
+				fmt.Fprintf(fitWriter, "%d  %d  %v  %v  %v  %v  %v  %v  %v\n", genNum, speciesSize, 0, aveFit, minFit, maxFit, totalMutns, meanMutns, 0)
+			}
 			//histWriter.Flush()  // <-- don't need this because we don't use a buffer for the file
 			if lastGen {
 				//todo: put summary stats in comments at the end of the file?
@@ -206,32 +232,58 @@ func (s *Species) ReportEachGen(genNum uint32, lastGen bool, totalInterimTime, g
 			config.Verbose(5, "Writing to file %v", config.HISTORY_FILENAME)
 			d, n, f := s.GetMutationStats() // GetMutationStats() caches its values so it's ok to call it multiple times
 			// If you change this line, you must also change the header in ReportInitial()
-			fmt.Fprintf(histWriter, "%d  %v  %v  %v\n", genNum, d, n, f)
+			if config.Cfg.Mutations.Allow_back_mutn {
+				var numBackMutations uint32
+				for _, p := range s.Populations { numBackMutations += p.NumBackMutations }
+				fmt.Fprintf(histWriter, "%d  %v  %v  %v  %d\n", genNum, d, n, f, numBackMutations)
+			} else {
+				fmt.Fprintf(histWriter, "%d  %v  %v  %v\n", genNum, d, n, f)
+			}
 			//histWriter.Flush()  // <-- don't need this because we don't use a buffer for the file
 			if lastGen {
 				//todo: put summary stats in comments at the end of the file?
 			}
 		}
+
+		if config.FMgr.GetFile(config.MITO_FILENAME, 0) != nil {
+			d, n, f := s.GetMitoStats()
+			writeMito(genNum, 0, d, n, f)
+		}
 	}
 
 	// Count and output the alleles for each pop
 	// This needs to come last if the lastGen because we free the individuals references to make memory room for the allele count
 	utils.Measure.Start("allele-count")
+	var speciesAlleles *dna.AlleleCount		// the sum of the tribes' counts, only needed for the species-wide SFS when there are tribes
+	var speciesSize, speciesMales, speciesFemales uint32
 	for _, p := range s.Populations {
-		p.CountAlleles(genNum, lastGen)
+		popSize := p.GetCurrentSize()
+		males, females := p.GetSexCounts()
+		alleles := p.CountAlleles(genNum, lastGen)
+		if alleles != nil && config.HasTribeFiles() && config.FMgr.IsDir(config.ALLELE_SFS_DIRECTORY) {
+			if speciesAlleles == nil { speciesAlleles = dna.AlleleCountFactory() }
+			speciesAlleles.Merge(alleles)
+			speciesSize += popSize
+			speciesMales += males
+			speciesFemales += females
+		}
 		utils.Measure.CheckAmountMemoryUsed()
 	}
+	if speciesAlleles != nil { outputSfs(genNum, speciesSize, speciesMales, speciesFemales, 0, speciesAlleles) }
 	utils.Measure.Stop("allele-count")
 }
 
 // GetAverageFitness gets the overall fitness of the species to determine if it has gone extinct
 func (s *Species) GetAverageFitness() (averageFitness float64) {
-	if s.GetNumPopulations() == 0 { return }
+	numPops := 0
 	for _, p := range s.Populations {
+		if p.GetCurrentSize() == 0 { continue }		// e.g. a tribe that was absorbed by another tribe
 		aveFit, _, _, _, _ := p.GetFitnessStats()	// its ok that this gets called multiple times, because it caches the data
 		averageFitness += aveFit
+		numPops++
 	}
-	return averageFitness / float64(s.GetNumPopulations())
+	if numPops == 0 { return }
+	return averageFitness / float64(numPops)
 }
 
 /* don't think this is useful...
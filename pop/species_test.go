@@ -0,0 +1,77 @@
+package pop
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"github.com/genetic-algorithms/mendel-go/utils"
+)
+
+// A [tribes.N] table in the input file must give that tribe its own parameters and models, while the other tribes use the regular values
+func TestTribeOverrides(t *testing.T) {
+	inputFile := filepath.Join(t.TempDir(), "tribes.ini")
+	contents := "[basic]\npop_size = 20\n" +
+		"[tribes]\nnum_tribes = 2\nhomogenous_tribes = false\n" +
+		"[tribes.2]\npop_size = 10\nmutn_rate = 0.0\nselection_model = \"fulltrunc\"\n"
+	setUpInputFileTest(t, inputFile, contents)
+
+	uniformRandom := rand.New(rand.NewSource(1))
+	s := SpeciesFactory()
+	for i := range s.Populations { s.Populations[i] = PopulationFactory(nil, 0, uint32(i+1), s.PartsPerPop) }
+	if s.Populations[0].Cfg != config.Cfg || s.Populations[0].Mdl != Mdl { t.Errorf("Tribe 1 should use the regular config and models") }
+	if size := s.Populations[1].GetCurrentSize(); size != 10 { t.Errorf("Tribe 2 has %d individuals, expected its pop_size of 10", size) }
+	if s.Populations[1].Cfg.Selection.Selection_model != "fulltrunc" || config.Cfg.Selection.Selection_model == "fulltrunc" { t.Errorf("Tribe 2 selection_model was not overridden, or the override changed the regular config") }
+
+	childrenS := s.GetNextGeneration(1)
+	s.Mate(childrenS, uniformRandom)
+	childrenS.Select(uniformRandom)
+	for _, indRef := range childrenS.Populations[1].IndivRefs {
+		if indRef.Indiv.NumMutations != 0 { t.Fatalf("An individual in tribe 2 has %d mutations, but the tribe's mutn_rate is 0.0", indRef.Indiv.NumMutations) }
+	}
+	if _, _, _, totalMutns, _ := childrenS.Populations[0].GetFitnessStats(); totalMutns == 0 { t.Errorf("Tribe 1 should have mutations with the regular mutn_rate") }
+	if size := childrenS.Populations[1].GetCurrentSize(); size != 10 { t.Errorf("Tribe 2 has %d individuals after selection, expected 10", size) }
+}
+
+
+// Each tribe must grow by its own pop_growth_model and growth parameters, stop at its own max_pop_size, and use its own non_scaling_noise
+func TestTribeGrowthModels(t *testing.T) {
+	inputFile := filepath.Join(t.TempDir(), "tribes.ini")
+	contents := "[basic]\npop_size = 20\n[population]\npop_growth_model = \"none\"\n" +
+		"[tribes]\nnum_tribes = 2\nhomogenous_tribes = false\n" +
+		"[tribes.2]\npop_size = 10\nheritability = 1.0\nnon_scaling_noise = 0.5\npop_growth_model = \"exponential\"\npop_growth_rate = 1.5\nmax_pop_size = 30\n"
+	setUpInputFileTest(t, inputFile, contents)
+
+	uniformRandom := rand.New(rand.NewSource(1))
+	s := SpeciesFactory()
+	for i := range s.Populations { s.Populations[i] = PopulationFactory(nil, 0, uint32(i+1), s.PartsPerPop) }
+	expectedSizes := [][2]uint32{{20, 15}, {20, 23}, {20, 35}}
+	for gen := uint32(1); gen <= 3; gen++ {
+		childrenS := s.GetNextGeneration(gen)
+		s.Mate(childrenS, uniformRandom)
+		childrenS.Select(uniformRandom)
+		for i, p := range childrenS.Populations {
+			if p.TargetSize != expectedSizes[gen-1][i] { t.Errorf("Gen %d: tribe %d has target size %d, expected %d", gen, p.TribeNum, p.TargetSize, expectedSizes[gen-1][i]) }
+		}
+		if noise := childrenS.Populations[1].EnvironNoise; noise != 0.5 { t.Errorf("Gen %d: tribe 2 has environmental noise %v, expected its non_scaling_noise of 0.5", gen, noise) }
+		s = childrenS
+	}
+	if s.Populations[0].IsDone(false) { t.Errorf("Tribe 1 is done, but it has no max_pop_size") }
+	if !s.Populations[1].IsDone(false) { t.Errorf("Tribe 2 is not done, but it is above its max_pop_size") }
+}
+
+
+// setUpInputFileTest writes contents to inputFile and reads it like the input file of a run, so it can have [tribes.N] tables
+func setUpInputFileTest(t *testing.T, inputFile, contents string) {
+	if err := os.WriteFile(inputFile, []byte(contents), 0644); err != nil { t.Fatalf("Error writing %v: %v", inputFile, err) }
+	config.CmdArgs = &config.CommandArgs{DefaultFile: "../mendel-defaults.ini", DataPath: t.TempDir()}
+	if err := config.ReadFromFile(inputFile); err != nil { t.Fatalf("Error reading %v: %v", inputFile, err) }
+	config.Cfg.Computation.Num_threads = 1
+	utils.MeasurerFactory(0)
+	utils.GlobalUniqueIntFactory()
+	dna.SetModels(config.Cfg)
+	SetModels(config.Cfg)
+}
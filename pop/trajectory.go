@@ -0,0 +1,142 @@
+package pop
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"github.com/genetic-algorithms/mendel-go/random"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"log"
+	"math/rand"
+	"sort"
+)
+
+// Allele trajectories (num_trajectories>0) choose a sample of the tracked alleles present in generation trajectory_gen and then write the
+// frequency of each of them in the species every generation to allele-trajectories.csv, so their rise and fall can be plotted. A full allele
+// count is only done once, to choose the sample. After that only the copies of the chosen alleles are counted.
+// Each line of allele-trajectories.csv is:  generation,id,type,fitness_effect,frequency
+
+const TRAJECTORIES_HEADER = "generation,id,type,fitness_effect,frequency\n"
+
+type TrajectoryModelType string
+const (
+	RANDOM_TRAJECTORIES TrajectoryModelType = "random"		// choose num_trajectories alleles randomly from all of the alleles
+	CLASS_TRAJECTORIES TrajectoryModelType = "class"		// choose num_trajectories alleles randomly from each effect size class (the classes of track_fixation)
+)
+
+// trajectoryMutation is 1 of the alleles whose frequency is written each gen
+type trajectoryMutation struct {
+	Id uint64
+	Type dna.MutationType
+	FitnessEffect float32
+}
+
+// trajectoryTracker is all of the trajectory state, which is saved in the checkpoint
+type trajectoryTracker struct {
+	Mutations []trajectoryMutation		// in id order
+}
+
+// trajectories is the trajectory state of the run, or nil if the alleles have not been chosen yet
+var trajectories *trajectoryTracker
+
+// Algorithms for choosing the alleles to follow from all of the alleles present (in id order)
+type SampleTrajectoriesType func(candidates []trajectoryMutation, num uint32, uniformRandom *rand.Rand) []trajectoryMutation
+
+
+// IsTrajectoriesEnabled returns true if the frequency of a sample of the alleles should be written to allele-trajectories.csv each gen
+func IsTrajectoriesEnabled() bool { return config.FMgr.IsFile(config.TRAJECTORIES_FILENAME) }
+
+
+// RandomSampleTrajectories chooses num of the candidates randomly, and returns them in id order
+func RandomSampleTrajectories(candidates []trajectoryMutation, num uint32, uniformRandom *rand.Rand) []trajectoryMutation {
+	if int(num) >= len(candidates) { return candidates }
+	chosen := make([]trajectoryMutation, 0, num)
+	for _, i := range uniformRandom.Perm(len(candidates))[:num] { chosen = append(chosen, candidates[i]) }
+	sort.Slice(chosen, func(i, j int) bool { return chosen[i].Id < chosen[j].Id })
+	return chosen
+}
+
+
+// ClassSampleTrajectories chooses num of the candidates randomly from each effect size class, and returns them in id order
+func ClassSampleTrajectories(candidates []trajectoryMutation, num uint32, uniformRandom *rand.Rand) []trajectoryMutation {
+	classes := make([][]trajectoryMutation, len(FIXATION_CLASS_NAMES))
+	for _, m := range candidates {
+		class := fixationClass(m.Type, m.FitnessEffect)
+		classes[class] = append(classes[class], m)
+	}
+	var chosen []trajectoryMutation
+	for _, class := range classes { chosen = append(chosen, RandomSampleTrajectories(class, num, uniformRandom)...) }
+	sort.Slice(chosen, func(i, j int) bool { return chosen[i].Id < chosen[j].Id })
+	return chosen
+}
+
+
+// TrackTrajectories chooses the alleles to follow when genNum is trajectory_gen, and from then on writes the frequency of each of them in the
+// species. This is called for the genesis population and then after selection (and migration/fission) each generation.
+func (s *Species) TrackTrajectories(genNum uint32) {
+	if !IsTrajectoriesEnabled() || genNum < config.Cfg.Computation.Trajectory_gen { return }
+	defer utils.Measure.Start("TrackTrajectories").Stop("TrackTrajectories")
+	trajFile := config.FMgr.GetFile(config.TRAJECTORIES_FILENAME, 0)
+	writer := bufio.NewWriter(trajFile)
+	if trajectories == nil {
+		trajectories = &trajectoryTracker{Mutations: s.sampleTrajectories()}
+		config.Verbose(1, "Gen %d: chose %d alleles to write the trajectories of to %v", genNum, len(trajectories.Mutations), config.TRAJECTORIES_FILENAME)
+		if _, err := writer.WriteString(TRAJECTORIES_HEADER); err != nil { log.Fatalf("Error writing %v: %v", config.TRAJECTORIES_FILENAME, err) }
+	}
+
+	// Count the copies of only the alleles we are following, and record which of them are on the Y (or W) or X (or Z) (see TrackFixation())
+	counts := make(map[uint64]uint32, len(trajectories.Mutations))
+	sexLinked := make(map[uint64]bool)
+	for _, m := range trajectories.Mutations { counts[m.Id] = 0 }
+	for _, p := range s.Populations {
+		for _, indRef := range p.IndivRefs {
+			for i, chromosomes := range [][]dna.Chromosome{indRef.Indiv.ChromosomesFromDad, indRef.Indiv.ChromosomesFromMom} {
+				for c := range chromosomes {
+					isSexChromosome := config.Cfg.Population.Separate_sexes && c == sexChromosome()
+					for lb := range chromosomes[c].LinkageBlocks {
+						for _, m := range chromosomes[c].LinkageBlocks[lb].GetMutations() {
+							if count, ok := counts[m.Id]; ok {
+								counts[m.Id] = count + 1
+								if isSexChromosome { sexLinked[m.Id] = indRef.Indiv.onHeteroChromosome(c, i == 0) }
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	totalCopies := 2 * s.GetCurrentSize()
+	var males, females uint32
+	if config.Cfg.Population.Separate_sexes { males, females = s.GetSexCounts() }
+	for _, m := range trajectories.Mutations {
+		copies := totalCopies
+		if onHetero, ok := sexLinked[m.Id]; ok { copies = sexChromosomeCopies(onHetero, males, females) }
+		if _, err := fmt.Fprintf(writer, "%d,%d,%s,%v,%v\n", genNum, m.Id, m.Type.Name(), m.FitnessEffect, float64(counts[m.Id]) / float64(utils.MaxInt(int(copies), 1))); err != nil { log.Fatalf("Error writing %v: %v", config.TRAJECTORIES_FILENAME, err) }
+	}
+	if err := writer.Flush(); err != nil { log.Fatalf("Error writing %v: %v", config.TRAJECTORIES_FILENAME, err) }		// flush every gen to support restart
+}
+
+
+// sampleTrajectories counts all of the alleles in the species and chooses the ones to follow using the trajectory_model
+func (s *Species) sampleTrajectories() []trajectoryMutation {
+	alleles := dna.AlleleCountFactory()
+	for _, p := range s.Populations { alleles.Merge(p.countAlleles(false, 0)) }
+	var candidates []trajectoryMutation
+	for _, a := range []struct {
+		mType dna.MutationType
+		alleles []dna.Allele
+	}{{dna.DELETERIOUS_DOMINANT, alleles.DeleteriousDom}, {dna.DELETERIOUS_RECESSIVE, alleles.DeleteriousRec}, {dna.NEUTRAL, alleles.Neutral},
+		{dna.FAVORABLE_DOMINANT, alleles.FavorableDom}, {dna.FAVORABLE_RECESSIVE, alleles.FavorableRec},
+		{dna.DEL_ALLELE, alleles.DelInitialAlleles}, {dna.FAV_ALLELE, alleles.FavInitialAlleles}} {
+		for _, al := range a.alleles { candidates = append(candidates, trajectoryMutation{Id: al.Id, Type: a.mType, FitnessEffect: al.FitnessEffect}) }
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Id < candidates[j].Id })		// so the sample is reproducible
+	if len(candidates) == 0 { log.Printf("Warning: there are no tracked alleles to choose from for %v", config.TRAJECTORIES_FILENAME) }
+
+	// Use a separate random number generator, so following trajectories does not change the rest of the run
+	seed := config.Cfg.Computation.Random_number_seed
+	if seed == 0 { seed = random.GetSeed() }
+	return Mdl.SampleTrajectories(candidates, config.Cfg.Computation.Num_trajectories, rand.New(rand.NewSource(seed)))
+}
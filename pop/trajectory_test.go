@@ -0,0 +1,39 @@
+package pop
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/dna"
+)
+
+// Both sampling models must return distinct alleles in id order, and the class model must take at most num from each effect size class
+func TestSampleTrajectories(t *testing.T) {
+	var candidates []trajectoryMutation
+	for i := uint64(1); i <= 100; i++ {
+		m := trajectoryMutation{Id: i, Type: dna.DELETERIOUS_DOMINANT, FitnessEffect: -0.001}
+		if i % 10 == 0 { m = trajectoryMutation{Id: i, Type: dna.FAVORABLE_DOMINANT, FitnessEffect: 0.01} }
+		if i % 10 == 5 { m = trajectoryMutation{Id: i, Type: dna.NEUTRAL} }
+		candidates = append(candidates, m)
+	}
+
+	random := RandomSampleTrajectories(candidates, 20, rand.New(rand.NewSource(1)))
+	if len(random) != 20 { t.Errorf("random sample has %d alleles, expected 20", len(random)) }
+	checkIdOrder(t, random)
+	if len(RandomSampleTrajectories(candidates, 200, rand.New(rand.NewSource(1)))) != len(candidates) { t.Errorf("random sample larger than the candidates should return all of them") }
+
+	class := ClassSampleTrajectories(candidates, 5, rand.New(rand.NewSource(1)))
+	if len(class) != 15 { t.Errorf("class sample has %d alleles, expected 15", len(class)) }
+	checkIdOrder(t, class)
+	perClass := make(map[int]int)
+	for _, m := range class { perClass[fixationClass(m.Type, m.FitnessEffect)]++ }
+	for c, n := range perClass {
+		if n != 5 { t.Errorf("class %q has %d alleles in the sample, expected 5", FIXATION_CLASS_NAMES[c], n) }
+	}
+}
+
+func checkIdOrder(t *testing.T, mutns []trajectoryMutation) {
+	for i := 1; i < len(mutns); i++ {
+		if mutns[i].Id <= mutns[i-1].Id { t.Errorf("sample is not in increasing id order at %d: %d, %d", i, mutns[i-1].Id, mutns[i].Id) }
+	}
+}
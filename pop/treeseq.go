@@ -0,0 +1,280 @@
+package pop
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tree sequence output (track_tree_sequence=true) writes the genealogy of the LBs as node, edge, site, and mutation tables in the tskit text
+// table format (see tskit.load_text()), so standard popgen tools can compute statistics on a mendel run. Each individual that survives selection
+// contributes 2 nodes (its chromosomes from dad and from mom). The edges come from the LB sections that crossover copied from each parent node,
+// and the mutations are the tracked mutations that arose in each node, each on its own site. Genome positions are in units of LBs, with the
+// chromosomes laid end to end, so the sequence length is num_linkage_subunits.
+// While the run is going the time of each node is the generation it was recorded in, because the last generation is not known yet (the run
+// can stop early). When the last generation is recorded, the tables are finalized: the node times are changed to the number of generations
+// before the last one (tskit measures time backward from the present) and the sites are sorted by position. The tree sequence is not
+// simplified, so it contains every individual that survived selection, not just the ancestors of the samples (use tskit's simplify() for that).
+
+const (
+	TREE_SEQ_NODES_HEADER = "is_sample\ttime\tpopulation\n"
+	TREE_SEQ_EDGES_HEADER = "left\tright\tparent\tchild\n"
+	TREE_SEQ_SITES_HEADER = "position\tancestral_state\n"
+	TREE_SEQ_MUTATIONS_HEADER = "site\tnode\tderived_state\n"
+)
+
+// The next row of the node and site tables. The ids of the rows in the tskit text tables are implicit, so we must count them. These are saved in the checkpoint.
+var nextNodeId, nextSiteId int64
+
+// treeSeqEdge is a section of 1 of an individual's chromosomes that was inherited from a parent node
+type treeSeqEdge struct {
+	Left, Right uint32		// the LB positions in the whole genome, right is exclusive
+	Parent int64
+	FromMom bool		// whether this is an edge to the individual's chromosomes from mom (NodeId+1) or from dad (NodeId)
+}
+
+// treeSeqMutation is a tracked mutation that arose in an individual
+type treeSeqMutation struct {
+	Position float64
+	FromMom bool
+}
+
+
+// siteOffset returns a position within an LB for a mutation. tskit requires every site to have a unique position, and the fractional parts of
+// multiples of the golden ratio are distinct (and well spread out), so the unique mutation ids give unique positions within each LB.
+func siteOffset(mutnId uint64) float64 {
+	_, frac := math.Modf(float64(mutnId) * 0.6180339887498949)
+	return frac
+}
+
+
+// recordTreeSeqEdges converts the LB sources that crossover recorded in each of this new individual's chromosomes to edges from the parent nodes.
+// This is called right after mating, when the parents are still available.
+func (offspr *Individual) recordTreeSeqEdges(dad, mom *Individual) {
+	offspr.treeSeqEdges = offspr.treeSeqEdges[:0]		// this may be a recycled individual
+	offspr.treeSeqMutns = offspr.treeSeqMutns[:0]
+	for c := range offspr.ChromosomesFromDad {
+		offspr.addTreeSeqEdges(&offspr.ChromosomesFromDad[c], false, dad, c)
+		offspr.addTreeSeqEdges(&offspr.ChromosomesFromMom[c], true, mom, c)
+	}
+	offspr.Mitochondrion.LbSources = nil		// the mitochondrion is not in the tree sequence, so do not hold on to the maternal ancestors it was copied from
+}
+
+
+// addTreeSeqEdges adds an edge for each section of LBs of chromosome c of this individual that was copied from 1 of the parent's chromosome c
+func (offspr *Individual) addTreeSeqEdges(chr *dna.Chromosome, fromMom bool, parent *Individual, c int) {
+	numLBs := len(chr.LinkageBlocks)
+	offset := config.Computed.Chromosome_offsets[c]
+	for i, src := range chr.LbSources {
+		endIndex := numLBs
+		if i+1 < len(chr.LbSources) { endIndex = chr.LbSources[i+1].BegIndex }
+		var parentNode int64
+		switch src.From {
+		case &parent.ChromosomesFromDad[c]:
+			parentNode = parent.NodeId
+		case &parent.ChromosomesFromMom[c]:
+			parentNode = parent.NodeId + 1
+		default:
+			log.Fatalf("Error: chromosome %d of an offspring was not copied from its parent", c)
+		}
+		offspr.treeSeqEdges = append(offspr.treeSeqEdges, treeSeqEdge{Left: offset + uint32(src.BegIndex), Right: offset + uint32(endIndex), Parent: parentNode, FromMom: fromMom})
+	}
+	chr.LbSources = nil		// so we do not hold on to the parent generation
+}
+
+
+// recordTreeSeqMutation notes the new mutation with id mutId in LB lbInChr of chromo, if it is tracked (untracked mutations are only pooled into the LB fitness)
+func (child *Individual) recordTreeSeqMutation(chromo *dna.Chromosome, fromDad bool, chr, lbInChr int, mutId uint64) {
+	mutns := chromo.LinkageBlocks[lbInChr].GetMutations()
+	if len(mutns) == 0 || mutns[len(mutns)-1].Id != mutId { return }
+	lb := int(config.Computed.Chromosome_offsets[chr]) + lbInChr
+	child.treeSeqMutns = append(child.treeSeqMutns, treeSeqMutation{Position: float64(lb) + siteOffset(mutId), FromMom: !fromDad})
+}
+
+
+// RecordTreeSequence adds the individuals of the species to the tree sequence tables. This is called for the genesis population and then after
+// selection (and migration/fission) each generation. The nodes of the last generation are the samples.
+func (s *Species) RecordTreeSequence(genNum uint32, lastGen bool) {
+	if !config.FMgr.IsTreeSequenceOutput() { return }
+	defer utils.Measure.Start("RecordTreeSequence").Stop("RecordTreeSequence")
+	nodes := newTreeSeqWriter(config.TREE_SEQUENCE_NODES)
+	edges := newTreeSeqWriter(config.TREE_SEQUENCE_EDGES)
+	sites := newTreeSeqWriter(config.TREE_SEQUENCE_SITES)
+	mutations := newTreeSeqWriter(config.TREE_SEQUENCE_MUTATIONS)
+	if genNum == 0 {
+		nodes.write(TREE_SEQ_NODES_HEADER)
+		edges.write(TREE_SEQ_EDGES_HEADER)
+		sites.write(TREE_SEQ_SITES_HEADER)
+		mutations.write(TREE_SEQ_MUTATIONS_HEADER)
+	}
+
+	isSample := 0
+	if lastGen { isSample = 1 }
+	genesisSites := make(map[uint64]int64)		// the initial alleles are shared by many genesis individuals, but each gets only 1 site
+	for _, p := range s.Populations {
+		if p.Done { continue }		// the individuals of a tribe that is done do not mate, so they were already recorded
+		population := p.TribeNum - 1
+		for _, indRef := range p.IndivRefs {
+			ind := indRef.Indiv
+			ind.NodeId = nextNodeId
+			nextNodeId += 2
+			nodes.write(fmt.Sprintf("%d\t%d\t%d\n%d\t%d\t%d\n", isSample, genNum, population, isSample, genNum, population))		// finalizeTreeSequence() converts the gen to the time
+			for _, e := range ind.treeSeqEdges {
+				child := ind.NodeId
+				if e.FromMom { child++ }
+				edges.write(fmt.Sprintf("%d\t%d\t%d\t%d\n", e.Left, e.Right, e.Parent, child))
+			}
+			for _, m := range ind.treeSeqMutns {
+				node := ind.NodeId
+				if m.FromMom { node++ }
+				sites.write(fmt.Sprintf("%v\t0\n", m.Position))
+				mutations.write(fmt.Sprintf("%d\t%d\t1\n", nextSiteId, node))
+				nextSiteId++
+			}
+			ind.treeSeqEdges = nil
+			ind.treeSeqMutns = nil
+			if genNum == 0 { ind.recordGenesisMutations(genesisSites, sites, mutations) }
+		}
+	}
+
+	nodes.flush()		// flush every gen to support restart
+	edges.flush()
+	sites.flush()
+	mutations.flush()
+	if lastGen { finalizeTreeSequence(genNum) }
+}
+
+
+// finalizeTreeSequence changes the node times from the generation number to the number of generations before lastGenNum, and sorts the sites
+// by position (renumbering the sites of the mutations to match), as tskit requires.
+func finalizeTreeSequence(lastGenNum uint32) {
+	rewriteTreeSeqTable(config.TREE_SEQUENCE_NODES, func(scanner *bufio.Scanner, w *treeSeqWriter) {
+		for scanner.Scan() {
+			fields := strings.Split(scanner.Text(), "\t")
+			if gen, err := strconv.ParseUint(fields[1], 10, 32); err == nil {
+				fields[1] = strconv.FormatInt(int64(lastGenNum) - int64(gen), 10)
+			}		// else it is the header
+			w.write(strings.Join(fields, "\t") + "\n")
+		}
+	})
+
+	// The site ids are the row numbers, so sorting the sites gives them new ids
+	var newSiteIds []int		// indexed by the old site id
+	rewriteTreeSeqTable(config.TREE_SEQUENCE_SITES, func(scanner *bufio.Scanner, w *treeSeqWriter) {
+		var positions []string
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "position") { continue }
+			positions = append(positions, strings.Split(scanner.Text(), "\t")[0])
+		}
+		values := make([]float64, len(positions))
+		for i, pos := range positions {
+			var err error
+			if values[i], err = strconv.ParseFloat(pos, 64); err != nil { log.Fatalf("Error: invalid position in %v: %v", config.TREE_SEQUENCE_SITES, pos) }
+		}
+		order := make([]int, len(positions))		// the old site ids in position order
+		for i := range order { order[i] = i }
+		sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+		w.write(TREE_SEQ_SITES_HEADER)
+		for _, oldId := range order { w.write(positions[oldId] + "\t0\n") }
+		newSiteIds = make([]int, len(order))
+		for newId, oldId := range order { newSiteIds[oldId] = newId }
+	})
+
+	rewriteTreeSeqTable(config.TREE_SEQUENCE_MUTATIONS, func(scanner *bufio.Scanner, w *treeSeqWriter) {
+		type treeSeqMutationRow struct {
+			site int
+			node string
+		}
+		var rows []treeSeqMutationRow
+		for scanner.Scan() {
+			fields := strings.Split(scanner.Text(), "\t")
+			oldId, err := strconv.Atoi(fields[0])
+			if err != nil { continue }		// the header
+			if oldId < 0 || oldId >= len(newSiteIds) { log.Fatalf("Error: invalid site id in %v: %v", config.TREE_SEQUENCE_MUTATIONS, fields[0]) }
+			rows = append(rows, treeSeqMutationRow{site: newSiteIds[oldId], node: fields[1]})
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].site < rows[j].site })
+		w.write(TREE_SEQ_MUTATIONS_HEADER)
+		for _, row := range rows { w.write(fmt.Sprintf("%d\t%s\t1\n", row.site, row.node)) }
+	})
+}
+
+
+// rewriteTreeSeqTable replaces the contents of 1 of the tree sequence tables with what rewrite writes while reading the current contents.
+// The new contents are written to a temporary file first, because they can be longer than the current contents.
+func rewriteTreeSeqTable(table string, rewrite func(scanner *bufio.Scanner, w *treeSeqWriter)) {
+	name := config.TREE_SEQUENCE_DIRECTORY + table
+	filePath := config.FMgr.DataFilePath + "/" + name
+	inFile, err := os.Open(filePath)		// the output file is only open for writing, so read it separately
+	if err != nil { log.Fatalf("Error opening %v: %v", filePath, err) }
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), table)
+	if err != nil { log.Fatalf("Error creating a temporary file for %v: %v", filePath, err) }
+	defer os.Remove(tmpFile.Name())
+	tmpWriter := &treeSeqWriter{name: tmpFile.Name(), writer: bufio.NewWriter(tmpFile)}
+	scanner := bufio.NewScanner(inFile)
+	rewrite(scanner, tmpWriter)
+	if err := scanner.Err(); err != nil { log.Fatalf("Error reading %v: %v", filePath, err) }
+	inFile.Close()
+	tmpWriter.flush()
+
+	// Copy the new contents into the open output file, so the file manager's file stays valid
+	file := config.FMgr.GetFile(name, 0)
+	if err := file.Truncate(0); err != nil { log.Fatalf("Error truncating %v: %v", filePath, err) }
+	if _, err := file.Seek(0, io.SeekStart); err != nil { log.Fatalf("Error seeking in %v: %v", filePath, err) }
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil { log.Fatalf("Error seeking in %v: %v", tmpFile.Name(), err) }
+	if _, err := io.Copy(file, tmpFile); err != nil { log.Fatalf("Error writing %v: %v", filePath, err) }
+	tmpFile.Close()
+}
+
+
+// recordGenesisMutations adds the initial alleles of this genesis individual to the tree sequence tables
+func (ind *Individual) recordGenesisMutations(genesisSites map[uint64]int64, sites, mutations *treeSeqWriter) {
+	for node, chromosomes := range [][]dna.Chromosome{ind.ChromosomesFromDad, ind.ChromosomesFromMom} {
+		for c := range chromosomes {
+			chr := &chromosomes[c]
+			for lbInChr := range chr.LinkageBlocks {
+				for _, m := range chr.LinkageBlocks[lbInChr].GetMutations() {
+					site, ok := genesisSites[m.Id]
+					if !ok {
+						site = nextSiteId
+						nextSiteId++
+						genesisSites[m.Id] = site
+						lb := int(config.Computed.Chromosome_offsets[c]) + lbInChr
+						sites.write(fmt.Sprintf("%v\t0\n", float64(lb) + siteOffset(m.Id)))
+					}
+					mutations.write(fmt.Sprintf("%d\t%d\t1\n", site, ind.NodeId + int64(node)))
+				}
+			}
+		}
+	}
+}
+
+
+// treeSeqWriter buffers the writes to 1 of the tree sequence tables
+type treeSeqWriter struct {
+	name string
+	writer *bufio.Writer
+}
+
+func newTreeSeqWriter(table string) *treeSeqWriter {
+	name := config.TREE_SEQUENCE_DIRECTORY + table
+	return &treeSeqWriter{name: name, writer: bufio.NewWriter(config.FMgr.GetFile(name, 0))}
+}
+
+func (w *treeSeqWriter) write(str string) {
+	if _, err := w.writer.WriteString(str); err != nil { log.Fatalf("Error writing %v: %v", w.name, err) }
+}
+
+func (w *treeSeqWriter) flush() {
+	if err := w.writer.Flush(); err != nil { log.Fatalf("Error writing %v: %v", w.name, err) }
+}
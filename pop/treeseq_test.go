@@ -0,0 +1,112 @@
+package pop
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+)
+
+// Each chromosome of an offspring must have edges that exactly cover it, from the nodes of the correct parent
+func TestTreeSeqEdges(t *testing.T) {
+	for _, crossoverModel := range []string{"none", "full", "partial"} {
+		setUpPopTest(t, func(c *config.Config) {
+			c.Basic.Pop_size = 10
+			c.Population.Crossover_model = crossoverModel
+			c.Computation.Track_tree_sequence = true
+		})
+		config.FileMgrFactory(t.TempDir(), config.TREE_SEQUENCE_DIRECTORY)
+		dna.SetModels(config.Cfg)
+		s := SpeciesFactory()
+		s.Populations[0] = PopulationFactory(nil, 0, 1, s.PartsPerPop)
+		nextNodeId, nextSiteId = 0, 0
+		s.RecordTreeSequence(0, false)
+		p := s.Populations[0]
+		newP := PopulationFactory(p, 1, 1, 1)
+		p.Mate(newP, rand.New(rand.NewSource(1)))
+
+		numLBs := config.Cfg.Population.Num_linkage_subunits
+		numNodes := int64(2 * len(p.IndivRefs))
+		numMutns := 0
+		for _, indRef := range newP.IndivRefs {
+			offspr := indRef.Indiv
+			numMutns += len(offspr.treeSeqMutns)
+			for _, fromMom := range []bool{false, true} {
+				var covered uint32
+				var parentNode int64 = -1
+				for _, e := range offspr.treeSeqEdges {
+					if e.FromMom != fromMom { continue }
+					if e.Left != covered { t.Errorf("With crossover_model=%v, an edge starts at %d instead of %d", crossoverModel, e.Left, covered) }
+					if e.Parent < 0 || e.Parent >= numNodes { t.Errorf("With crossover_model=%v, an edge is from node %d, which is not in the parent generation", crossoverModel, e.Parent) }
+					if parentNode >= 0 && e.Parent / 2 != parentNode / 2 { t.Errorf("With crossover_model=%v, the chromosomes from 1 parent have edges from 2 different individuals", crossoverModel) }
+					parentNode = e.Parent
+					covered = e.Right
+				}
+				if covered != numLBs { t.Errorf("With crossover_model=%v, the edges cover %d LBs instead of %d", crossoverModel, covered, numLBs) }
+			}
+		}
+		if numMutns == 0 { t.Errorf("With crossover_model=%v, no tree sequence mutations were recorded", crossoverModel) }
+	}
+}
+
+// Finalizing the tree sequence must measure the node times back from the last gen run, and sort the sites without changing which node
+// each mutation position is in
+func TestFinalizeTreeSequence(t *testing.T) {
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 10
+		c.Computation.Track_tree_sequence = true
+	})
+	dataPath := t.TempDir()
+	config.FileMgrFactory(dataPath, config.TREE_SEQUENCE_DIRECTORY)
+	dna.SetModels(config.Cfg)
+	s := SpeciesFactory()
+	s.Populations[0] = PopulationFactory(nil, 0, 1, s.PartsPerPop)
+	nextNodeId, nextSiteId = 0, 0
+	s.RecordTreeSequence(0, false)
+	uniformRandom := rand.New(rand.NewSource(1))
+	const lastGen = 3
+	for gen := uint32(1); gen <= lastGen; gen++ {
+		childrenS := s.GetNextGeneration(gen)
+		s.Mate(childrenS, uniformRandom)
+		childrenS.Select(uniformRandom)
+		childrenS.RecordTreeSequence(gen, false)
+		s = childrenS
+	}
+	readTable := func(table string) (rows [][]string) {
+		contents, err := os.ReadFile(filepath.Join(dataPath, config.TREE_SEQUENCE_DIRECTORY, table))
+		if err != nil { t.Fatalf("Error reading %v: %v", table, err) }
+		for _, line := range strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")[1:] { rows = append(rows, strings.Split(line, "\t")) }
+		return
+	}
+	mutationPositions := func() (positions []string) {		// the position and node of each mutation, in a canonical order
+		sites := readTable(config.TREE_SEQUENCE_SITES)
+		for _, m := range readTable(config.TREE_SEQUENCE_MUTATIONS) {
+			site, _ := strconv.Atoi(m[0])
+			positions = append(positions, sites[site][0] + " " + m[1])
+		}
+		sort.Strings(positions)
+		return
+	}
+	gens := readTable(config.TREE_SEQUENCE_NODES)
+	before := mutationPositions()
+	if len(before) == 0 { t.Fatalf("No tree sequence mutations were recorded") }
+
+	finalizeTreeSequence(lastGen)
+	for i, node := range readTable(config.TREE_SEQUENCE_NODES) {
+		gen, _ := strconv.Atoi(gens[i][1])
+		if node[1] != strconv.Itoa(lastGen - gen) { t.Errorf("Node %d of gen %d has time %v, expected %d", i, gen, node[1], lastGen - gen) }
+	}
+	var prev float64
+	for i, site := range readTable(config.TREE_SEQUENCE_SITES) {
+		pos, _ := strconv.ParseFloat(site[0], 64)
+		if i > 0 && pos < prev { t.Errorf("Site %d at position %v is before the previous site at %v", i, pos, prev) }
+		prev = pos
+	}
+	if after := mutationPositions(); strings.Join(after, ",") != strings.Join(before, ",") { t.Errorf("Finalizing changed the positions of the mutations") }
+}
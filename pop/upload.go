@@ -0,0 +1,104 @@
+package pop
+
+import (
+	"bufio"
+	"github.com/genetic-algorithms/mendel-go/config"
+	"github.com/genetic-algorithms/mendel-go/dna"
+	"github.com/genetic-algorithms/mendel-go/utils"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// UploadedMutation is 1 line of the upload_mutations_file: a mutation and where and how often it should be placed in the genesis population.
+type UploadedMutation struct {
+	ChromoIndex int		// 0 to numChr-1
+	LbIndexOnChr int	// 0 to LBsPerChromosome-1
+	FromDad bool		// which set of chromosomes (haplotype) the mutation goes on
+	Type dna.MutationType
+	FitnessEffect float32
+	Frequency float64	// the fraction of the population that should carry this mutation
+}
+
+
+// ReadUploadedMutations reads the file of mutations to give to the genesis population. Each non-blank, non-comment (#) line of the file is:
+//   chromosome  lb  haplotype  type  fitness  frequency
+// where chromosome is 1 to haploid_chromosome_number, lb is the LB number within the chromosome (1 to the chromosome's entry in chromosome_lbs),
+// haplotype is dad or mom, type is one of the names in dna.MutationTypeNames, fitness is the fitness effect of the mutation on the individual
+// (negative for deleterious), and frequency is the fraction of the population that carries it (0.0 - 1.0).
+func ReadUploadedMutations(fileName string, lBsPerChromosome []uint32) (mutns []UploadedMutation) {
+	file, err := os.Open(fileName)
+	if err != nil { log.Fatalf("Error opening upload_mutations_file %v: %v", fileName, err) }
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") { continue }
+		fields := strings.Fields(line)
+		if len(fields) != 6 { log.Fatalf("Error: line %d of %v has %d fields instead of: chromosome lb haplotype type fitness frequency", lineNum, fileName, len(fields)) }
+
+		var m UploadedMutation
+		chromo, err := strconv.Atoi(fields[0])
+		if err != nil || chromo < 1 || chromo > int(config.Cfg.Population.Haploid_chromosome_number) { log.Fatalf("Error: invalid chromosome %v on line %d of %v, it must be 1 - %d", fields[0], lineNum, fileName, config.Cfg.Population.Haploid_chromosome_number) }
+		m.ChromoIndex = chromo - 1
+		lb, err := strconv.Atoi(fields[1])
+		if err != nil || lb < 1 || lb > int(lBsPerChromosome[chromo-1]) { log.Fatalf("Error: invalid lb %v on line %d of %v, it must be 1 - %d", fields[1], lineNum, fileName, lBsPerChromosome[chromo-1]) }
+		m.LbIndexOnChr = lb - 1
+		switch strings.ToLower(fields[2]) {
+		case "dad":
+			m.FromDad = true
+		case "mom":
+			m.FromDad = false
+		default:
+			log.Fatalf("Error: invalid haplotype %v on line %d of %v, it must be dad or mom", fields[2], lineNum, fileName)
+		}
+		var ok bool
+		if m.Type, ok = dna.MutationTypeNames[strings.ToLower(fields[3])]; !ok { log.Fatalf("Error: invalid mutation type %v on line %d of %v", fields[3], lineNum, fileName) }
+		fitness, err := strconv.ParseFloat(fields[4], 32)
+		if err != nil { log.Fatalf("Error: invalid fitness %v on line %d of %v: %v", fields[4], lineNum, fileName, err) }
+		switch m.Type {
+		case dna.DELETERIOUS_DOMINANT, dna.DELETERIOUS_RECESSIVE, dna.DEL_ALLELE:
+			if fitness > 0.0 { log.Fatalf("Error: the fitness of deleterious mutation on line %d of %v must be <= 0.0", lineNum, fileName) }
+		case dna.FAVORABLE_DOMINANT, dna.FAVORABLE_RECESSIVE, dna.FAV_ALLELE:
+			if fitness < 0.0 { log.Fatalf("Error: the fitness of favorable mutation on line %d of %v must be >= 0.0", lineNum, fileName) }
+		case dna.NEUTRAL:
+			fitness = 0.0
+		}
+		m.FitnessEffect = float32(fitness)
+		if m.Frequency, err = strconv.ParseFloat(fields[5], 64); err != nil || m.Frequency < 0.0 || m.Frequency > 1.0 { log.Fatalf("Error: invalid frequency %v on line %d of %v, it must be 0.0 - 1.0", fields[5], lineNum, fileName) }
+		mutns = append(mutns, m)
+	}
+	if err := scanner.Err(); err != nil { log.Fatalf("Error reading upload_mutations_file %v: %v", fileName, err) }
+	return
+}
+
+
+// GenerateUploadedInitialAlleles gives the genesis population the mutations listed in upload_mutations_file. Each mutation is given to a random
+// subset of the individuals of size frequency * pop size.
+func GenerateUploadedInitialAlleles(p *Population, uniformRandom *rand.Rand) {
+	mutns := ReadUploadedMutations(config.Cfg.Population.Upload_mutations_file, p.LBsPerChromosome)
+	config.Verbose(1, "Uploading %d mutations from %v to tribe %d", len(mutns), config.Cfg.Population.Upload_mutations_file, p.TribeNum)
+	popSize := int(p.GetCurrentSize())
+	for _, m := range mutns {
+		// All of the individuals get the same mutation id, so it is counted as 1 allele
+		mutn := dna.Mutation{Id: utils.GlobalUniqueInt.NextInt(), Type: m.Type, FitnessEffect: m.FitnessEffect}
+		numIndivs := utils.RoundInt(float64(popSize) * m.Frequency)
+		allIndivs := numIndivs >= popSize
+		var indivIndices []int
+		if !allIndivs { indivIndices = uniformRandom.Perm(popSize) }  // as optimization don't mix pop order if we are going to give the mutation to everyone anyway
+		for j := 0; j < numIndivs && j < popSize; j++ {
+			var ind *Individual
+			if allIndivs {
+				ind = p.IndivRefs[j].Indiv
+			} else {
+				ind = p.IndivRefs[indivIndices[j]].Indiv
+			}
+			ind.AddUploadedMutation(m.ChromoIndex, m.LbIndexOnChr, m.FromDad, mutn)
+		}
+	}
+}
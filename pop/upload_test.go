@@ -0,0 +1,43 @@
+package pop
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/genetic-algorithms/mendel-go/config"
+)
+
+// The uploaded mutations must be given to the right fraction of the genesis population, and be included in their counts and fitness
+func TestGenerateUploadedInitialAlleles(t *testing.T) {
+	uploadFile := filepath.Join(t.TempDir(), "upload.txt")
+	contents := "# chromosome lb haplotype type fitness frequency\n" +
+		"1  3  dad  deleterious-dominant  -0.01  0.5\n" +
+		"\n" +
+		"23  43  mom  fav-allele  0.02  1.0\n"
+	if err := os.WriteFile(uploadFile, []byte(contents), 0644); err != nil { t.Fatalf("Error writing %v: %v", uploadFile, err) }
+	setUpPopTest(t, func(c *config.Config) {
+		c.Basic.Pop_size = 20
+		c.Population.Initial_allele_fitness_model = string(UPLOAD_INITIAL_ALLELES)
+		c.Population.Upload_mutations_file = uploadFile
+	})
+	p := PopulationFactory(nil, 0, 1, 1)
+	Mdl.GenerateInitialAlleles(p, rand.New(rand.NewSource(1)))
+
+	var numWithDel int
+	for _, indRef := range p.IndivRefs {
+		ind := indRef.Indiv
+		if ind.NumFavAllele != 1 || ind.ChromosomesFromMom[22].LinkageBlocks[42].GetNumMutations() != 1 { t.Errorf("Every individual should have the uploaded fav-allele on mom's chromosome 23, lb 43") }
+		expectedFitness := 1.02
+		if ind.NumDeleterious == 1 {
+			numWithDel++
+			if ind.ChromosomesFromDad[0].LinkageBlocks[2].GetNumMutations() != 1 { t.Errorf("The uploaded deleterious mutation is not on dad's chromosome 1, lb 3") }
+			expectedFitness = 1.01
+		}
+		if ind.NumMutations != ind.NumDeleterious + ind.NumFavAllele { t.Errorf("Individual has %d mutations, expected %d", ind.NumMutations, ind.NumDeleterious + ind.NumFavAllele) }
+		if fitness := SumIndivFitness(ind); math.Abs(fitness - expectedFitness) > 1e-6 { t.Errorf("Individual has fitness %v, expected %v", fitness, expectedFitness) }
+	}
+	if numWithDel != 10 { t.Errorf("%d individuals have the uploaded deleterious mutation, expected 10", numWithDel) }
+}
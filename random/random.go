@@ -5,6 +5,7 @@ import (
 	crand "crypto/rand"
 	"math"
 	"math/big"
+	"math/bits"
 	"math/rand"
 )
 
@@ -33,6 +34,70 @@ func RandFactory() *rand.Rand {
 //func (r *Rnd) Intn(n int) int   { return r.Rnd.Intn(n) }
 
 
+// Xoshiro256 is the xoshiro256** random number generator (https://prng.di.unimi.it/). It implements rand.Source64, and unlike the source that
+// math/rand.NewSource() returns, all of its state is in S, so the state can be saved in a checkpoint and restored by RestoreRand().
+type Xoshiro256 struct {
+	S [4]uint64
+}
+
+// RandState is the saved state of a Xoshiro256 source
+type RandState struct {
+	S [4]uint64
+}
+
+// Seed fills the state from seed with splitmix64, as the xoshiro authors recommend, so the state is never all 0
+func (x *Xoshiro256) Seed(seed int64) {
+	z := uint64(seed)
+	for i := range x.S {
+		z += 0x9e3779b97f4a7c15
+		r := z
+		r = (r ^ (r >> 30)) * 0xbf58476d1ce4e5b9
+		r = (r ^ (r >> 27)) * 0x94d049bb133111eb
+		x.S[i] = r ^ (r >> 31)
+	}
+}
+
+func (x *Xoshiro256) Uint64() uint64 {
+	s := &x.S
+	result := bits.RotateLeft64(s[1] * 5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+func (x *Xoshiro256) Int63() int64 { return int64(x.Uint64() >> 1) }
+
+// GetState returns the current state of this source
+func (x *Xoshiro256) GetState() RandState { return RandState{S: x.S} }
+
+
+// TrackedRandFactory is like RandFactory, except the generator is backed by a Xoshiro256 source, which is also returned so its state can be checkpointed.
+func TrackedRandFactory() (*rand.Rand, *Xoshiro256) {
+	var seed int64
+	if NextSeed != 0 {
+		seed = NextSeed
+		NextSeed++
+	} else {
+		seed = GetSeed()
+	}
+	src := &Xoshiro256{}
+	src.Seed(seed)
+	return rand.New(src), src
+}
+
+
+// RestoreRand recreates a random number generator at the point in its sequence that was recorded in state.
+func RestoreRand(state RandState) (*rand.Rand, *Xoshiro256) {
+	src := &Xoshiro256{S: state.S}
+	return rand.New(src), src
+}
+
+
 // Round randomly rounds an int either up or down, weighting the odds according to how far away from the integer it is.
 // If the float is a perfect int, it always chooses that.
 func Round(uniformRandom *rand.Rand, num float64) int {
@@ -76,3 +76,20 @@ func TestShuffle(t *testing.T) {
 	}
 }
 */
+
+
+// A generator restored from the state saved partway through its sequence must have that state and continue with the same numbers
+func TestRestoreRand(t *testing.T) {
+	NextSeed = 7
+	r, src := TrackedRandFactory()
+	for i := 0; i < 1000; i++ { r.Float64(); r.Intn(50) }
+	state := src.GetState()
+	if state.S == [4]uint64{} { t.Fatalf("The state of the source is all 0") }
+	restored, restoredSrc := RestoreRand(state)
+	if restoredSrc.GetState() != state { t.Errorf("The restored state is %v, expected %v", restoredSrc.GetState(), state) }
+	for i := 0; i < 1000; i++ {
+		if x, y := r.Float64(), restored.Float64(); y != x { t.Fatalf("Draw %d of the restored generator is %v, expected %v", i, y, x) }
+	}
+	if restoredSrc.GetState() != src.GetState() { t.Errorf("After the same draws the restored state is %v, expected %v", restoredSrc.GetState(), src.GetState()) }
+	NextSeed = 0
+}
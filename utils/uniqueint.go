@@ -46,3 +46,11 @@ func (u *UniqueInt) NextInt() uint64 {
 	u.nextInt++
 	return i
 }
+
+
+// GetNextInt returns the next int that will be handed out, so it can be saved in a checkpoint.
+func (u *UniqueInt) GetNextInt() uint64 { return u.nextInt }
+
+
+// SetNextInt sets the next int to hand out. This is used when restoring from a checkpoint.
+func (u *UniqueInt) SetNextInt(nextInt uint64) { u.nextInt = nextInt }
@@ -45,12 +45,10 @@ func MinInt(a, b int) int {
 	return b
 }
 
-/*
 func MaxInt(a, b int) int {
 	if a > b { return a }
 	return b
 }
-*/
 
 func MinUint32(a, b uint32) uint32 {
 	if a < b { return a }